@@ -10,6 +10,7 @@ import (
 type Cmd struct {
 	app *cli.App
 	flag *Flag
+	commands []*cli.Command
 	isRunning bool
 }
 
@@ -61,11 +62,27 @@ func (f *Cmd) InitApp(sf StartFunc, appNames ...string) error {
 			return sf(c)
 		},
 		Flags: f.flag.GetFlags(),
+		Commands: f.commands,
 	}
 	f.app = app
 	return nil
 }
 
+//register sub command, step-1
+//like `migrate.NewCommand`
+func (f *Cmd) RegisterCommand(commands ...*cli.Command) error {
+	if f.app != nil {
+		return errors.New("app had init")
+	}
+	for _, command := range commands {
+		if command == nil {
+			return errors.New("invalid parameter")
+		}
+		f.commands = append(f.commands, command)
+	}
+	return nil
+}
+
 //register new flag, step-1
 func (f *Cmd) RegisterBoolFlag(nameTag string, usages ...string) error {
 	return f.RegisterNewFlag(nameTag, FlagKindOfBool, usages...)
@@ -4,6 +4,7 @@ package config
 type Config struct {
 	ini *IniConfig
 	json *JsonConfig
+	history *History
 }
 
 //construct
@@ -20,7 +21,9 @@ func NewConfig(params ...interface{}) *Config {
 	this := &Config{
 		ini: NewIniConfigWithPara(cfgRootPath),
 		json: NewJsonConfigWithPara(cfgRootPath),
+		history: NewHistory(),
 	}
+	this.ini.SetHistory(this.history)
 	return this
 }
 
@@ -31,4 +34,8 @@ func (c *Config) GetIniConf() *IniConfig {
 
 func (c *Config) GetJsonConf() *JsonConfig {
 	return c.json
+}
+
+func (c *Config) GetHistory() *History {
+	return c.history
 }
\ No newline at end of file
@@ -0,0 +1,465 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinycells/logger"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * config snapshot, diff and audit trail
+ * - record versioned snapshot for each config tag
+ * - compute added/removed/changed keys between versions
+ * - can be registered as web sub app for admin query
+ */
+
+//inter macro define
+const (
+	HistoryMaxSize = 50 //max snapshots per tag
+	HistoryKeySplit = "."
+
+	DiffKindOfAdded = "added"
+	DiffKindOfRemoved = "removed"
+	DiffKindOfChanged = "changed"
+
+	HistoryMaskedValue = "******"
+)
+
+//default sensitive key words, value of key contains them will be masked
+//in audit log and web entry
+var HistorySensitiveKeys = []string{
+	"password", "passwd", "pwd", "secret", "token",
+	"apikey", "api_key", "privatekey", "private_key",
+	"accesskey", "access_key", "credential",
+}
+
+//snapshot info
+type Snapshot struct {
+	Tag string `json:"tag"`
+	Version int64 `json:"version"`
+	Data map[string]interface{} `json:"data"` //flatten key -> value
+	CreateAt int64 `json:"createAt"`
+}
+
+//diff item info
+type DiffItem struct {
+	Key string `json:"key"`
+	Kind string `json:"kind"`
+	OldVal interface{} `json:"oldVal,omitempty"`
+	NewVal interface{} `json:"newVal,omitempty"`
+}
+
+//diff info
+type Diff struct {
+	Tag string `json:"tag"`
+	FromVersion int64 `json:"fromVersion"`
+	ToVersion int64 `json:"toVersion"`
+	Items []*DiffItem `json:"items"`
+	CreateAt int64 `json:"createAt"`
+}
+
+//face info
+type History struct {
+	maxSize int
+	snapshotMap map[string][]*Snapshot //tag -> []*Snapshot
+	diffMap map[string][]*Diff //tag -> []*Diff
+	versionMap map[string]int64 //tag -> last version
+	sensitiveKeys []string
+	logger *logger.Logger
+	sync.RWMutex
+}
+
+//construct
+func NewHistory(maxSizes ...int) *History {
+	maxSize := HistoryMaxSize
+	if maxSizes != nil && len(maxSizes) > 0 && maxSizes[0] > 0 {
+		maxSize = maxSizes[0]
+	}
+	this := &History{
+		maxSize: maxSize,
+		snapshotMap: map[string][]*Snapshot{},
+		diffMap: map[string][]*Diff{},
+		versionMap: map[string]int64{},
+		sensitiveKeys: append([]string{}, HistorySensitiveKeys...),
+	}
+	return this
+}
+
+//add sensitive key words, case insensitive
+func (f *History) AddSensitiveKeys(keys ...string) {
+	f.Lock()
+	defer f.Unlock()
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		f.sensitiveKeys = append(f.sensitiveKeys, strings.ToLower(key))
+	}
+}
+
+//check key is sensitive, only last segment of flatten key checked
+func (f *History) IsSensitive(key string) bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.isSensitive(key)
+}
+
+//set logger
+func (f *History) SetLogger(l *logger.Logger) {
+	f.Lock()
+	defer f.Unlock()
+	f.logger = l
+}
+
+//record new snapshot for tag
+//data can be json config map or ini config file
+//return nil diff if config not changed
+func (f *History) Record(tag string, data interface{}) (*Diff, error) {
+	//check
+	if tag == "" {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//flatten data as new snapshot
+	flatData := map[string]interface{}{}
+	f.flatten("", data, flatData)
+	now := time.Now().Unix()
+
+	f.Lock()
+	defer f.Unlock()
+
+	//compare with last snapshot
+	var (
+		lastData map[string]interface{}
+		lastVersion int64
+	)
+	snapshots := f.snapshotMap[tag]
+	if len(snapshots) > 0 {
+		last := snapshots[len(snapshots)-1]
+		lastData = last.Data
+		lastVersion = last.Version
+	}
+	items := f.compare(lastData, flatData)
+	if len(snapshots) > 0 && len(items) <= 0 {
+		//nothing changed
+		return nil, nil
+	}
+
+	//save new snapshot
+	version := f.versionMap[tag] + 1
+	f.versionMap[tag] = version
+	snapshot := &Snapshot{
+		Tag: tag,
+		Version: version,
+		Data: flatData,
+		CreateAt: now,
+	}
+	snapshots = append(snapshots, snapshot)
+	if len(snapshots) > f.maxSize {
+		snapshots = snapshots[len(snapshots)-f.maxSize:]
+	}
+	f.snapshotMap[tag] = snapshots
+
+	//save diff
+	diff := &Diff{
+		Tag: tag,
+		FromVersion: lastVersion,
+		ToVersion: version,
+		Items: items,
+		CreateAt: now,
+	}
+	diffs := append(f.diffMap[tag], diff)
+	if len(diffs) > f.maxSize {
+		diffs = diffs[len(diffs)-f.maxSize:]
+	}
+	f.diffMap[tag] = diffs
+
+	//write audit log
+	f.writeLog(diff)
+	return diff, nil
+}
+
+//get all tags
+func (f *History) GetTags() []string {
+	f.RLock()
+	defer f.RUnlock()
+	tags := make([]string, 0, len(f.snapshotMap))
+	for tag := range f.snapshotMap {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+//get snapshots of tag, oldest first
+func (f *History) GetSnapshots(tag string) []*Snapshot {
+	f.RLock()
+	defer f.RUnlock()
+	v, ok := f.snapshotMap[tag]
+	if !ok {
+		return nil
+	}
+	result := make([]*Snapshot, len(v))
+	copy(result, v)
+	return result
+}
+
+//get assigned version snapshot of tag
+func (f *History) GetSnapshot(tag string, version int64) *Snapshot {
+	f.RLock()
+	defer f.RUnlock()
+	for _, v := range f.snapshotMap[tag] {
+		if v.Version == version {
+			return v
+		}
+	}
+	return nil
+}
+
+//get latest snapshot of tag
+func (f *History) GetLatest(tag string) *Snapshot {
+	f.RLock()
+	defer f.RUnlock()
+	v, ok := f.snapshotMap[tag]
+	if !ok || len(v) <= 0 {
+		return nil
+	}
+	return v[len(v)-1]
+}
+
+//get diffs of tag, oldest first
+func (f *History) GetDiffs(tag string) []*Diff {
+	f.RLock()
+	defer f.RUnlock()
+	v, ok := f.diffMap[tag]
+	if !ok {
+		return nil
+	}
+	result := make([]*Diff, len(v))
+	copy(result, v)
+	return result
+}
+
+//compare two saved versions of tag
+func (f *History) DiffVersion(tag string, fromVersion, toVersion int64) (*Diff, error) {
+	from := f.GetSnapshot(tag, fromVersion)
+	to := f.GetSnapshot(tag, toVersion)
+	if from == nil || to == nil {
+		return nil, errors.New("no such version")
+	}
+	diff := &Diff{
+		Tag: tag,
+		FromVersion: fromVersion,
+		ToVersion: toVersion,
+		Items: f.compare(from.Data, to.Data),
+		CreateAt: time.Now().Unix(),
+	}
+	return diff, nil
+}
+
+//web entry, can be registered by `web.App.RegisterSubApp`
+//query para:
+//- none: list all tags
+//- tag: list snapshots and diffs of tag
+//- tag + version: get one snapshot
+//- tag + from + to: diff two versions
+func (f *History) Entry(c *gin.Context) {
+	tag := c.Query("tag")
+	if tag == "" {
+		c.JSON(http.StatusOK, gin.H{"tags": f.GetTags()})
+		return
+	}
+
+	//get one snapshot
+	if versionStr := c.Query("version"); versionStr != "" {
+		version, _ := strconv.ParseInt(versionStr, 10, 64)
+		snapshot := f.GetSnapshot(tag, version)
+		if snapshot == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no such version"})
+			return
+		}
+		c.JSON(http.StatusOK, f.maskSnapshot(snapshot))
+		return
+	}
+
+	//diff two versions
+	fromStr, toStr := c.Query("from"), c.Query("to")
+	if fromStr != "" && toStr != "" {
+		from, _ := strconv.ParseInt(fromStr, 10, 64)
+		to, _ := strconv.ParseInt(toStr, 10, 64)
+		diff, err := f.DiffVersion(tag, from, to)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, f.maskDiff(diff))
+		return
+	}
+
+	//list versions of tag
+	versions := make([]int64, 0)
+	for _, v := range f.GetSnapshots(tag) {
+		versions = append(versions, v.Version)
+	}
+	diffs := make([]*Diff, 0)
+	for _, v := range f.GetDiffs(tag) {
+		diffs = append(diffs, f.maskDiff(v))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tag": tag,
+		"versions": versions,
+		"diffs": diffs,
+	})
+}
+
+////////////////
+//private func
+////////////////
+
+//write diff into audit log, called with lock
+func (f *History) writeLog(diff *Diff) {
+	for _, item := range diff.Items {
+		if f.isSensitive(item.Key) {
+			item = f.maskItem(item)
+		}
+		if f.logger != nil {
+			f.logger.SS().Infow("config changed",
+				"tag", diff.Tag,
+				"version", diff.ToVersion,
+				"key", item.Key,
+				"kind", item.Kind,
+				"old", item.OldVal,
+				"new", item.NewVal,
+			)
+			continue
+		}
+		log.Printf("config changed, tag:%v, version:%v, key:%v, kind:%v, old:%v, new:%v\n",
+			diff.Tag, diff.ToVersion, item.Key, item.Kind, item.OldVal, item.NewVal)
+	}
+}
+
+//copy snapshot with sensitive value masked
+func (f *History) maskSnapshot(snapshot *Snapshot) *Snapshot {
+	f.RLock()
+	defer f.RUnlock()
+	data := make(map[string]interface{}, len(snapshot.Data))
+	for k, v := range snapshot.Data {
+		if f.isSensitive(k) {
+			v = HistoryMaskedValue
+		}
+		data[k] = v
+	}
+	result := *snapshot
+	result.Data = data
+	return &result
+}
+
+//copy diff with sensitive value masked
+func (f *History) maskDiff(diff *Diff) *Diff {
+	f.RLock()
+	defer f.RUnlock()
+	items := make([]*DiffItem, 0, len(diff.Items))
+	for _, item := range diff.Items {
+		if f.isSensitive(item.Key) {
+			item = f.maskItem(item)
+		}
+		items = append(items, item)
+	}
+	result := *diff
+	result.Items = items
+	return &result
+}
+
+//copy diff item with value masked
+func (f *History) maskItem(item *DiffItem) *DiffItem {
+	result := *item
+	if result.OldVal != nil {
+		result.OldVal = HistoryMaskedValue
+	}
+	if result.NewVal != nil {
+		result.NewVal = HistoryMaskedValue
+	}
+	return &result
+}
+
+//check key is sensitive, called with lock
+func (f *History) isSensitive(key string) bool {
+	if idx := strings.LastIndex(key, HistoryKeySplit); idx >= 0 {
+		key = key[idx+1:]
+	}
+	key = strings.ToLower(key)
+	for _, v := range f.sensitiveKeys {
+		if strings.Contains(key, v) {
+			return true
+		}
+	}
+	return false
+}
+
+//compare old and new flatten data
+//result sorted by key
+func (f *History) compare(oldData, newData map[string]interface{}) []*DiffItem {
+	items := make([]*DiffItem, 0)
+	for k, newVal := range newData {
+		oldVal, ok := oldData[k]
+		if !ok {
+			items = append(items, &DiffItem{Key: k, Kind: DiffKindOfAdded, NewVal: newVal})
+			continue
+		}
+		if !reflect.DeepEqual(oldVal, newVal) {
+			items = append(items, &DiffItem{Key: k, Kind: DiffKindOfChanged, OldVal: oldVal, NewVal: newVal})
+		}
+	}
+	for k, oldVal := range oldData {
+		if _, ok := newData[k]; !ok {
+			items = append(items, &DiffItem{Key: k, Kind: DiffKindOfRemoved, OldVal: oldVal})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+	return items
+}
+
+//flatten nested map into `a.b.c` format keys
+func (f *History) flatten(prefix string, data interface{}, result map[string]interface{}) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			f.flatten(f.joinKey(prefix, key), val, result)
+		}
+	case map[string]string:
+		for key, val := range v {
+			result[f.joinKey(prefix, key)] = val
+		}
+	case map[string]Section:
+		for key, val := range v {
+			f.flatten(f.joinKey(prefix, key), map[string]string(val), result)
+		}
+	case File:
+		f.flatten(prefix, map[string]Section(v), result)
+	case Section:
+		f.flatten(prefix, map[string]string(v), result)
+	default:
+		if prefix != "" {
+			result[prefix] = v
+		}
+	}
+}
+
+//join key with prefix
+func (f *History) joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return fmt.Sprintf("%s%s%s", prefix, HistoryKeySplit, key)
+}
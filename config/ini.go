@@ -15,6 +15,7 @@ type IniConfig struct {
 	cfgRootPath string
 	cfgMap map[string]*File //tag -> *File
 	autoReload bool
+	history *History
 	sync.RWMutex
 }
 
@@ -94,9 +95,21 @@ func (f *IniConfig) LoadConfig(cfgFileName string) error {
 	f.Lock()
 	defer f.Unlock()
 	f.cfgMap[cfgFileName] = &file
+
+	//record snapshot
+	if f.history != nil {
+		f.history.Record(cfgFileName, file)
+	}
 	return nil
 }
 
+//set history
+func (f *IniConfig) SetHistory(history *History) {
+	f.Lock()
+	defer f.Unlock()
+	f.history = history
+}
+
 //set auto reload switch
 func (f *IniConfig) SetAutoReload(switcher bool) {
 	f.Lock()
@@ -4,6 +4,7 @@ package config
 import (
 	"log"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -24,7 +25,9 @@ type SubConfig struct {
 	conf *JsonConfig `config instance`
 	confMap map[string]interface{}
 	lastTime int64 `last update time`
+	history *History
 	closeChan chan bool
+	sync.RWMutex
 }
 
 //construct
@@ -63,8 +66,27 @@ func (c *SubConfig) Quit() {
 	c.closeChan <- true
 }
 
+//set history, current config will be recorded as first snapshot
+func (c *SubConfig) SetHistory(history *History) {
+	c.Lock()
+	defer c.Unlock()
+	c.history = history
+	if history != nil && c.confMap != nil {
+		history.Record(c.confFile, c.confMap)
+	}
+}
+
+//get history
+func (c *SubConfig) GetHistory() *History {
+	c.RLock()
+	defer c.RUnlock()
+	return c.history
+}
+
 //get map data
 func (c *SubConfig) GetConfMap() map[string]interface{} {
+	c.RLock()
+	defer c.RUnlock()
 	return c.confMap
 }
 
@@ -81,12 +103,18 @@ func (c *SubConfig) preLoadConfig() bool {
 		return false
 	}
 
-	//get all data
-	c.confMap = c.conf.GetAllConfigs()
+	//get all data and record snapshot
+	confMap := c.conf.GetAllConfigs()
+	c.Lock()
+	c.confMap = confMap
+	if c.history != nil {
+		c.history.Record(c.confFile, confMap)
+	}
+	c.Unlock()
 
 	//run call back
-	if c.cbForAnalyze != nil && c.confMap != nil {
-		c.cbForAnalyze(c.confMap)
+	if c.cbForAnalyze != nil && confMap != nil {
+		c.cbForAnalyze(confMap)
 	}
 
 	return true
@@ -0,0 +1,410 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"sync"
+)
+
+/*
+ * authenticated encryption
+ * - AES-256-GCM or XChaCha20-Poly1305
+ * - random nonce and versioned ciphertext header
+ * - key derived from password by Argon2id or scrypt
+ *
+ * ciphertext format:
+ * - key mode: [version][alg][nonce][sealed]
+ * - password mode: [version][alg][kdf][kdf params][salt][nonce][sealed]
+ *   argon2id params: [time][memory, uint32 big endian][threads]
+ *   scrypt params: [logN][r][p]
+ * header bytes are authenticated as additional data
+ */
+
+//ciphertext version
+const (
+	AeadVersionOfKey = 1
+	AeadVersionOfPassword = 2
+)
+
+//algorithm
+const (
+	AeadAlgOfAesGcm = 1
+	AeadAlgOfXChaCha20 = 2
+)
+
+//key derivation function
+const (
+	AeadKdfOfArgon2id = 1
+	AeadKdfOfScrypt = 2
+)
+
+//default value
+const (
+	AeadKeySize = 32
+	AeadSaltSize = 16
+
+	Argon2Time = 1
+	Argon2Memory = 64 * 1024 //KiB
+	Argon2Threads = 4
+
+	ScryptN = 32768
+	ScryptLogN = 15
+	ScryptR = 8
+	ScryptP = 1
+)
+
+//face info
+type Aead struct {
+	key []byte
+	alg int
+	kdf int
+	kdfParams *PasswordParams
+	sync.RWMutex
+}
+
+//construct
+func NewAead(keys ...[]byte) *Aead {
+	this := &Aead{
+		alg: AeadAlgOfAesGcm,
+		kdf: AeadKdfOfArgon2id,
+		kdfParams: &PasswordParams{
+			Argon2Time: Argon2Time,
+			Argon2Memory: Argon2Memory,
+			Argon2Threads: Argon2Threads,
+			ScryptLogN: ScryptLogN,
+			ScryptR: ScryptR,
+			ScryptP: ScryptP,
+		},
+	}
+	if keys != nil && len(keys) > 0 {
+		this.SetKey(keys[0])
+	}
+	return this
+}
+
+//gen random key
+func (f *Aead) GenKey() ([]byte, error) {
+	key := make([]byte, AeadKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+//set key, should be 32 bytes
+func (f *Aead) SetKey(key []byte) error {
+	if len(key) != AeadKeySize {
+		return errors.New("key should be 32 bytes")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.key = append([]byte{}, key...)
+	return nil
+}
+
+//set algorithm for encrypt
+func (f *Aead) SetAlg(alg int) error {
+	if alg != AeadAlgOfAesGcm && alg != AeadAlgOfXChaCha20 {
+		return errors.New("invalid algorithm")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.alg = alg
+	return nil
+}
+
+//set key derivation function for password mode
+func (f *Aead) SetKdf(kdf int) error {
+	if kdf != AeadKdfOfArgon2id && kdf != AeadKdfOfScrypt {
+		return errors.New("invalid kdf")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.kdf = kdf
+	return nil
+}
+
+//set kdf params for password mode, bcrypt cost not used
+//params stored in ciphertext header, old data still can be decrypted
+func (f *Aead) SetKdfParams(params *PasswordParams) error {
+	if params == nil {
+		return errors.New("invalid parameter")
+	}
+	for _, alg := range []string{PasswordAlgOfArgon2id, PasswordAlgOfScrypt} {
+		if err := checkPasswordParams(alg, params); err != nil {
+			return err
+		}
+	}
+	paramsCopy := *params
+	f.Lock()
+	defer f.Unlock()
+	f.kdfParams = &paramsCopy
+	return nil
+}
+
+//derive key from password by default params
+func (f *Aead) DeriveKey(password, salt []byte, kdf int) ([]byte, error) {
+	return f.deriveKey(password, salt, kdf, &PasswordParams{
+		Argon2Time: Argon2Time,
+		Argon2Memory: Argon2Memory,
+		Argon2Threads: Argon2Threads,
+		ScryptLogN: ScryptLogN,
+		ScryptR: ScryptR,
+		ScryptP: ScryptP,
+	})
+}
+
+//encrypt with key
+//aad is optional additional data, must be same when decrypt
+func (f *Aead) Encrypt(plain []byte, aad ...[]byte) ([]byte, error) {
+	f.RLock()
+	key, alg := f.key, f.alg
+	f.RUnlock()
+	if key == nil {
+		return nil, errors.New("key not set")
+	}
+	header := []byte{AeadVersionOfKey, byte(alg)}
+	return f.seal(header, key, alg, plain, aad...)
+}
+
+//decrypt with key
+func (f *Aead) Decrypt(data []byte, aad ...[]byte) ([]byte, error) {
+	f.RLock()
+	key := f.key
+	f.RUnlock()
+	if key == nil {
+		return nil, errors.New("key not set")
+	}
+	if len(data) < 2 || data[0] != AeadVersionOfKey {
+		return nil, errors.New("invalid ciphertext version")
+	}
+	return f.open(data[:2], key, int(data[1]), data[2:], aad...)
+}
+
+//encrypt with password
+func (f *Aead) EncryptWithPassword(password, plain []byte, aad ...[]byte) ([]byte, error) {
+	f.RLock()
+	alg, kdf, params := f.alg, f.kdf, f.kdfParams
+	f.RUnlock()
+
+	//derive key
+	salt := make([]byte, AeadSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := f.deriveKey(password, salt, kdf, params)
+	if err != nil {
+		return nil, err
+	}
+	header := []byte{AeadVersionOfPassword, byte(alg), byte(kdf)}
+	header = append(header, f.encodeKdfParams(kdf, params)...)
+	header = append(header, salt...)
+	return f.seal(header, key, alg, plain, aad...)
+}
+
+//decrypt with password
+func (f *Aead) DecryptWithPassword(password, data []byte, aad ...[]byte) ([]byte, error) {
+	if len(data) < 3 || data[0] != AeadVersionOfPassword {
+		return nil, errors.New("invalid ciphertext version")
+	}
+	kdf := int(data[2])
+	params, paramsSize, err := f.decodeKdfParams(kdf, data[3:])
+	if err != nil {
+		return nil, err
+	}
+	headerSize := 3 + paramsSize + AeadSaltSize
+	if len(data) < headerSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	key, err := f.deriveKey(password, data[3 + paramsSize:headerSize], kdf, params)
+	if err != nil {
+		return nil, err
+	}
+	return f.open(data[:headerSize], key, int(data[1]), data[headerSize:], aad...)
+}
+
+//encrypt string, return raw url base64 format
+func (f *Aead) EncryptString(plain string) (string, error) {
+	data, err := f.Encrypt([]byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+//decrypt raw url base64 format string
+func (f *Aead) DecryptString(encStr string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encStr)
+	if err != nil {
+		return "", err
+	}
+	plain, err := f.Decrypt(data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+//check string is aead key mode format, by encoding and header only
+func (f *Aead) IsAeadString(encStr string) bool {
+	data, err := base64.RawURLEncoding.DecodeString(encStr)
+	if err != nil || len(data) < 2 || data[0] != AeadVersionOfKey {
+		return false
+	}
+	switch data[1] {
+	case AeadAlgOfAesGcm, AeadAlgOfXChaCha20:
+		return true
+	}
+	return false
+}
+
+//migrate legacy `SimpleEncrypt` value into aead format
+func (f *Aead) MigrateSimple(simple *SimpleEncrypt, legacyStr string) (string, error) {
+	if simple == nil || legacyStr == "" {
+		return "", errors.New("invalid parameter")
+	}
+	plain, err := simple.Decrypt(legacyStr)
+	if err != nil {
+		return "", err
+	}
+	return f.EncryptString(plain)
+}
+
+//decrypt aead string, fall back to legacy `SimpleEncrypt`
+//only fall back if value is not aead format, tampered aead value will fail
+//return plain, is legacy or not, error
+func (f *Aead) DecryptCompat(simple *SimpleEncrypt, encStr string) (string, bool, error) {
+	if f.IsAeadString(encStr) || simple == nil {
+		plain, err := f.DecryptString(encStr)
+		if err != nil {
+			return "", false, err
+		}
+		return plain, false, nil
+	}
+	plain, err := simple.Decrypt(encStr)
+	if err != nil {
+		return "", false, err
+	}
+	return plain, true, nil
+}
+
+////////////////
+//private func
+////////////////
+
+//derive key from password by params
+func (f *Aead) deriveKey(password, salt []byte, kdf int, params *PasswordParams) ([]byte, error) {
+	if len(password) <= 0 || len(salt) <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+	switch kdf {
+	case AeadKdfOfArgon2id:
+		return argon2.IDKey(password, salt, params.Argon2Time, params.Argon2Memory,
+			params.Argon2Threads, AeadKeySize), nil
+	case AeadKdfOfScrypt:
+		return scrypt.Key(password, salt, 1 << uint(params.ScryptLogN), params.ScryptR, params.ScryptP, AeadKeySize)
+	}
+	return nil, errors.New("invalid kdf")
+}
+
+//encode kdf params of header
+func (f *Aead) encodeKdfParams(kdf int, params *PasswordParams) []byte {
+	switch kdf {
+	case AeadKdfOfArgon2id:
+		result := []byte{byte(params.Argon2Time), 0, 0, 0, 0, params.Argon2Threads}
+		binary.BigEndian.PutUint32(result[1:5], params.Argon2Memory)
+		return result
+	case AeadKdfOfScrypt:
+		return []byte{byte(params.ScryptLogN), byte(params.ScryptR), byte(params.ScryptP)}
+	}
+	return nil
+}
+
+//decode kdf params of header, params checked in accepted range
+//return params, params size, error
+func (f *Aead) decodeKdfParams(kdf int, data []byte) (*PasswordParams, int, error) {
+	params := &PasswordParams{}
+	switch kdf {
+	case AeadKdfOfArgon2id:
+		if len(data) < 6 {
+			return nil, 0, errors.New("ciphertext too short")
+		}
+		params.Argon2Time = uint32(data[0])
+		params.Argon2Memory = binary.BigEndian.Uint32(data[1:5])
+		params.Argon2Threads = data[5]
+		if err := checkPasswordParams(PasswordAlgOfArgon2id, params); err != nil {
+			return nil, 0, err
+		}
+		return params, 6, nil
+	case AeadKdfOfScrypt:
+		if len(data) < 3 {
+			return nil, 0, errors.New("ciphertext too short")
+		}
+		params.ScryptLogN, params.ScryptR, params.ScryptP = int(data[0]), int(data[1]), int(data[2])
+		if err := checkPasswordParams(PasswordAlgOfScrypt, params); err != nil {
+			return nil, 0, err
+		}
+		return params, 3, nil
+	}
+	return nil, 0, errors.New("invalid kdf")
+}
+
+//seal data with header as additional data
+func (f *Aead) seal(header, key []byte, alg int, plain []byte, aad ...[]byte) ([]byte, error) {
+	aead, err := f.newCipher(key, alg)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	result := make([]byte, 0, len(header) + len(nonce) + len(plain) + aead.Overhead())
+	result = append(result, header...)
+	result = append(result, nonce...)
+	return aead.Seal(result, nonce, plain, f.additionalData(header, aad...)), nil
+}
+
+//open data with header as additional data
+func (f *Aead) open(header, key []byte, alg int, data []byte, aad ...[]byte) ([]byte, error) {
+	aead, err := f.newCipher(key, alg)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize + aead.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:nonceSize], data[nonceSize:], f.additionalData(header, aad...))
+}
+
+//create aead cipher
+func (f *Aead) newCipher(key []byte, alg int) (cipher.AEAD, error) {
+	switch alg {
+	case AeadAlgOfAesGcm:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AeadAlgOfXChaCha20:
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, errors.New("invalid algorithm")
+}
+
+//join header and optional additional data
+func (f *Aead) additionalData(header []byte, aad ...[]byte) []byte {
+	result := append([]byte{}, header...)
+	if aad != nil && len(aad) > 0 {
+		result = append(result, aad[0]...)
+	}
+	return result
+}
@@ -0,0 +1,194 @@
+package crypt
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+ * file checksum and detached signature
+ * - SHA-256 and BLAKE2b-256 checksum of stream or file
+ * - detached signature of file digest, signed by `Rsa` key
+ *
+ * detached signature is json format, signed message is:
+ * tinycells-sig-v1\n<hash alg>\n<hex digest>\n<create at>
+ */
+
+//checksum algorithm
+const (
+	ChecksumAlgOfSha256 = "sha256"
+	ChecksumAlgOfBlake2b = "blake2b"
+)
+
+//detached signature
+const (
+	SignatureVersion = 1
+	SignatureAlgOfRsaPss = "RSA-PSS-SHA256"
+)
+
+//detached signature info
+type DetachedSignature struct {
+	Version int `json:"version"`
+	Alg string `json:"alg"`
+	HashAlg string `json:"hashAlg"`
+	Digest string `json:"digest"` //hex format
+	Signature string `json:"signature"` //std base64 format
+	CreateAt int64 `json:"createAt"`
+}
+
+//face info
+type Checksum struct {
+	alg string
+}
+
+//construct
+func NewChecksum(algs ...string) *Checksum {
+	this := &Checksum{
+		alg: ChecksumAlgOfSha256,
+	}
+	if algs != nil && len(algs) > 0 {
+		this.SetAlg(algs[0])
+	}
+	return this
+}
+
+//set default algorithm
+func (f *Checksum) SetAlg(alg string) error {
+	if _, err := f.newHash(alg); err != nil {
+		return err
+	}
+	f.alg = alg
+	return nil
+}
+
+//get checksum of stream, hex format
+//alg is optional, default is algorithm of `SetAlg`
+func (f *Checksum) Sum(reader io.Reader, algs ...string) (string, error) {
+	h, err := f.newHash(f.getAlg(algs...))
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//get checksum of file, hex format
+func (f *Checksum) SumFile(filePath string, algs ...string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return f.Sum(file, algs...)
+}
+
+//verify checksum of file
+func (f *Checksum) VerifyFile(filePath, expected string, algs ...string) (bool, error) {
+	sum, err := f.SumFile(filePath, algs...)
+	if err != nil {
+		return false, err
+	}
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	return subtle.ConstantTimeCompare([]byte(sum), []byte(expected)) == 1, nil
+}
+
+//sign file digest by rsa private key
+//return json format detached signature
+func (f *Checksum) SignFile(filePath string, rsa *Rsa) ([]byte, error) {
+	if rsa == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	hashAlg := f.getAlg()
+	digest, err := f.SumFile(filePath, hashAlg)
+	if err != nil {
+		return nil, err
+	}
+	sig := &DetachedSignature{
+		Version: SignatureVersion,
+		Alg: SignatureAlgOfRsaPss,
+		HashAlg: hashAlg,
+		Digest: digest,
+		CreateAt: time.Now().Unix(),
+	}
+	signData, err := rsa.Sign(f.signMessage(sig))
+	if err != nil {
+		return nil, err
+	}
+	sig.Signature = base64.StdEncoding.EncodeToString(signData)
+	return json.Marshal(sig)
+}
+
+//verify file with detached signature by rsa public key
+func (f *Checksum) VerifyFileSignature(filePath string, sigBytes []byte, rsa *Rsa) error {
+	if rsa == nil || len(sigBytes) <= 0 {
+		return errors.New("invalid parameter")
+	}
+	sig := &DetachedSignature{}
+	if err := json.Unmarshal(sigBytes, sig); err != nil {
+		return err
+	}
+	if sig.Version != SignatureVersion || sig.Alg != SignatureAlgOfRsaPss {
+		return errors.New("unsupported signature")
+	}
+
+	//verify signature of digest
+	signData, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return err
+	}
+	if err = rsa.Verify(f.signMessage(sig), signData); err != nil {
+		return err
+	}
+
+	//verify digest of file
+	ok, err := f.VerifyFile(filePath, sig.Digest, sig.HashAlg)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("file digest not matched")
+	}
+	return nil
+}
+
+////////////////
+//private func
+////////////////
+
+//gen signed message
+func (f *Checksum) signMessage(sig *DetachedSignature) []byte {
+	return []byte(fmt.Sprintf("tinycells-sig-v%d\n%s\n%s\n%d",
+		sig.Version, sig.HashAlg, sig.Digest, sig.CreateAt))
+}
+
+//get algorithm
+func (f *Checksum) getAlg(algs ...string) string {
+	if algs != nil && len(algs) > 0 && algs[0] != "" {
+		return algs[0]
+	}
+	return f.alg
+}
+
+//create hash
+func (f *Checksum) newHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case ChecksumAlgOfSha256:
+		return sha256.New(), nil
+	case ChecksumAlgOfBlake2b:
+		return blake2b.New256(nil)
+	}
+	return nil, errors.New("invalid checksum algorithm")
+}
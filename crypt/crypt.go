@@ -5,14 +5,31 @@ type Crypt struct {
 	simple *SimpleEncrypt
 	jwt *Jwt
 	rsa *Rsa
+	aead *Aead
+	ecc *Ecc
+	password *Password
+	signer *HmacSigner
+	keyRing *KeyRing
+	otp *OTP
+	stream *Stream
+	checksum *Checksum
 }
 
 //construct
 func NewCrypt() *Crypt {
+	keyRing, _ := NewKeyRing()
 	this := &Crypt{
 		simple: NewSimpleEncrypt(),
 		jwt: NewJwt(),
 		rsa: NewRsa(),
+		aead: NewAead(),
+		ecc: NewEcc(),
+		password: NewPassword(),
+		signer: NewHmacSigner(),
+		keyRing: keyRing,
+		otp: NewOTP(),
+		stream: NewStream(),
+		checksum: NewChecksum(),
 	}
 	return this
 }
@@ -30,3 +47,35 @@ func (f *Crypt) GetSimple() *SimpleEncrypt {
 	return f.simple
 }
 
+func (f *Crypt) GetAead() *Aead {
+	return f.aead
+}
+
+func (f *Crypt) GetEcc() *Ecc {
+	return f.ecc
+}
+
+func (f *Crypt) GetPassword() *Password {
+	return f.password
+}
+
+func (f *Crypt) GetSigner() *HmacSigner {
+	return f.signer
+}
+
+
+func (f *Crypt) GetKeyRing() *KeyRing {
+	return f.keyRing
+}
+
+func (f *Crypt) GetOTP() *OTP {
+	return f.otp
+}
+
+func (f *Crypt) GetStream() *Stream {
+	return f.stream
+}
+
+func (f *Crypt) GetChecksum() *Checksum {
+	return f.checksum
+}
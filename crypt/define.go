@@ -3,6 +3,4 @@ package crypt
 const (
 	SimpleEncryptKeySize = 32
 	SimpleEncryptKeyDefault = "1Q0/oHmv!nVmpA#9$C"
-
-	SecretKeyOfJwt = "Q1%x&9/jS1j%3omv!nVmA#lM"
 )
@@ -0,0 +1,273 @@
+package crypt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"sync"
+)
+
+/*
+ * elliptic curve algorithm
+ * - Ed25519 and ECDSA P-256 sign/verify
+ * - PKCS#8 and PKIX pem import/export
+ * - X25519 key exchange, shared key usable by `Aead`
+ */
+
+//pem block type
+const (
+	PemTypeOfEcPrivateKey = "EC PRIVATE KEY"
+)
+
+//x25519 key size
+const (
+	X25519KeySize = 32
+)
+
+//face info
+type Ecc struct {
+	privateKey crypto.PrivateKey //ed25519.PrivateKey or *ecdsa.PrivateKey
+	publicKey crypto.PublicKey //ed25519.PublicKey or *ecdsa.PublicKey
+	sync.RWMutex
+}
+
+//construct
+func NewEcc() *Ecc {
+	this := &Ecc{}
+	return this
+}
+
+//gen ed25519 key
+//return prvKey(PKCS#8), pubKey, error
+func (f *Ecc) GenEd25519Key() ([]byte, []byte, error) {
+	pub, prv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.marshalKeyPair(prv, pub)
+}
+
+//gen ecdsa P-256 key
+//return prvKey(PKCS#8), pubKey, error
+func (f *Ecc) GenEcdsaKey() ([]byte, []byte, error) {
+	prv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.marshalKeyPair(prv, &prv.PublicKey)
+}
+
+//set key
+//one of keys can be empty, like public key only for verify
+func (f *Ecc) SetKey(pubKey, prvKey string) error {
+	var (
+		publicKey crypto.PublicKey
+		privateKey crypto.PrivateKey
+		err error
+	)
+	//check
+	if pubKey == "" && prvKey == "" {
+		return errors.New("invalid parameter")
+	}
+
+	//parse keys
+	if prvKey != "" {
+		privateKey, err = f.ParsePrivateKey([]byte(prvKey))
+		if err != nil {
+			return err
+		}
+		publicKey = f.getPublic(privateKey)
+	}
+	if pubKey != "" {
+		publicKey, err = f.ParsePublicKey([]byte(pubKey))
+		if err != nil {
+			return err
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.privateKey = privateKey
+	f.publicKey = publicKey
+	return nil
+}
+
+//get private key which set by `SetKey`
+func (f *Ecc) GetPrivateKey() crypto.PrivateKey {
+	f.RLock()
+	defer f.RUnlock()
+	return f.privateKey
+}
+
+//get public key which set by `SetKey`
+func (f *Ecc) GetPublicKey() crypto.PublicKey {
+	f.RLock()
+	defer f.RUnlock()
+	return f.publicKey
+}
+
+//parse pem format private key
+//support PKCS#8 and SEC1 format
+func (f *Ecc) ParsePrivateKey(keyBytes []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("private key error")
+	}
+	if block.Type == PemTypeOfEcPrivateKey {
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case ed25519.PrivateKey, *ecdsa.PrivateKey:
+		return key, nil
+	}
+	return nil, errors.New("not ed25519 or ecdsa private key")
+}
+
+//parse pem format public key
+func (f *Ecc) ParsePublicKey(keyBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("public key error")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, errors.New("not ed25519 or ecdsa public key")
+}
+
+//sign data, use key set by `SetKey`
+//ecdsa signature is ASN.1 format of sha256 digest
+func (f *Ecc) Sign(data []byte) ([]byte, error) {
+	prv := f.GetPrivateKey()
+	if prv == nil {
+		return nil, errors.New("private key not set")
+	}
+	return f.SignWithKey(data, prv)
+}
+
+//verify signature, use key set by `SetKey`
+func (f *Ecc) Verify(data, signData []byte) error {
+	pub := f.GetPublicKey()
+	if pub == nil {
+		return errors.New("public key not set")
+	}
+	return f.VerifyWithKey(data, signData, pub)
+}
+
+//sign data with assigned private key
+func (f *Ecc) SignWithKey(data []byte, prv crypto.PrivateKey) ([]byte, error) {
+	switch key := prv.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, data), nil
+	case *ecdsa.PrivateKey:
+		hashed := sha256.Sum256(data)
+		return ecdsa.SignASN1(rand.Reader, key, hashed[:])
+	}
+	return nil, errors.New("unsupported private key type")
+}
+
+//verify signature with assigned public key
+func (f *Ecc) VerifyWithKey(data, signData []byte, pub crypto.PublicKey) error {
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, data, signData) {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		hashed := sha256.Sum256(data)
+		if ecdsa.VerifyASN1(key, hashed[:], signData) {
+			return nil
+		}
+	default:
+		return errors.New("unsupported public key type")
+	}
+	return errors.New("signature verify failed")
+}
+
+//gen x25519 key pair
+//return prvKey, pubKey, error
+func (f *Ecc) GenX25519Key() ([]byte, []byte, error) {
+	prv := make([]byte, X25519KeySize)
+	if _, err := rand.Read(prv); err != nil {
+		return nil, nil, err
+	}
+	pub, err := curve25519.X25519(prv, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prv, pub, nil
+}
+
+//gen shared key by x25519 and hkdf-sha256
+//info used for bind key usage, both sides should use same info
+func (f *Ecc) SharedKey(prvKey, peerPubKey, info []byte) ([]byte, error) {
+	if len(prvKey) != X25519KeySize || len(peerPubKey) != X25519KeySize {
+		return nil, errors.New("invalid parameter")
+	}
+	secret, err := curve25519.X25519(prvKey, peerPubKey)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, AeadKeySize)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, secret, nil, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+//gen aead with x25519 shared key
+func (f *Ecc) SharedAead(prvKey, peerPubKey, info []byte) (*Aead, error) {
+	key, err := f.SharedKey(prvKey, peerPubKey, info)
+	if err != nil {
+		return nil, err
+	}
+	return NewAead(key), nil
+}
+
+////////////////
+//private func
+////////////////
+
+//get public key of private key
+func (f *Ecc) getPublic(prv crypto.PrivateKey) crypto.PublicKey {
+	switch key := prv.(type) {
+	case ed25519.PrivateKey:
+		return key.Public()
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey
+	}
+	return nil
+}
+
+//marshal key pair as pem
+func (f *Ecc) marshalKeyPair(prv crypto.PrivateKey, pub crypto.PublicKey) ([]byte, []byte, error) {
+	prvDer, err := x509.MarshalPKCS8PrivateKey(prv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubDer, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	prvKey := pem.EncodeToMemory(&pem.Block{Type: PemTypeOfPrivateKey, Bytes: prvDer})
+	pubKey := pem.EncodeToMemory(&pem.Block{Type: PemTypeOfPublicKey, Bytes: pubDer})
+	return prvKey, pubKey, nil
+}
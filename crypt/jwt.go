@@ -1,69 +1,282 @@
 package crypt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"github.com/andyzhou/tinycells/util"
 	"github.com/dgrijalva/jwt-go"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+ * jwt encode and decode
+ * - stateless and concurrent safe
+ * - support HS256, RS256, ES256 and EdDSA
+ * - kid based key set for rotation
+ * - registered claims validation with clock skew
+ */
+
+//inter macro define
+const (
+	JwtHeaderOfKid = "kid"
+	JwtDefaultKid = ""
 )
 
 //face info
 type Jwt struct {
-	secret string `secret key string`
-	token *jwt.Token `jwt token instance`
-	claims jwt.MapClaims `jwt claims object`
+	keyMap map[string]*JwtKey //kid -> *JwtKey
+	activeKid string
+	validation *JwtValidation
+	util.Util
+	sync.RWMutex
 }
 
 //construct
+//no key installed without security key, call `SetKey` or `AddKey` before use
 func NewJwt(securityKeys ...string) *Jwt {
-	securityKey := SecretKeyOfJwt
-	if securityKeys != nil && len(securityKeys) > 0 {
-		securityKey = securityKeys[0]
-	}
 	this := &Jwt{
-		secret: securityKey,
-		token:jwt.New(jwt.SigningMethodHS256),
-		claims:make(jwt.MapClaims),
+		keyMap: map[string]*JwtKey{},
+		activeKid: JwtDefaultKid,
+		validation: &JwtValidation{},
+	}
+	if securityKeys != nil && len(securityKeys) > 0 && securityKeys[0] != "" {
+		this.keyMap[JwtDefaultKid] = NewJwtHmacKey(JwtDefaultKid, []byte(securityKeys[0]))
 	}
 	return this
 }
 
-//encode
+//encode map claims
 func (j *Jwt) Encode(input map[string]interface{}) (string, error) {
-	j.claims = input
-	j.token.Claims = j.claims
-	result, err := j.token.SignedString([]byte(j.secret))
-	if err != nil {
-		return "", err
+	if input == nil {
+		return "", errors.New("invalid parameter")
 	}
-	return result, nil
+	return j.sign(jwt.MapClaims(input))
 }
 
-//decode
+//decode map claims, registered claims will be validated
 func (j *Jwt) Decode(input string) (map[string]interface{}, error) {
-	//parse input string
-	token, err := jwt.Parse(input, j.getValidationKey)
+	claims := jwt.MapClaims{}
+	if err := j.parse(input, claims); err != nil {
+		return nil, err
+	}
+
+	//convert registered claims
+	jwtClaims := &JwtClaims{}
+	data, err := json.Marshal(claims)
 	if err != nil {
 		return nil, err
 	}
-	//check header
-	if jwt.SigningMethodHS256.Alg() != token.Header["alg"] {
-		return nil, errors.New("header error")
+	if err = json.Unmarshal(data, jwtClaims); err != nil {
+		return nil, ErrJwtInvalidToken
+	}
+	if err = j.validate(jwtClaims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+//encode typed claims
+func (j *Jwt) EncodeClaims(claims IJwtClaims) (string, error) {
+	if claims == nil {
+		return "", errors.New("invalid parameter")
+	}
+	return j.sign(claims)
+}
+
+//decode into typed claims, registered claims will be validated
+func (j *Jwt) DecodeClaims(input string, claims IJwtClaims) error {
+	if claims == nil {
+		return errors.New("invalid parameter")
+	}
+	if err := j.parse(input, claims); err != nil {
+		return err
+	}
+	return j.validate(claims.GetJwtClaims())
+}
+
+//gen registered claims with ttl
+//issuer and audience filled from validation option
+func (j *Jwt) GenClaims(subject string, ttl time.Duration) *JwtClaims {
+	now := j.Now().Unix()
+	claims := &JwtClaims{
+		Subject: subject,
+		IssuedAt: now,
+		NotBefore: now,
+		Id: j.genJti(),
+	}
+	if ttl > 0 {
+		claims.ExpiresAt = now + int64(ttl / time.Second)
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	j.RLock()
+	defer j.RUnlock()
+	claims.Issuer = j.validation.Issuer
+	if j.validation.Audience != "" {
+		claims.Audience = JwtAudience{j.validation.Audience}
+	}
+	return claims
+}
+
+//set validation option
+func (j *Jwt) SetValidation(opt *JwtValidation) error {
+	if opt == nil {
+		return errors.New("invalid parameter")
 	}
-	return nil, errors.New("invalid token")
+	j.Lock()
+	defer j.Unlock()
+	j.validation = opt
+	return nil
 }
 
-//set key
+//set default hmac key
 func (j *Jwt) SetKey(key string) error {
 	if key == "" {
 		return errors.New("invalid parameter")
 	}
-	j.secret = key
+	j.Lock()
+	defer j.Unlock()
+	j.keyMap[JwtDefaultKid] = NewJwtHmacKey(JwtDefaultKid, []byte(key))
+	return nil
+}
+
+//add key into key set
+//old keys still can verify token until removed
+func (j *Jwt) AddKey(key *JwtKey) error {
+	if key == nil || key.VerifyKey == nil || key.GetMethod() == nil {
+		return errors.New("invalid parameter")
+	}
+	if !key.checkCurve() {
+		return errors.New("ES256 key should be P-256 curve")
+	}
+	j.Lock()
+	defer j.Unlock()
+	j.keyMap[key.Kid] = key
+	return nil
+}
+
+//remove key from key set
+func (j *Jwt) RemoveKey(kid string) error {
+	j.Lock()
+	defer j.Unlock()
+	if kid == j.activeKid {
+		return errors.New("can't remove active key")
+	}
+	delete(j.keyMap, kid)
+	return nil
+}
+
+//set active key for signing
+func (j *Jwt) SetActiveKey(kid string) error {
+	j.Lock()
+	defer j.Unlock()
+	key, ok := j.keyMap[kid]
+	if !ok || key.SignKey == nil {
+		return ErrJwtUnknownKey
+	}
+	j.activeKid = kid
+	return nil
+}
+
+//get key by kid
+func (j *Jwt) GetKey(kid string) *JwtKey {
+	j.RLock()
+	defer j.RUnlock()
+	v, ok := j.keyMap[kid]
+	if ok {
+		return v
+	}
+	return nil
+}
+
+//gen jwks of all asymmetric keys
+func (j *Jwt) GenJwks() *Jwks {
+	jwks := &Jwks{
+		Keys: make([]*Jwk, 0),
+	}
+	j.RLock()
+	defer j.RUnlock()
+	for _, key := range j.keyMap {
+		jwk := key.GetJwk()
+		if jwk != nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	sort.Slice(jwks.Keys, func(m, n int) bool {
+		return jwks.Keys[m].Kid < jwks.Keys[n].Kid
+	})
+	return jwks
+}
+
+////////////////
+//private func
+////////////////
+
+//sign claims with active key
+func (j *Jwt) sign(claims jwt.Claims) (string, error) {
+	j.RLock()
+	key, ok := j.keyMap[j.activeKid]
+	j.RUnlock()
+	if !ok || key.SignKey == nil {
+		return "", ErrJwtUnknownKey
+	}
+	token := jwt.NewWithClaims(key.GetMethod(), claims)
+	if key.Kid != JwtDefaultKid {
+		token.Header[JwtHeaderOfKid] = key.Kid
+	}
+	return token.SignedString(key.SignKey)
+}
+
+//parse and verify token signature
+func (j *Jwt) parse(input string, claims jwt.Claims) error {
+	if input == "" {
+		return errors.New("invalid parameter")
+	}
+	parser := &jwt.Parser{
+		SkipClaimsValidation: true,
+	}
+	token, err := parser.ParseWithClaims(input, claims, j.getValidationKey)
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Inner != nil {
+			return ve.Inner
+		}
+		return err
+	}
+	if !token.Valid {
+		return ErrJwtInvalidToken
+	}
 	return nil
 }
 
-//get validate key
-func (j *Jwt) getValidationKey(*jwt.Token) (interface{}, error) {
-	return []byte(j.secret), nil
-}
\ No newline at end of file
+//validate registered claims
+func (j *Jwt) validate(claims *JwtClaims) error {
+	j.RLock()
+	opt := j.validation
+	j.RUnlock()
+	return claims.Validate(opt, j.Now().Unix())
+}
+
+//get validate key by kid header
+func (j *Jwt) getValidationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header[JwtHeaderOfKid].(string)
+	key := j.GetKey(kid)
+	if key == nil {
+		return nil, ErrJwtUnknownKey
+	}
+	//algorithm must match key, avoid alg confusion
+	if token.Method == nil || token.Method.Alg() != key.Alg {
+		return nil, ErrJwtInvalidAlg
+	}
+	return key.VerifyKey, nil
+}
+
+//gen random jwt id
+func (j *Jwt) genJti() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,136 @@
+package crypt
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+/*
+ * jwt typed claims and registered claims validation
+ */
+
+//jwt errors
+var (
+	ErrJwtExpired = errors.New("jwt token is expired")
+	ErrJwtNotValidYet = errors.New("jwt token is not valid yet")
+	ErrJwtIssuedInFuture = errors.New("jwt token issued in future")
+	ErrJwtInvalidIssuer = errors.New("jwt token issuer invalid")
+	ErrJwtInvalidAudience = errors.New("jwt token audience invalid")
+	ErrJwtMissingExpire = errors.New("jwt token missing expire time")
+	ErrJwtUnknownKey = errors.New("jwt token key not found")
+	ErrJwtInvalidAlg = errors.New("jwt token algorithm invalid")
+	ErrJwtInvalidToken = errors.New("invalid token")
+)
+
+//typed claims interface
+//custom claims should embed `JwtClaims`, like:
+//type UserClaims struct {
+//	crypt.JwtClaims
+//	Uid int64 `json:"uid"`
+//}
+type IJwtClaims interface {
+	Valid() error
+	GetJwtClaims() *JwtClaims
+}
+
+//audience, encode as string if only one
+type JwtAudience []string
+
+//registered claims
+type JwtClaims struct {
+	Issuer string `json:"iss,omitempty"`
+	Subject string `json:"sub,omitempty"`
+	Audience JwtAudience `json:"aud,omitempty"`
+	ExpiresAt int64 `json:"exp,omitempty"`
+	NotBefore int64 `json:"nbf,omitempty"`
+	IssuedAt int64 `json:"iat,omitempty"`
+	Id string `json:"jti,omitempty"`
+}
+
+//validation option of registered claims
+type JwtValidation struct {
+	Issuer string //required issuer, empty means not check
+	Audience string //required audience, empty means not check
+	Leeway time.Duration //clock skew
+	RequireExpire bool //exp claim must be set
+}
+
+//claims validation is done by `Jwt` with `JwtValidation`
+func (c *JwtClaims) Valid() error {
+	return nil
+}
+
+//get registered claims
+func (c *JwtClaims) GetJwtClaims() *JwtClaims {
+	return c
+}
+
+//check audience
+func (c *JwtClaims) HasAudience(aud string) bool {
+	for _, v := range c.Audience {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+//validate registered claims with option and current unix time
+func (c *JwtClaims) Validate(opt *JwtValidation, now int64) error {
+	var (
+		leeway int64
+	)
+	if opt == nil {
+		opt = &JwtValidation{}
+	}
+	leeway = int64(opt.Leeway / time.Second)
+
+	//check time claims
+	if c.ExpiresAt <= 0 && opt.RequireExpire {
+		return ErrJwtMissingExpire
+	}
+	if c.ExpiresAt > 0 && now > c.ExpiresAt + leeway {
+		return ErrJwtExpired
+	}
+	if c.NotBefore > 0 && now + leeway < c.NotBefore {
+		return ErrJwtNotValidYet
+	}
+	if c.IssuedAt > 0 && now + leeway < c.IssuedAt {
+		return ErrJwtIssuedInFuture
+	}
+
+	//check issuer and audience
+	if opt.Issuer != "" && c.Issuer != opt.Issuer {
+		return ErrJwtInvalidIssuer
+	}
+	if opt.Audience != "" && !c.HasAudience(opt.Audience) {
+		return ErrJwtInvalidAudience
+	}
+	return nil
+}
+
+//encode audience
+func (a JwtAudience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+//decode audience, support string or string array
+func (a *JwtAudience) UnmarshalJSON(data []byte) error {
+	var (
+		single string
+		multi []string
+	)
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = JwtAudience{single}
+		return nil
+	}
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
@@ -0,0 +1,227 @@
+package crypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"math/big"
+)
+
+/*
+ * jwt signing keys, EdDSA signing method and jwks
+ */
+
+//algorithm define
+const (
+	JwtAlgOfHS256 = "HS256"
+	JwtAlgOfRS256 = "RS256"
+	JwtAlgOfES256 = "ES256"
+	JwtAlgOfEdDSA = "EdDSA"
+)
+
+//EdDSA signing method instance
+var (
+	SigningMethodEdDSA = &SigningMethodEd25519{}
+)
+
+//jwt key info
+type JwtKey struct {
+	Kid string
+	Alg string
+	SignKey interface{} //[]byte, *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+	VerifyKey interface{} //[]byte, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+}
+
+//jwk info
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+}
+
+//jwks document
+type Jwks struct {
+	Keys []*Jwk `json:"keys"`
+}
+
+//EdDSA signing method, jwt-go v3 not support it
+type SigningMethodEd25519 struct {}
+
+func init() {
+	jwt.RegisterSigningMethod(JwtAlgOfEdDSA, func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+//create hmac key
+func NewJwtHmacKey(kid string, secret []byte) *JwtKey {
+	return &JwtKey{
+		Kid: kid,
+		Alg: JwtAlgOfHS256,
+		SignKey: secret,
+		VerifyKey: secret,
+	}
+}
+
+//create rsa key, RS256
+func NewJwtRsaKey(kid string, prvKey *rsa.PrivateKey) *JwtKey {
+	return &JwtKey{
+		Kid: kid,
+		Alg: JwtAlgOfRS256,
+		SignKey: prvKey,
+		VerifyKey: &prvKey.PublicKey,
+	}
+}
+
+//create ecdsa key, ES256
+func NewJwtEcdsaKey(kid string, prvKey *ecdsa.PrivateKey) *JwtKey {
+	return &JwtKey{
+		Kid: kid,
+		Alg: JwtAlgOfES256,
+		SignKey: prvKey,
+		VerifyKey: &prvKey.PublicKey,
+	}
+}
+
+//create ed25519 key, EdDSA
+func NewJwtEd25519Key(kid string, prvKey ed25519.PrivateKey) *JwtKey {
+	return &JwtKey{
+		Kid: kid,
+		Alg: JwtAlgOfEdDSA,
+		SignKey: prvKey,
+		VerifyKey: prvKey.Public(),
+	}
+}
+
+//create verify only key by public key
+func NewJwtVerifyKey(kid string, pubKey interface{}) (*JwtKey, error) {
+	key := &JwtKey{
+		Kid: kid,
+		VerifyKey: pubKey,
+	}
+	switch pubKey.(type) {
+	case *rsa.PublicKey:
+		key.Alg = JwtAlgOfRS256
+	case *ecdsa.PublicKey:
+		key.Alg = JwtAlgOfES256
+	case ed25519.PublicKey:
+		key.Alg = JwtAlgOfEdDSA
+	default:
+		return nil, errors.New("unsupported public key type")
+	}
+	return key, nil
+}
+
+//get signing method
+func (k *JwtKey) GetMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Alg)
+}
+
+//get jwk of public key, nil for hmac key
+func (k *JwtKey) GetJwk() *Jwk {
+	jwk := &Jwk{
+		Kid: k.Kid,
+		Alg: k.Alg,
+		Use: "sig",
+	}
+	switch pub := k.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = k.encodeBase64(pub.N.Bytes())
+		jwk.E = k.encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = k.encodeBase64(k.padBytes(pub.X.Bytes(), size))
+		jwk.Y = k.encodeBase64(k.padBytes(pub.Y.Bytes(), size))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = k.encodeBase64(pub)
+	default:
+		return nil
+	}
+	return jwk
+}
+
+//sign for EdDSA
+func (m *SigningMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	prvKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	if len(prvKey) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKey
+	}
+	signature := ed25519.Sign(prvKey, []byte(signingString))
+	return jwt.EncodeSegment(signature), nil
+}
+
+//verify for EdDSA
+func (m *SigningMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	pubKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKey
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pubKey, []byte(signingString), sig) {
+		return errors.New("ed25519 verification failed")
+	}
+	return nil
+}
+
+//get algorithm
+func (m *SigningMethodEd25519) Alg() string {
+	return JwtAlgOfEdDSA
+}
+
+//encode jwks document
+func (j *Jwks) Encode() ([]byte, error) {
+	return json.Marshal(j)
+}
+
+////////////////
+//private func
+////////////////
+
+//encode as raw url base64
+func (k *JwtKey) encodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+//left pad bytes with zero
+func (k *JwtKey) padBytes(data []byte, size int) []byte {
+	if len(data) >= size {
+		return data
+	}
+	result := make([]byte, size)
+	copy(result[size-len(data):], data)
+	return result
+}
+
+//check curve of ES256
+func (k *JwtKey) checkCurve() bool {
+	switch key := k.VerifyKey.(type) {
+	case *ecdsa.PublicKey:
+		return key.Curve == elliptic.P256()
+	}
+	return true
+}
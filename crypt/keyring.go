@@ -0,0 +1,473 @@
+package crypt
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * key ring with envelope encryption
+ * - data encryption keys(DEK) are versioned and wrapped by master key
+ * - master key loaded from file or derived from env secret
+ * - encrypt with active DEK, decrypt with any historic DEK by embedded key id
+ *
+ * ciphertext format:
+ * [key id(4 bytes, big endian)][aead ciphertext]
+ * key id is authenticated as additional data
+ */
+
+//default value
+const (
+	KeyRingKeyIdSize = 4
+	KeyRingEnvSaltDefault = "tinycells.keyring"
+)
+
+//keyring errors
+var (
+	ErrKeyRingNoMaster = errors.New("keyring master key not set")
+	ErrKeyRingNoActive = errors.New("keyring active key not set")
+	ErrKeyRingUnknownKey = errors.New("keyring key id unknown")
+)
+
+//wrapped data encryption key
+type WrappedKey struct {
+	Id uint32 `json:"id"`
+	Key string `json:"key"` //raw url base64 of wrapped key
+	CreateAt int64 `json:"createAt"`
+}
+
+//persisted key ring
+type KeyRingData struct {
+	ActiveId uint32 `json:"activeId"`
+	Keys []*WrappedKey `json:"keys"`
+}
+
+//face info
+type KeyRing struct {
+	master *Aead
+	wrappedMap map[uint32]*WrappedKey
+	keyMap map[uint32]*Aead //key id -> unwrapped DEK
+	activeId uint32
+	alg int
+	sync.RWMutex
+}
+
+//construct
+//return error if master key invalid
+func NewKeyRing(masterKeys ...[]byte) (*KeyRing, error) {
+	this := &KeyRing{
+		wrappedMap: map[uint32]*WrappedKey{},
+		keyMap: map[uint32]*Aead{},
+		alg: AeadAlgOfAesGcm,
+	}
+	if masterKeys != nil && len(masterKeys) > 0 {
+		if err := this.SetMasterKey(masterKeys[0]); err != nil {
+			return nil, err
+		}
+	}
+	return this, nil
+}
+
+//set master key, should be 32 bytes
+//loaded keys will be unwrapped again by new master,
+//master not changed if unwrap failed
+func (f *KeyRing) SetMasterKey(key []byte) error {
+	master := NewAead()
+	if err := master.SetKey(key); err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	keyMap, err := f.unwrapAll(master, f.wrappedMap)
+	if err != nil {
+		return err
+	}
+	f.master = master
+	f.keyMap = keyMap
+	return nil
+}
+
+//load master key from file
+//file content is raw 32 bytes, hex or base64 format
+func (f *KeyRing) LoadMasterKeyFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := f.decodeMasterKey(data)
+	if err != nil {
+		return fmt.Errorf("master key file %v invalid, err:%v", path, err)
+	}
+	return f.SetMasterKey(key)
+}
+
+//derive master key from env secret by Argon2id
+//salt is optional, default is `KeyRingEnvSaltDefault`
+func (f *KeyRing) LoadMasterKeyEnv(envName string, salts ...string) error {
+	secret := os.Getenv(envName)
+	if secret == "" {
+		return fmt.Errorf("env %v not set", envName)
+	}
+	salt := KeyRingEnvSaltDefault
+	if salts != nil && len(salts) > 0 && salts[0] != "" {
+		salt = salts[0]
+	}
+	key, err := NewAead().DeriveKey([]byte(secret), []byte(salt), AeadKdfOfArgon2id)
+	if err != nil {
+		return err
+	}
+	return f.SetMasterKey(key)
+}
+
+//set algorithm for new encryption
+func (f *KeyRing) SetAlg(alg int) error {
+	if alg != AeadAlgOfAesGcm && alg != AeadAlgOfXChaCha20 {
+		return errors.New("invalid algorithm")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.alg = alg
+	for _, dek := range f.keyMap {
+		dek.SetAlg(alg)
+	}
+	return nil
+}
+
+//rotate, gen new DEK and make it active
+//return new key id
+func (f *KeyRing) Rotate() (uint32, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.master == nil {
+		return 0, ErrKeyRingNoMaster
+	}
+
+	//gen new key
+	dek := NewAead()
+	key, err := dek.GenKey()
+	if err != nil {
+		return 0, err
+	}
+	dek.SetKey(key)
+	dek.SetAlg(f.alg)
+
+	//wrap by master
+	id := f.nextId()
+	wrapped, err := f.master.Encrypt(key, f.keyIdBytes(id))
+	if err != nil {
+		return 0, err
+	}
+	f.wrappedMap[id] = &WrappedKey{
+		Id: id,
+		Key: base64.RawURLEncoding.EncodeToString(wrapped),
+		CreateAt: time.Now().Unix(),
+	}
+	f.keyMap[id] = dek
+	f.activeId = id
+	return id, nil
+}
+
+//set active key id
+func (f *KeyRing) SetActive(id uint32) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.keyMap[id]; !ok {
+		return ErrKeyRingUnknownKey
+	}
+	f.activeId = id
+	return nil
+}
+
+//get active key id
+func (f *KeyRing) GetActive() uint32 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.activeId
+}
+
+//get all key ids, sorted
+func (f *KeyRing) GetKeyIds() []uint32 {
+	f.RLock()
+	defer f.RUnlock()
+	result := make([]uint32, 0, len(f.wrappedMap))
+	for id := range f.wrappedMap {
+		result = append(result, id)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+//remove historic key
+//data encrypted by it can not be decrypted any more
+func (f *KeyRing) RemoveKey(id uint32) error {
+	f.Lock()
+	defer f.Unlock()
+	if id == f.activeId {
+		return errors.New("can't remove active key")
+	}
+	delete(f.wrappedMap, id)
+	delete(f.keyMap, id)
+	return nil
+}
+
+//encrypt with active key
+func (f *KeyRing) Encrypt(plain []byte, aad ...[]byte) ([]byte, error) {
+	f.RLock()
+	id := f.activeId
+	dek, ok := f.keyMap[id]
+	f.RUnlock()
+	if !ok {
+		return nil, ErrKeyRingNoActive
+	}
+	header := f.keyIdBytes(id)
+	sealed, err := dek.Encrypt(plain, f.additionalData(header, aad...))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+//decrypt with key by embedded key id
+func (f *KeyRing) Decrypt(data []byte, aad ...[]byte) ([]byte, error) {
+	id, err := f.GetKeyId(data)
+	if err != nil {
+		return nil, err
+	}
+	f.RLock()
+	dek, ok := f.keyMap[id]
+	f.RUnlock()
+	if !ok {
+		return nil, ErrKeyRingUnknownKey
+	}
+	header := data[:KeyRingKeyIdSize]
+	return dek.Decrypt(data[KeyRingKeyIdSize:], f.additionalData(header, aad...))
+}
+
+//get embedded key id of ciphertext
+func (f *KeyRing) GetKeyId(data []byte) (uint32, error) {
+	if len(data) <= KeyRingKeyIdSize {
+		return 0, errors.New("ciphertext too short")
+	}
+	return binary.BigEndian.Uint32(data[:KeyRingKeyIdSize]), nil
+}
+
+//encrypt string, return raw url base64 format
+func (f *KeyRing) EncryptString(plain string) (string, error) {
+	data, err := f.Encrypt([]byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+//decrypt raw url base64 format string
+func (f *KeyRing) DecryptString(encStr string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encStr)
+	if err != nil {
+		return "", err
+	}
+	plain, err := f.Decrypt(data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+//re-encrypt ciphertext with active key
+//return original data if already encrypted by active key
+func (f *KeyRing) ReEncrypt(data []byte, aad ...[]byte) ([]byte, error) {
+	id, err := f.GetKeyId(data)
+	if err != nil {
+		return nil, err
+	}
+	if id == f.GetActive() {
+		return data, nil
+	}
+	plain, err := f.Decrypt(data, aad...)
+	if err != nil {
+		return nil, err
+	}
+	return f.Encrypt(plain, aad...)
+}
+
+//re-wrap all DEKs by new master key
+//data ciphertext is not changed
+func (f *KeyRing) Rewrap(newMasterKey []byte) error {
+	master := NewAead()
+	if err := master.SetKey(newMasterKey); err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+
+	//wrap into new map first, keep old one if failed
+	wrappedMap := map[uint32]*WrappedKey{}
+	for id, wrapped := range f.wrappedMap {
+		dek, ok := f.keyMap[id]
+		if !ok {
+			return ErrKeyRingUnknownKey
+		}
+		dek.RLock()
+		key := dek.key
+		dek.RUnlock()
+		data, err := master.Encrypt(key, f.keyIdBytes(id))
+		if err != nil {
+			return err
+		}
+		wrappedMap[id] = &WrappedKey{
+			Id: id,
+			Key: base64.RawURLEncoding.EncodeToString(data),
+			CreateAt: wrapped.CreateAt,
+		}
+	}
+	f.master = master
+	f.wrappedMap = wrappedMap
+	return nil
+}
+
+//export wrapped keys as json
+func (f *KeyRing) Export() ([]byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	data := &KeyRingData{
+		ActiveId: f.activeId,
+		Keys: make([]*WrappedKey, 0, len(f.wrappedMap)),
+	}
+	for _, wrapped := range f.wrappedMap {
+		data.Keys = append(data.Keys, wrapped)
+	}
+	sort.Slice(data.Keys, func(i, j int) bool {
+		return data.Keys[i].Id < data.Keys[j].Id
+	})
+	return json.Marshal(data)
+}
+
+//import wrapped keys from json
+//master key should be set before
+func (f *KeyRing) Import(jsonBytes []byte) error {
+	data := &KeyRingData{}
+	if err := json.Unmarshal(jsonBytes, data); err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	if f.master == nil {
+		return ErrKeyRingNoMaster
+	}
+	wrappedMap := map[uint32]*WrappedKey{}
+	for _, wrapped := range data.Keys {
+		wrappedMap[wrapped.Id] = wrapped
+	}
+	keyMap, err := f.unwrapAll(f.master, wrappedMap)
+	if err != nil {
+		return err
+	}
+	if _, ok := keyMap[data.ActiveId]; !ok && len(data.Keys) > 0 {
+		return ErrKeyRingNoActive
+	}
+	f.wrappedMap = wrappedMap
+	f.keyMap = keyMap
+	f.activeId = data.ActiveId
+	return nil
+}
+
+//save wrapped keys into file
+func (f *KeyRing) SaveFile(path string) error {
+	data, err := f.Export()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+//load wrapped keys from file
+func (f *KeyRing) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return f.Import(data)
+}
+
+////////////////
+//private func
+////////////////
+
+//unwrap all keys by master, without locker
+func (f *KeyRing) unwrapAll(master *Aead, wrappedMap map[uint32]*WrappedKey) (map[uint32]*Aead, error) {
+	keyMap := map[uint32]*Aead{}
+	for id, wrapped := range wrappedMap {
+		data, err := base64.RawURLEncoding.DecodeString(wrapped.Key)
+		if err != nil {
+			return nil, err
+		}
+		key, err := master.Decrypt(data, f.keyIdBytes(id))
+		if err != nil {
+			return nil, fmt.Errorf("unwrap key %v failed, err:%v", id, err)
+		}
+		dek := NewAead()
+		if err = dek.SetKey(key); err != nil {
+			return nil, err
+		}
+		dek.SetAlg(f.alg)
+		keyMap[id] = dek
+	}
+	return keyMap, nil
+}
+
+//get next key id, without locker
+func (f *KeyRing) nextId() uint32 {
+	var maxId uint32
+	for id := range f.wrappedMap {
+		if id > maxId {
+			maxId = id
+		}
+	}
+	return maxId + 1
+}
+
+//key id as bytes
+func (f *KeyRing) keyIdBytes(id uint32) []byte {
+	buf := make([]byte, KeyRingKeyIdSize)
+	binary.BigEndian.PutUint32(buf, id)
+	return buf
+}
+
+//join header and optional additional data
+func (f *KeyRing) additionalData(header []byte, aad ...[]byte) []byte {
+	result := append([]byte{}, header...)
+	if aad != nil && len(aad) > 0 {
+		result = append(result, aad[0]...)
+	}
+	return result
+}
+
+//decode master key, raw, hex or base64 format
+func (f *KeyRing) decodeMasterKey(data []byte) ([]byte, error) {
+	if len(data) == AeadKeySize {
+		return data, nil
+	}
+	str := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(str); err == nil && len(key) == AeadKeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(str); err == nil && len(key) == AeadKeySize {
+		return key, nil
+	}
+	if key, err := base64.RawURLEncoding.DecodeString(str); err == nil && len(key) == AeadKeySize {
+		return key, nil
+	}
+	return nil, errors.New("master key should be 32 bytes")
+}
@@ -0,0 +1,350 @@
+package crypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * one time password
+ * - HOTP(RFC 4226) and TOTP(RFC 6238)
+ * - clock drift window for verify
+ * - otpauth:// provisioning uri, render qr code by media.GenQrCodePng
+ * - recovery codes hashed by HMAC-SHA256, high entropy codes need no slow hash
+ */
+
+//hash algorithm
+const (
+	OtpAlgOfSha1 = "SHA1"
+	OtpAlgOfSha256 = "SHA256"
+	OtpAlgOfSha512 = "SHA512"
+)
+
+//default value
+const (
+	OtpDigits = 6
+	OtpPeriod = 30 //xxx seconds
+	OtpSkew = 1 //allowed drift steps on both sides
+	OtpSecretSize = 20
+	OtpHotpLookAhead = 10
+	OtpRecoveryCodeCount = 10
+	OtpRecoveryCodeSize = 10 //bytes of random, two groups of base32 chars
+)
+
+//face info
+type OTP struct {
+	digits int
+	period int
+	skew int
+	alg string
+	recoveryKey []byte
+	sync.RWMutex
+}
+
+//construct
+func NewOTP() *OTP {
+	this := &OTP{
+		digits: OtpDigits,
+		period: OtpPeriod,
+		skew: OtpSkew,
+		alg: OtpAlgOfSha1,
+	}
+	return this
+}
+
+//set code digits, 6 ~ 8
+func (f *OTP) SetDigits(digits int) error {
+	if digits < 6 || digits > 8 {
+		return errors.New("digits should be 6 ~ 8")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.digits = digits
+	return nil
+}
+
+//set totp period seconds
+func (f *OTP) SetPeriod(period int) error {
+	if period <= 0 {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.period = period
+	return nil
+}
+
+//set allowed drift steps
+func (f *OTP) SetSkew(skew int) error {
+	if skew < 0 {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.skew = skew
+	return nil
+}
+
+//set hash algorithm
+func (f *OTP) SetAlg(alg string) error {
+	switch alg {
+	case OtpAlgOfSha1, OtpAlgOfSha256, OtpAlgOfSha512:
+	default:
+		return errors.New("invalid algorithm")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.alg = alg
+	return nil
+}
+
+//set secret key of recovery code hash, hashes leaked without key can't be checked offline
+//empty key means plain SHA256
+func (f *OTP) SetRecoveryKey(key []byte) {
+	f.Lock()
+	defer f.Unlock()
+	f.recoveryKey = append([]byte{}, key...)
+}
+
+//gen random secret, base32 format without padding
+func (f *OTP) GenSecret() (string, error) {
+	buf := make([]byte, OtpSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+//gen hotp code
+func (f *OTP) HOTP(secret string, counter uint64) (string, error) {
+	key, err := f.decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	f.RLock()
+	digits, alg := f.digits, f.alg
+	f.RUnlock()
+	return f.genCode(key, counter, digits, alg), nil
+}
+
+//verify hotp code, look ahead for counter drift
+//return next counter which should be saved, is valid or not, error
+func (f *OTP) VerifyHOTP(secret, code string, counter uint64) (uint64, bool, error) {
+	for i := uint64(0); i <= OtpHotpLookAhead; i++ {
+		expected, err := f.HOTP(secret, counter + i)
+		if err != nil {
+			return counter, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter + i + 1, true, nil
+		}
+	}
+	return counter, false, nil
+}
+
+//gen totp code, time is optional, default is now
+func (f *OTP) TOTP(secret string, times ...time.Time) (string, error) {
+	return f.HOTP(secret, f.getStep(f.getTime(times...)))
+}
+
+//verify totp code within drift window
+//time is optional, default is now
+func (f *OTP) VerifyTOTP(secret, code string, times ...time.Time) (bool, error) {
+	_, ok, err := f.VerifyTOTPStep(secret, code, 0, times...)
+	return ok, err
+}
+
+//verify totp code, reject step not after last used step for replay protection
+//return matched step which should be saved, is valid or not, error
+func (f *OTP) VerifyTOTPStep(secret, code string, lastStep uint64, times ...time.Time) (uint64, bool, error) {
+	f.RLock()
+	skew := f.skew
+	f.RUnlock()
+	current := f.getStep(f.getTime(times...))
+	for i := -skew; i <= skew; i++ {
+		step := uint64(int64(current) + int64(i))
+		if step <= lastStep && lastStep > 0 {
+			continue
+		}
+		expected, err := f.HOTP(secret, step)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+//gen totp provisioning uri
+//like: otpauth://totp/Issuer:account?secret=xxx&issuer=Issuer&algorithm=SHA1&digits=6&period=30
+func (f *OTP) TotpURI(issuer, account, secret string) string {
+	f.RLock()
+	defer f.RUnlock()
+	params := f.uriParams(issuer, secret)
+	params.Set("period", fmt.Sprintf("%d", f.period))
+	return f.genURI("totp", issuer, account, params)
+}
+
+//gen hotp provisioning uri
+func (f *OTP) HotpURI(issuer, account, secret string, counter uint64) string {
+	f.RLock()
+	defer f.RUnlock()
+	params := f.uriParams(issuer, secret)
+	params.Set("counter", fmt.Sprintf("%d", counter))
+	return f.genURI("hotp", issuer, account, params)
+}
+
+//gen recovery codes
+//return plain codes for user, hashes for saving, error
+func (f *OTP) GenRecoveryCodes(counts ...int) ([]string, []string, error) {
+	count := OtpRecoveryCodeCount
+	if counts != nil && len(counts) > 0 && counts[0] > 0 {
+		count = counts[0]
+	}
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < count; i++ {
+		buf := make([]byte, OtpRecoveryCodeSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))
+		code := fmt.Sprintf("%s-%s", raw[:len(raw) / 2], raw[len(raw) / 2:])
+		codes = append(codes, code)
+		hashes = append(hashes, f.hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+//verify recovery code with saved hashes, all hashes compared in constant time
+//return index of matched hash which should be removed, -1 if not matched
+func (f *OTP) VerifyRecoveryCode(code string, hashes []string) (int, error) {
+	if strings.TrimSpace(code) == "" {
+		return -1, errors.New("invalid parameter")
+	}
+	hash := []byte(f.hashRecoveryCode(code))
+	matched := -1
+	for i, v := range hashes {
+		if subtle.ConstantTimeCompare(hash, []byte(v)) == 1 && matched < 0 {
+			matched = i
+		}
+	}
+	return matched, nil
+}
+
+////////////////
+//private func
+////////////////
+
+//gen code by RFC 4226 dynamic truncation
+func (f *OTP) genCode(key []byte, counter uint64, digits int, alg string) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, counter)
+	mac := hmac.New(f.getHash(alg), key)
+	mac.Write(buf)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum) - 1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset : offset + 4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value % mod)
+}
+
+//get hash func
+func (f *OTP) getHash(alg string) func() hash.Hash {
+	switch alg {
+	case OtpAlgOfSha256:
+		return sha256.New
+	case OtpAlgOfSha512:
+		return sha512.New
+	}
+	return sha1.New
+}
+
+//decode base32 secret, case and padding insensitive
+func (f *OTP) decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	secret = strings.TrimRight(secret, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) <= 0 {
+		return nil, errors.New("invalid otp secret")
+	}
+	return key, nil
+}
+
+//get time step
+func (f *OTP) getStep(t time.Time) uint64 {
+	f.RLock()
+	defer f.RUnlock()
+	return uint64(t.Unix() / int64(f.period))
+}
+
+//get time, default is now
+func (f *OTP) getTime(times ...time.Time) time.Time {
+	if times != nil && len(times) > 0 {
+		return times[0]
+	}
+	return time.Now()
+}
+
+//get common uri params, without locker
+func (f *OTP) uriParams(issuer, secret string) url.Values {
+	params := url.Values{}
+	params.Set("secret", secret)
+	if issuer != "" {
+		params.Set("issuer", issuer)
+	}
+	params.Set("algorithm", f.alg)
+	params.Set("digits", fmt.Sprintf("%d", f.digits))
+	return params
+}
+
+//gen otpauth uri
+func (f *OTP) genURI(otpType, issuer, account string, params url.Values) string {
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+	return fmt.Sprintf("otpauth://%s/%s?%s", otpType, label, params.Encode())
+}
+
+//hash normalized recovery code, hex format
+func (f *OTP) hashRecoveryCode(code string) string {
+	f.RLock()
+	key := f.recoveryKey
+	f.RUnlock()
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	}else{
+		h = sha256.New()
+	}
+	h.Write([]byte(f.normalizeRecoveryCode(code)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//normalize recovery code, lower case without separator
+func (f *OTP) normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.Replace(code, "-", "", -1)
+	return strings.Replace(code, " ", "", -1)
+}
@@ -0,0 +1,326 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+	"strings"
+	"sync"
+)
+
+/*
+ * password hashing
+ * - bcrypt, scrypt and Argon2id
+ * - PHC string format, like:
+ *   $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+ *   $scrypt$ln=15,r=8,p=1$<salt>$<hash>
+ *   bcrypt keep its own format, like $2a$10$...
+ * - verify auto detect algorithm
+ */
+
+//algorithm
+const (
+	PasswordAlgOfArgon2id = "argon2id"
+	PasswordAlgOfScrypt = "scrypt"
+	PasswordAlgOfBcrypt = "bcrypt"
+)
+
+//default params
+const (
+	PasswordSaltSize = 16
+	PasswordKeySize = 32
+	PasswordArgon2Time = 3
+	PasswordArgon2Memory = 64 * 1024 //KiB
+	PasswordArgon2Threads = 4
+	PasswordScryptLogN = 15
+	PasswordScryptR = 8
+	PasswordScryptP = 1
+	PasswordBcryptCost = bcrypt.DefaultCost
+)
+
+//accepted params range of stored hash, avoid panic and resource exhaustion
+const (
+	PasswordArgon2MaxTime = 10
+	PasswordArgon2MaxMemory = 1024 * 1024 //KiB
+	PasswordScryptMaxLogN = 20
+	PasswordScryptMaxR = 32
+	PasswordScryptMaxP = 16
+	PasswordBcryptMaxCost = 16
+	PasswordMinSaltSize = 8
+	PasswordMinKeySize = 16
+	PasswordMaxKeySize = 64
+)
+
+//hash params
+type PasswordParams struct {
+	Argon2Time uint32
+	Argon2Memory uint32 //KiB
+	Argon2Threads uint8
+	ScryptLogN int
+	ScryptR int
+	ScryptP int
+	BcryptCost int
+}
+
+//face info
+type Password struct {
+	alg string
+	params *PasswordParams
+	sync.RWMutex
+}
+
+//construct
+func NewPassword() *Password {
+	this := &Password{
+		alg: PasswordAlgOfArgon2id,
+		params: &PasswordParams{
+			Argon2Time: PasswordArgon2Time,
+			Argon2Memory: PasswordArgon2Memory,
+			Argon2Threads: PasswordArgon2Threads,
+			ScryptLogN: PasswordScryptLogN,
+			ScryptR: PasswordScryptR,
+			ScryptP: PasswordScryptP,
+			BcryptCost: PasswordBcryptCost,
+		},
+	}
+	return this
+}
+
+//set algorithm for new hash
+func (f *Password) SetAlg(alg string) error {
+	switch alg {
+	case PasswordAlgOfArgon2id, PasswordAlgOfScrypt, PasswordAlgOfBcrypt:
+	default:
+		return errors.New("invalid algorithm")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.alg = alg
+	return nil
+}
+
+//set params for new hash, params of all algorithms should be in accepted range
+func (f *Password) SetParams(params *PasswordParams) error {
+	if params == nil {
+		return errors.New("invalid parameter")
+	}
+	for _, alg := range []string{PasswordAlgOfArgon2id, PasswordAlgOfScrypt, PasswordAlgOfBcrypt} {
+		if err := checkPasswordParams(alg, params); err != nil {
+			return err
+		}
+	}
+	paramsCopy := *params
+	f.Lock()
+	defer f.Unlock()
+	f.params = &paramsCopy
+	return nil
+}
+
+//get copy of current params
+func (f *Password) GetParams() PasswordParams {
+	f.RLock()
+	defer f.RUnlock()
+	return *f.params
+}
+
+//hash password into PHC string
+func (f *Password) Hash(password string) (string, error) {
+	f.RLock()
+	alg := f.alg
+	params := *f.params
+	f.RUnlock()
+
+	//bcrypt use its own salt and format
+	if alg == PasswordAlgOfBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), params.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	//gen salt
+	salt := make([]byte, PasswordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	//hash by algorithm
+	switch alg {
+	case PasswordAlgOfScrypt:
+		key, err := scrypt.Key([]byte(password), salt, 1 << uint(params.ScryptLogN),
+			params.ScryptR, params.ScryptP, PasswordKeySize)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s", PasswordAlgOfScrypt,
+			params.ScryptLogN, params.ScryptR, params.ScryptP,
+			f.encode(salt), f.encode(key)), nil
+	default:
+		key := argon2.IDKey([]byte(password), salt, params.Argon2Time,
+			params.Argon2Memory, params.Argon2Threads, PasswordKeySize)
+		return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", PasswordAlgOfArgon2id,
+			argon2.Version, params.Argon2Memory, params.Argon2Time, params.Argon2Threads,
+			f.encode(salt), f.encode(key)), nil
+	}
+}
+
+//verify password with encoded hash, algorithm auto detected
+//compare in constant time
+func (f *Password) Verify(password, encoded string) (bool, error) {
+	alg, params, salt, hash, err := f.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	//compute hash with same params
+	var (
+		key []byte
+	)
+	switch alg {
+	case PasswordAlgOfBcrypt:
+		err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case PasswordAlgOfScrypt:
+		key, err = scrypt.Key([]byte(password), salt, 1 << uint(params.ScryptLogN),
+			params.ScryptR, params.ScryptP, len(hash))
+		if err != nil {
+			return false, err
+		}
+	default:
+		key = argon2.IDKey([]byte(password), salt, params.Argon2Time,
+			params.Argon2Memory, params.Argon2Threads, uint32(len(hash)))
+	}
+	return subtle.ConstantTimeCompare(key, hash) == 1, nil
+}
+
+//check encoded hash need rehash
+//when algorithm or params changed
+func (f *Password) NeedsRehash(encoded string) bool {
+	alg, params, _, _, err := f.decode(encoded)
+	if err != nil {
+		return true
+	}
+	f.RLock()
+	defer f.RUnlock()
+	if alg != f.alg {
+		return true
+	}
+	switch alg {
+	case PasswordAlgOfBcrypt:
+		return params.BcryptCost != f.params.BcryptCost
+	case PasswordAlgOfScrypt:
+		return params.ScryptLogN != f.params.ScryptLogN ||
+			params.ScryptR != f.params.ScryptR ||
+			params.ScryptP != f.params.ScryptP
+	default:
+		return params.Argon2Time != f.params.Argon2Time ||
+			params.Argon2Memory != f.params.Argon2Memory ||
+			params.Argon2Threads != f.params.Argon2Threads
+	}
+}
+
+////////////////
+//private func
+////////////////
+
+//decode encoded hash
+//return alg, params, salt, hash, error
+func (f *Password) decode(encoded string) (string, *PasswordParams, []byte, []byte, error) {
+	var (
+		params = &PasswordParams{}
+		version int
+		salt, hash []byte
+		err error
+	)
+
+	//bcrypt format
+	if strings.HasPrefix(encoded, "$2") {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return "", nil, nil, nil, err
+		}
+		if cost > PasswordBcryptMaxCost {
+			return "", nil, nil, nil, errors.New("bcrypt cost out of range")
+		}
+		params.BcryptCost = cost
+		return PasswordAlgOfBcrypt, params, nil, nil, nil
+	}
+
+	//PHC format
+	parts := strings.Split(encoded, "$")
+	switch {
+	case len(parts) == 6 && parts[1] == PasswordAlgOfArgon2id:
+		if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+			return "", nil, nil, nil, err
+		}
+		if version != argon2.Version {
+			return "", nil, nil, nil, errors.New("incompatible argon2 version")
+		}
+		_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d",
+			&params.Argon2Memory, &params.Argon2Time, &params.Argon2Threads)
+		salt, hash = f.decodeBase64(parts[4]), f.decodeBase64(parts[5])
+	case len(parts) == 5 && parts[1] == PasswordAlgOfScrypt:
+		_, err = fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d",
+			&params.ScryptLogN, &params.ScryptR, &params.ScryptP)
+		salt, hash = f.decodeBase64(parts[3]), f.decodeBase64(parts[4])
+	default:
+		return "", nil, nil, nil, errors.New("unknown password hash format")
+	}
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+	if len(salt) < PasswordMinSaltSize || len(hash) < PasswordMinKeySize || len(hash) > PasswordMaxKeySize {
+		return "", nil, nil, nil, errors.New("invalid password hash")
+	}
+	if err = checkPasswordParams(parts[1], params); err != nil {
+		return "", nil, nil, nil, err
+	}
+	return parts[1], params, salt, hash, nil
+}
+
+//check params of stored hash or new hash in accepted range
+func checkPasswordParams(alg string, params *PasswordParams) error {
+	switch alg {
+	case PasswordAlgOfScrypt:
+		if params.ScryptLogN < 1 || params.ScryptLogN > PasswordScryptMaxLogN ||
+			params.ScryptR < 1 || params.ScryptR > PasswordScryptMaxR ||
+			params.ScryptP < 1 || params.ScryptP > PasswordScryptMaxP {
+			return errors.New("scrypt params out of range")
+		}
+	case PasswordAlgOfBcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > PasswordBcryptMaxCost {
+			return errors.New("bcrypt cost out of range")
+		}
+	default:
+		if params.Argon2Time < 1 || params.Argon2Time > PasswordArgon2MaxTime ||
+			params.Argon2Threads < 1 ||
+			params.Argon2Memory < 8 * uint32(params.Argon2Threads) ||
+			params.Argon2Memory > PasswordArgon2MaxMemory {
+			return errors.New("argon2 params out of range")
+		}
+	}
+	return nil
+}
+
+//encode as raw std base64, PHC format
+func (f *Password) encode(data []byte) string {
+	return base64.RawStdEncoding.EncodeToString(data)
+}
+
+//decode raw std base64, return nil if failed
+func (f *Password) decodeBase64(data string) []byte {
+	result, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		return nil
+	}
+	return result
+}
@@ -6,20 +6,47 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"github.com/youmark/pkcs8"
+	"sync"
 )
 
 /*
  * RSA encrypt algorithm
+ * - 2048/3072/4096 bits key
+ * - RSA-OAEP encryption and RSA-PSS signature
+ * - PKCS#1, PKCS#8 and encrypted pem private key
+ * - hybrid encryption for large payload
  */
 
+//pem block type
+const (
+	PemTypeOfRsaPrivateKey = "RSA PRIVATE KEY"
+	PemTypeOfPrivateKey = "PRIVATE KEY"
+	PemTypeOfEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+	PemTypeOfPublicKey = "PUBLIC KEY"
+	PemTypeOfRsaPublicKey = "RSA PUBLIC KEY"
+)
+
+//key size
+const (
+	RsaKeySizeOf2048 = 2048
+	RsaKeySizeOf3072 = 3072
+	RsaKeySizeOf4096 = 4096
+	RsaKeySizeDefault = RsaKeySizeOf2048
+)
+
 //face info
 type Rsa struct {
 	pubKey string
 	prvKey string
+	publicKey *rsa.PublicKey
+	privateKey *rsa.PrivateKey
+	sync.RWMutex
 }
 
 //construct
@@ -46,30 +73,237 @@ func (f *Rsa) Demo() {
 	fmt.Println("-------------------------------Decode Data-----------------------------------------")
 	cipherText, _ := f.RsaEncrypt([]byte(data), pubKey)
 	fmt.Println("Pub key enc data：", hex.EncodeToString(cipherText))
-	sourceData, _ := f.RsaDecrypt([]byte(data), prvKey)
+	sourceData, _ := f.RsaDecrypt(cipherText, prvKey)
 	fmt.Println("Prv key decode data：", string(sourceData))
 }
 
 //set key
-func (f *Rsa) SetKey(pubKey, prvKey string) error {
+//one of keys can be empty, like public key only for verify
+//password used for encrypted private key
+func (f *Rsa) SetKey(pubKey, prvKey string, passwords ...string) error {
+	var (
+		publicKey *rsa.PublicKey
+		privateKey *rsa.PrivateKey
+		err error
+	)
 	//check
-	if pubKey == "" || prvKey == "" {
+	if pubKey == "" && prvKey == "" {
 		return errors.New("invalid parameter")
 	}
+
+	//parse keys
+	if prvKey != "" {
+		privateKey, err = f.ParsePrivateKey([]byte(prvKey), passwords...)
+		if err != nil {
+			return err
+		}
+		publicKey = &privateKey.PublicKey
+	}
+	if pubKey != "" {
+		publicKey, err = f.ParsePublicKey([]byte(pubKey))
+		if err != nil {
+			return err
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
 	f.pubKey = pubKey
 	f.prvKey = prvKey
+	f.publicKey = publicKey
+	f.privateKey = privateKey
 	return nil
 }
 
-//private key decode
-func (f *Rsa) RsaDecrypt(cipherText, keyBytes []byte) ([]byte, error) {
-	//get private key
+//get private key which set by `SetKey`
+func (f *Rsa) GetPrivateKey() (*rsa.PrivateKey, error) {
+	f.RLock()
+	defer f.RUnlock()
+	if f.privateKey == nil {
+		return nil, errors.New("private key not set")
+	}
+	return f.privateKey, nil
+}
+
+//get public key which set by `SetKey`
+func (f *Rsa) GetPublicKey() (*rsa.PublicKey, error) {
+	f.RLock()
+	defer f.RUnlock()
+	if f.publicKey == nil {
+		return nil, errors.New("public key not set")
+	}
+	return f.publicKey, nil
+}
+
+//parse pem format private key
+//support PKCS#1, PKCS#8, encrypted PKCS#8 and legacy encrypted pem
+func (f *Rsa) ParsePrivateKey(keyBytes []byte, passwords ...string) (*rsa.PrivateKey, error) {
+	var (
+		password []byte
+		der []byte
+		err error
+	)
 	block, _ := pem.Decode(keyBytes)
 	if block == nil {
 		return nil, errors.New("private key error")
 	}
-	//parse PKCS1 format private key
-	prvKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if passwords != nil && len(passwords) > 0 {
+		password = []byte(passwords[0])
+	}
+
+	//decrypt legacy encrypted pem
+	der = block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		if password == nil {
+			return nil, errors.New("private key is encrypted")
+		}
+		der, err = x509.DecryptPEMBlock(block, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//parse by block type
+	switch block.Type {
+	case PemTypeOfEncryptedPrivateKey:
+		if password == nil {
+			return nil, errors.New("private key is encrypted")
+		}
+		return pkcs8.ParsePKCS8PrivateKeyRSA(der, password)
+	case PemTypeOfPrivateKey:
+		return pkcs8.ParsePKCS8PrivateKeyRSA(der)
+	default:
+		return x509.ParsePKCS1PrivateKey(der)
+	}
+}
+
+//parse pem format public key
+//support PKIX and PKCS#1
+func (f *Rsa) ParsePublicKey(keyBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("public key error")
+	}
+	if block.Type == PemTypeOfRsaPublicKey {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := pubInterface.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not rsa public key")
+	}
+	return pub, nil
+}
+
+//marshal private key as PKCS#8 pem
+//if password assigned, encrypt with AES-256-CBC
+func (f *Rsa) MarshalPrivateKey(privateKey *rsa.PrivateKey, passwords ...string) ([]byte, error) {
+	var (
+		der []byte
+		err error
+		pemType = PemTypeOfPrivateKey
+	)
+	if privateKey == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	if passwords != nil && len(passwords) > 0 && passwords[0] != "" {
+		pemType = PemTypeOfEncryptedPrivateKey
+		der, err = pkcs8.ConvertPrivateKeyToPKCS8(privateKey, []byte(passwords[0]))
+	}else{
+		der, err = x509.MarshalPKCS8PrivateKey(privateKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der}), nil
+}
+
+//encrypt by RSA-OAEP with sha256, use key set by `SetKey`
+func (f *Rsa) Encrypt(data []byte, labels ...[]byte) ([]byte, error) {
+	pub, err := f.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, data, f.getLabel(labels...))
+}
+
+//decrypt by RSA-OAEP with sha256, use key set by `SetKey`
+func (f *Rsa) Decrypt(cipherText []byte, labels ...[]byte) ([]byte, error) {
+	prv, err := f.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, prv, cipherText, f.getLabel(labels...))
+}
+
+//sign by RSA-PSS with sha256, use key set by `SetKey`
+func (f *Rsa) Sign(data []byte) ([]byte, error) {
+	prv, err := f.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	hashed := sha256.Sum256(data)
+	return rsa.SignPSS(rand.Reader, prv, crypto.SHA256, hashed[:], nil)
+}
+
+//verify RSA-PSS signature with sha256, use key set by `SetKey`
+func (f *Rsa) Verify(data, signData []byte) error {
+	pub, err := f.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256(data)
+	return rsa.VerifyPSS(pub, crypto.SHA256, hashed[:], signData, nil)
+}
+
+//hybrid encrypt for payload larger than modulus
+//random key wrapped by RSA-OAEP, payload encrypted by `Aead`
+//format: [wrapped key size(2 bytes)][wrapped key][aead ciphertext]
+func (f *Rsa) HybridEncrypt(data []byte) ([]byte, error) {
+	aead := NewAead()
+	key, err := aead.GenKey()
+	if err != nil {
+		return nil, err
+	}
+	aead.SetKey(key)
+	wrappedKey, err := f.Encrypt(key)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := aead.Encrypt(data, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, 2, 2 + len(wrappedKey) + len(sealed))
+	binary.BigEndian.PutUint16(result, uint16(len(wrappedKey)))
+	result = append(result, wrappedKey...)
+	return append(result, sealed...), nil
+}
+
+//hybrid decrypt
+func (f *Rsa) HybridDecrypt(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, errors.New("invalid ciphertext")
+	}
+	keySize := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2 + keySize {
+		return nil, errors.New("invalid ciphertext")
+	}
+	wrappedKey := data[2:2+keySize]
+	key, err := f.Decrypt(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewAead(key).Decrypt(data[2+keySize:], wrappedKey)
+}
+
+//private key decode, PKCS#1 v1.5
+//Deprecated: use `Decrypt` with RSA-OAEP
+func (f *Rsa) RsaDecrypt(cipherText, keyBytes []byte) ([]byte, error) {
+	prvKey, err := f.ParsePrivateKey(keyBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -78,19 +312,13 @@ func (f *Rsa) RsaDecrypt(cipherText, keyBytes []byte) ([]byte, error) {
 	return data, err
 }
 
-//public key encode
+//public key encode, PKCS#1 v1.5
+//Deprecated: use `Encrypt` with RSA-OAEP
 func (f *Rsa) RsaEncrypt(data, keyBytes []byte) ([]byte, error) {
-	//decode pem format public key
-	block, _ := pem.Decode(keyBytes)
-	if block == nil {
-		return nil, errors.New("public key error")
-	}
-	//parse public key
-	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	pub, err := f.ParsePublicKey(keyBytes)
 	if err != nil {
 		return nil, err
 	}
-	pub := pubInterface.(*rsa.PublicKey)
 	//encrypt
 	cipherText, err := rsa.EncryptPKCS1v15(rand.Reader, pub, data)
 	if err != nil {
@@ -99,35 +327,25 @@ func (f *Rsa) RsaEncrypt(data, keyBytes []byte) ([]byte, error) {
 	return cipherText, nil
 }
 
-//verify signature
+//verify signature, PKCS#1 v1.5
 func (f *Rsa) RsaVerySignWithSha256(data, signData, keyBytes []byte) error {
-	block, _ := pem.Decode(keyBytes)
-	if block == nil {
-		return errors.New("public key error")
-	}
-	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	pub, err := f.ParsePublicKey(keyBytes)
 	if err != nil {
 		return err
 	}
 	hashed := sha256.Sum256(data)
-	err = rsa.VerifyPKCS1v15(pubKey.(*rsa.PublicKey), crypto.SHA256, hashed[:], signData)
+	err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], signData)
 	return err
 }
 
-//gen signature
+//gen signature, PKCS#1 v1.5
 func (f *Rsa) RsaSignWithSha256(data, keyBytes []byte) ([]byte, error) {
-	h := sha256.New()
-	h.Write(data)
-	hashed := h.Sum(nil)
-	block, _ := pem.Decode(keyBytes)
-	if block == nil {
-		return nil, errors.New("private key error")
-	}
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	hashed := sha256.Sum256(data)
+	privateKey, err := f.ParsePrivateKey(keyBytes)
 	if err != nil {
-		return nil, fmt.Errorf("parsePKCS8PrivateKey err:%v", err)
+		return nil, fmt.Errorf("parse private key err:%v", err)
 	}
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
 	if err != nil {
 		return nil, fmt.Errorf("error from signing, err:%v", err)
 	}
@@ -135,20 +353,31 @@ func (f *Rsa) RsaSignWithSha256(data, keyBytes []byte) ([]byte, error) {
 }
 
 //gen rsa private and public key
-//return prvKey, pubKey, error
-func (f *Rsa) GenRsaKey() ([]byte, []byte, error) {
+//bits should be 2048, 3072 or 4096, default 2048
+//return prvKey(PKCS#1), pubKey, error
+func (f *Rsa) GenRsaKey(bits ...int) ([]byte, []byte, error) {
 	var (
 		prvKey, pubKey []byte
 		err error
 	)
+	keySize := RsaKeySizeDefault
+	if bits != nil && len(bits) > 0 {
+		keySize = bits[0]
+	}
+	switch keySize {
+	case RsaKeySizeOf2048, RsaKeySizeOf3072, RsaKeySizeOf4096:
+	default:
+		return nil, nil, errors.New("key size should be 2048, 3072 or 4096")
+	}
+
 	//gen private key
-	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	privateKey, err := rsa.GenerateKey(rand.Reader, keySize)
 	if err != nil {
 		return nil, nil, err
 	}
 	derStream := x509.MarshalPKCS1PrivateKey(privateKey)
 	block := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
+		Type:  PemTypeOfRsaPrivateKey,
 		Bytes: derStream,
 	}
 	prvKey = pem.EncodeToMemory(block)
@@ -159,9 +388,21 @@ func (f *Rsa) GenRsaKey() ([]byte, []byte, error) {
 		return nil, nil, err
 	}
 	block = &pem.Block{
-		Type:  "PUBLIC KEY",
+		Type:  PemTypeOfPublicKey,
 		Bytes: derPKix,
 	}
 	pubKey = pem.EncodeToMemory(block)
 	return prvKey, pubKey, nil
-}
\ No newline at end of file
+}
+
+////////////////
+//private func
+////////////////
+
+//get optional oaep label
+func (f *Rsa) getLabel(labels ...[]byte) []byte {
+	if labels != nil && len(labels) > 0 {
+		return labels[0]
+	}
+	return nil
+}
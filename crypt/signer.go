@@ -0,0 +1,306 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * hmac request signing for service to service http call
+ * canonical string:
+ * METHOD\nPATH\nSORTED_QUERY\nHEX(SHA256(BODY))\nTIMESTAMP\nNONCE
+ */
+
+//signature header
+const (
+	SignHeaderOfKey = "X-Sign-Key"
+	SignHeaderOfTimestamp = "X-Sign-Timestamp"
+	SignHeaderOfNonce = "X-Sign-Nonce"
+	SignHeaderOfSignature = "X-Sign-Signature"
+)
+
+//default value
+const (
+	SignNonceSize = 16
+	SignMaxSkew = 5 * time.Minute
+	SignMaxBodySize = 10 * 1024 * 1024 //bytes
+)
+
+//signer errors
+var (
+	ErrSignMissing = errors.New("request signature missing")
+	ErrSignUnknownKey = errors.New("request sign key unknown")
+	ErrSignExpired = errors.New("request timestamp expired")
+	ErrSignInvalid = errors.New("request signature invalid")
+	ErrSignBodyTooLarge = errors.New("request body too large")
+)
+
+//verified sign info
+type SignInfo struct {
+	KeyId string
+	Timestamp int64
+	Nonce string
+}
+
+//face info
+type HmacSigner struct {
+	keyMap map[string][]byte //key id -> secret
+	activeKeyId string
+	maxSkew time.Duration
+	maxBodySize int64
+	sync.RWMutex
+}
+
+//construct
+func NewHmacSigner() *HmacSigner {
+	this := &HmacSigner{
+		keyMap: map[string][]byte{},
+		maxSkew: SignMaxSkew,
+		maxBodySize: SignMaxBodySize,
+	}
+	return this
+}
+
+//add key, first added key is active for signing
+func (f *HmacSigner) AddKey(keyId string, secret []byte) error {
+	if keyId == "" || len(secret) <= 0 {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.keyMap[keyId] = secret
+	if f.activeKeyId == "" {
+		f.activeKeyId = keyId
+	}
+	return nil
+}
+
+//remove key
+func (f *HmacSigner) RemoveKey(keyId string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.keyMap, keyId)
+	if f.activeKeyId == keyId {
+		f.activeKeyId = ""
+	}
+}
+
+//set active key for signing
+func (f *HmacSigner) SetActiveKey(keyId string) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.keyMap[keyId]; !ok {
+		return ErrSignUnknownKey
+	}
+	f.activeKeyId = keyId
+	return nil
+}
+
+//set max timestamp skew
+func (f *HmacSigner) SetMaxSkew(maxSkew time.Duration) {
+	if maxSkew <= 0 {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.maxSkew = maxSkew
+}
+
+//get max timestamp skew
+func (f *HmacSigner) GetMaxSkew() time.Duration {
+	f.RLock()
+	defer f.RUnlock()
+	return f.maxSkew
+}
+
+//set max body size of incoming request, body over size will be rejected
+//before signature check
+func (f *HmacSigner) SetMaxBodySize(maxBodySize int64) {
+	if maxBodySize <= 0 {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.maxBodySize = maxBodySize
+}
+
+//sign outgoing request with active key
+//body will be read and restored
+func (f *HmacSigner) SignRequest(req *http.Request) error {
+	if req == nil || req.URL == nil {
+		return errors.New("invalid parameter")
+	}
+	f.RLock()
+	keyId := f.activeKeyId
+	secret := f.keyMap[keyId]
+	f.RUnlock()
+	if secret == nil {
+		return ErrSignUnknownKey
+	}
+
+	//read body
+	body, err := f.readBody(req, 0)
+	if err != nil {
+		return err
+	}
+
+	//gen timestamp and nonce
+	timestamp := time.Now().Unix()
+	nonce, err := f.genNonce()
+	if err != nil {
+		return err
+	}
+
+	//sign
+	canonical := f.Canonical(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, body, timestamp, nonce)
+	req.Header.Set(SignHeaderOfKey, keyId)
+	req.Header.Set(SignHeaderOfTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignHeaderOfNonce, nonce)
+	req.Header.Set(SignHeaderOfSignature, f.Sign(secret, canonical))
+	return nil
+}
+
+//verify incoming request signature and timestamp
+//nonce replay should be checked by caller
+func (f *HmacSigner) VerifyRequest(req *http.Request) (*SignInfo, error) {
+	if req == nil || req.URL == nil {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//get sign headers
+	keyId := req.Header.Get(SignHeaderOfKey)
+	timestampStr := req.Header.Get(SignHeaderOfTimestamp)
+	nonce := req.Header.Get(SignHeaderOfNonce)
+	signature := req.Header.Get(SignHeaderOfSignature)
+	if keyId == "" || timestampStr == "" || nonce == "" || signature == "" {
+		return nil, ErrSignMissing
+	}
+	f.RLock()
+	secret, ok := f.keyMap[keyId]
+	maxSkew := f.maxSkew
+	maxBodySize := f.maxBodySize
+	f.RUnlock()
+	if !ok {
+		return nil, ErrSignUnknownKey
+	}
+
+	//check timestamp
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return nil, ErrSignInvalid
+	}
+	diff := time.Since(time.Unix(timestamp, 0))
+	if diff > maxSkew || diff < -maxSkew {
+		return nil, ErrSignExpired
+	}
+
+	//check signature
+	body, err := f.readBody(req, maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	canonical := f.Canonical(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, body, timestamp, nonce)
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, f.sum(secret, canonical)) {
+		return nil, ErrSignInvalid
+	}
+	info := &SignInfo{
+		KeyId: keyId,
+		Timestamp: timestamp,
+		Nonce: nonce,
+	}
+	return info, nil
+}
+
+//gen canonical string
+func (f *HmacSigner) Canonical(
+			method, path, rawQuery string,
+			body []byte,
+			timestamp int64,
+			nonce string,
+		) string {
+	bodyHash := sha256.Sum256(body)
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		f.sortQuery(rawQuery),
+		hex.EncodeToString(bodyHash[:]),
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+	}, "\n")
+}
+
+//sign canonical string, return hex format
+func (f *HmacSigner) Sign(secret []byte, canonical string) string {
+	return hex.EncodeToString(f.sum(secret, canonical))
+}
+
+////////////////
+//private func
+////////////////
+
+//hmac sha256
+func (f *HmacSigner) sum(secret []byte, canonical string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return mac.Sum(nil)
+}
+
+//sort query by key and value
+func (f *HmacSigner) sortQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	for _, v := range values {
+		sort.Strings(v)
+	}
+	return values.Encode()
+}
+
+//read and restore request body, limited by max size if > 0
+func (f *HmacSigner) readBody(req *http.Request, maxSize int64) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	var reader io.Reader = req.Body
+	if maxSize > 0 {
+		reader = io.LimitReader(req.Body, maxSize + 1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && int64(len(body)) > maxSize {
+		return nil, ErrSignBodyTooLarge
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+//gen random nonce
+func (f *HmacSigner) genNonce() (string, error) {
+	buf := make([]byte, SignNonceSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("gen nonce failed, err:%v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
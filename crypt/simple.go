@@ -11,6 +11,8 @@ import (
 
 /*
  * Simple encrypt algorithm
+ * Deprecated: no integrity and easy to break, use `Aead` instead.
+ * `Aead.MigrateSimple` can convert legacy value.
  */
 
 //simple encrypt info
@@ -0,0 +1,268 @@
+package crypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/*
+ * streaming authenticated encryption
+ * - io.Reader -> io.Writer in fixed size chunks, large file never kept in memory
+ * - per stream sub key derived by hkdf from key and random salt
+ * - chunk nonce is [zero padding][counter(4 bytes)][last flag(1 byte)]
+ *   so chunk reorder, drop and truncation are detected
+ *
+ * format:
+ * header: [version][alg][chunk size(4 bytes)][salt(16 bytes)]
+ * body: [sealed chunk]..., each chunk sealed with header as additional data
+ */
+
+//stream version
+const (
+	StreamVersion = 1
+)
+
+//default value
+const (
+	StreamChunkSize = 64 * 1024
+	StreamMaxChunkSize = 16 * 1024 * 1024
+	StreamSaltSize = 16
+	StreamHeaderSize = 2 + 4 + StreamSaltSize
+	StreamKdfInfo = "tinycells.stream"
+)
+
+//face info
+type Stream struct {
+	key []byte
+	alg int
+	chunkSize int
+	sync.RWMutex
+}
+
+//construct
+func NewStream(keys ...[]byte) *Stream {
+	this := &Stream{
+		alg: AeadAlgOfAesGcm,
+		chunkSize: StreamChunkSize,
+	}
+	if keys != nil && len(keys) > 0 {
+		this.SetKey(keys[0])
+	}
+	return this
+}
+
+//set key, should be 32 bytes
+func (f *Stream) SetKey(key []byte) error {
+	if len(key) != AeadKeySize {
+		return errors.New("key should be 32 bytes")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.key = append([]byte{}, key...)
+	return nil
+}
+
+//set algorithm for encrypt
+func (f *Stream) SetAlg(alg int) error {
+	if alg != AeadAlgOfAesGcm && alg != AeadAlgOfXChaCha20 {
+		return errors.New("invalid algorithm")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.alg = alg
+	return nil
+}
+
+//set plain chunk size for encrypt
+func (f *Stream) SetChunkSize(size int) error {
+	if size <= 0 || size > StreamMaxChunkSize {
+		return errors.New("invalid chunk size")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.chunkSize = size
+	return nil
+}
+
+//encrypt src stream into dst
+func (f *Stream) Encrypt(dst io.Writer, src io.Reader) error {
+	f.RLock()
+	key, alg, chunkSize := f.key, f.alg, f.chunkSize
+	f.RUnlock()
+	if key == nil {
+		return errors.New("key not set")
+	}
+
+	//gen and write header
+	header := make([]byte, StreamHeaderSize)
+	header[0], header[1] = StreamVersion, byte(alg)
+	binary.BigEndian.PutUint32(header[2:6], uint32(chunkSize))
+	if _, err := rand.Read(header[6:]); err != nil {
+		return err
+	}
+	aead, err := f.newCipher(key, header)
+	if err != nil {
+		return err
+	}
+	if _, err = dst.Write(header); err != nil {
+		return err
+	}
+
+	//seal chunks
+	reader := bufio.NewReaderSize(src, chunkSize + 1)
+	plain := make([]byte, chunkSize)
+	sealed := make([]byte, 0, chunkSize + aead.Overhead())
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(reader, plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := n < chunkSize
+		if !last {
+			//full chunk, check it is the end or not
+			if _, err = reader.Peek(1); err == io.EOF {
+				last = true
+			}else if err != nil {
+				return err
+			}
+		}
+		sealed = aead.Seal(sealed[:0], f.chunkNonce(aead.NonceSize(), counter, last), plain[:n], header)
+		if _, err = dst.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		if counter == ^uint32(0) {
+			return errors.New("stream too large")
+		}
+	}
+}
+
+//decrypt src stream into dst
+//each chunk is verified before written, if error returned,
+//written part should be discarded as stream may be truncated
+func (f *Stream) Decrypt(dst io.Writer, src io.Reader) error {
+	f.RLock()
+	key := f.key
+	f.RUnlock()
+	if key == nil {
+		return errors.New("key not set")
+	}
+
+	//read header
+	header := make([]byte, StreamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return errors.New("invalid stream header")
+	}
+	if header[0] != StreamVersion {
+		return errors.New("invalid stream version")
+	}
+	chunkSize := int(binary.BigEndian.Uint32(header[2:6]))
+	if chunkSize <= 0 || chunkSize > StreamMaxChunkSize {
+		return errors.New("invalid stream chunk size")
+	}
+	aead, err := f.newCipher(key, header)
+	if err != nil {
+		return err
+	}
+
+	//open chunks
+	sealedSize := chunkSize + aead.Overhead()
+	reader := bufio.NewReaderSize(src, sealedSize + 1)
+	sealed := make([]byte, sealedSize)
+	plain := make([]byte, 0, chunkSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(reader, sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := n < sealedSize
+		if !last {
+			if _, err = reader.Peek(1); err == io.EOF {
+				last = true
+			}else if err != nil {
+				return err
+			}
+		}
+		plain, err = aead.Open(plain[:0], f.chunkNonce(aead.NonceSize(), counter, last), sealed[:n], header)
+		if err != nil {
+			return errors.New("stream chunk authentication failed")
+		}
+		if _, err = dst.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+//encrypt file, dst file replaced only when success
+func (f *Stream) EncryptFile(srcPath, dstPath string) error {
+	return f.processFile(srcPath, dstPath, f.Encrypt)
+}
+
+//decrypt file, dst file replaced only when success
+func (f *Stream) DecryptFile(srcPath, dstPath string) error {
+	return f.processFile(srcPath, dstPath, f.Decrypt)
+}
+
+////////////////
+//private func
+////////////////
+
+//create aead cipher with sub key of header salt
+func (f *Stream) newCipher(key, header []byte) (cipher.AEAD, error) {
+	subKey := make([]byte, AeadKeySize)
+	salt := header[6:StreamHeaderSize]
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte(StreamKdfInfo)), subKey); err != nil {
+		return nil, err
+	}
+	return NewAead().newCipher(subKey, int(header[1]))
+}
+
+//gen chunk nonce
+func (f *Stream) chunkNonce(size int, counter uint32, last bool) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint32(nonce[size-5:size-1], counter)
+	if last {
+		nonce[size-1] = 1
+	}
+	return nonce
+}
+
+//process file by temp file and rename
+func (f *Stream) processFile(srcPath, dstPath string, cb func(io.Writer, io.Reader) error) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(dstPath), filepath.Base(dstPath) + ".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	writer := bufio.NewWriter(tmp)
+	if err = cb(writer, src); err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dstPath)
+}
@@ -0,0 +1,169 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/*
+ * fluent sql query builder
+ * - deterministic and parameterized sql
+ * - identifier quoting by dialect
+ * - usable by `mysql.Connect` and `sqlite.SqlLite`
+ *
+ * like:
+ * Select("id", "name", Raw("COUNT(*) AS total")).From("user").
+ *   Where(Eq("status", 1), Or(Gt("age", 18), In("role", "admin", "vip"))).
+ *   OrderByDesc("id").Limit(10).ToSql()
+ */
+
+//dialect
+const (
+	DialectOfMysql = "mysql"
+	DialectOfSqlite = "sqlite"
+)
+
+//raw sql kept without quoting, like Raw("COUNT(*) AS total")
+type Raw string
+
+//builder interface
+type IBuilder interface {
+	ToSql() (string, []interface{}, error)
+}
+
+//face info
+type Builder struct {
+	dialect string
+}
+
+//construct
+func New(dialects ...string) *Builder {
+	this := &Builder{
+		dialect: DialectOfMysql,
+	}
+	if dialects != nil && len(dialects) > 0 && dialects[0] != "" {
+		this.dialect = dialects[0]
+	}
+	return this
+}
+
+//create select builder
+//column is identifier string or `Raw`
+func (f *Builder) Select(columns ...interface{}) *SelectBuilder {
+	return newSelectBuilder(f.dialect, columns...)
+}
+
+//create insert builder
+func (f *Builder) Insert(table string) *InsertBuilder {
+	return newInsertBuilder(f.dialect, table)
+}
+
+//create update builder
+func (f *Builder) Update(table string) *UpdateBuilder {
+	return newUpdateBuilder(f.dialect, table)
+}
+
+//create delete builder
+func (f *Builder) Delete(table string) *DeleteBuilder {
+	return newDeleteBuilder(f.dialect, table)
+}
+
+//create select builder of mysql dialect
+//column is identifier string or `Raw`
+func Select(columns ...interface{}) *SelectBuilder {
+	return newSelectBuilder(DialectOfMysql, columns...)
+}
+
+//create insert builder of mysql dialect
+func Insert(table string) *InsertBuilder {
+	return newInsertBuilder(DialectOfMysql, table)
+}
+
+//create update builder of mysql dialect
+func Update(table string) *UpdateBuilder {
+	return newUpdateBuilder(DialectOfMysql, table)
+}
+
+//create delete builder of mysql dialect
+func Delete(table string) *DeleteBuilder {
+	return newDeleteBuilder(DialectOfMysql, table)
+}
+
+//quote identifier by dialect, like `table`.`name`
+//dot split qualifier, quote char inside escaped by doubling, `*` kept
+//use `Raw` for expressions like `COUNT(*)`
+func QuoteIdent(dialect, ident string) string {
+	quote := "`"
+	if dialect == DialectOfSqlite {
+		quote = `"`
+	}
+	parts := strings.Split(strings.TrimSpace(ident), ".")
+	for i, part := range parts {
+		if part == "*" {
+			continue
+		}
+		parts[i] = quote + strings.Replace(part, quote, quote + quote, -1) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+////////////////
+//private func
+////////////////
+
+//quote identifier list
+func quoteIdents(dialect string, idents []string) string {
+	buf := bytes.NewBuffer(nil)
+	for i, ident := range idents {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(QuoteIdent(dialect, ident))
+	}
+	return buf.String()
+}
+
+//quote identifier string, or keep `Raw`
+func quoteColumn(dialect string, column interface{}) (string, error) {
+	switch v := column.(type) {
+	case Raw:
+		return string(v), nil
+	case string:
+		return QuoteIdent(dialect, v), nil
+	}
+	return "", fmt.Errorf("invalid column type %T", column)
+}
+
+//quote column list
+func quoteColumns(dialect string, columns []interface{}) (string, error) {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		sql, err := quoteColumn(dialect, column)
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, sql)
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+//gen placeholders, like ?, ?, ?
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n - 1) + "?"
+}
+
+//build where sql of conditions, joined by AND
+func buildWhere(dialect string, conds []Cond) (string, []interface{}, error) {
+	if len(conds) <= 0 {
+		return "", nil, nil
+	}
+	sql, args, err := And(conds...).Build(dialect)
+	if err != nil {
+		return "", nil, err
+	}
+	return " WHERE " + sql, args, nil
+}
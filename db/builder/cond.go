@@ -0,0 +1,256 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+ * where conditions
+ */
+
+//condition interface
+type Cond interface {
+	Build(dialect string) (string, []interface{}, error)
+}
+
+//compare condition, like `field` > ?
+type compareCond struct {
+	field string
+	op string
+	val interface{}
+}
+
+//in condition
+type inCond struct {
+	field string
+	vals []interface{}
+	not bool
+}
+
+//null condition
+type nullCond struct {
+	field string
+	not bool
+}
+
+//between condition
+type betweenCond struct {
+	field string
+	from, to interface{}
+}
+
+//logic condition, AND or OR
+type logicCond struct {
+	op string
+	conds []Cond
+}
+
+//not condition
+type notCond struct {
+	cond Cond
+}
+
+//raw expression condition
+type exprCond struct {
+	sql string
+	args []interface{}
+}
+
+//field = val
+func Eq(field string, val interface{}) Cond {
+	return &compareCond{field: field, op: "=", val: val}
+}
+
+//field != val
+func Neq(field string, val interface{}) Cond {
+	return &compareCond{field: field, op: "!=", val: val}
+}
+
+//field > val
+func Gt(field string, val interface{}) Cond {
+	return &compareCond{field: field, op: ">", val: val}
+}
+
+//field >= val
+func Gte(field string, val interface{}) Cond {
+	return &compareCond{field: field, op: ">=", val: val}
+}
+
+//field < val
+func Lt(field string, val interface{}) Cond {
+	return &compareCond{field: field, op: "<", val: val}
+}
+
+//field <= val
+func Lte(field string, val interface{}) Cond {
+	return &compareCond{field: field, op: "<=", val: val}
+}
+
+//field LIKE val
+func Like(field string, val interface{}) Cond {
+	return &compareCond{field: field, op: "LIKE", val: val}
+}
+
+//field IN(vals), vals can be values or single slice
+func In(field string, vals ...interface{}) Cond {
+	return &inCond{field: field, vals: flattenVals(vals)}
+}
+
+//field NOT IN(vals)
+func NotIn(field string, vals ...interface{}) Cond {
+	return &inCond{field: field, vals: flattenVals(vals), not: true}
+}
+
+//field IS NULL
+func IsNull(field string) Cond {
+	return &nullCond{field: field}
+}
+
+//field IS NOT NULL
+func IsNotNull(field string) Cond {
+	return &nullCond{field: field, not: true}
+}
+
+//field BETWEEN from AND to
+func Between(field string, from, to interface{}) Cond {
+	return &betweenCond{field: field, from: from, to: to}
+}
+
+//conditions joined by AND, empty is always true
+func And(conds ...Cond) Cond {
+	return &logicCond{op: "AND", conds: conds}
+}
+
+//conditions joined by OR, empty is always false
+func Or(conds ...Cond) Cond {
+	return &logicCond{op: "OR", conds: conds}
+}
+
+//NOT (cond)
+func Not(cond Cond) Cond {
+	return &notCond{cond: cond}
+}
+
+//raw sql expression, like Expr("FIND_IN_SET(?, tags)", "x")
+func Expr(sql string, args ...interface{}) Cond {
+	return &exprCond{sql: sql, args: args}
+}
+
+func (c *compareCond) Build(dialect string) (string, []interface{}, error) {
+	if c.field == "" {
+		return "", nil, errors.New("empty field of condition")
+	}
+	return fmt.Sprintf("%s %s ?", QuoteIdent(dialect, c.field), c.op), []interface{}{c.val}, nil
+}
+
+func (c *inCond) Build(dialect string) (string, []interface{}, error) {
+	if c.field == "" {
+		return "", nil, errors.New("empty field of condition")
+	}
+	if len(c.vals) <= 0 {
+		//empty set, IN always false, NOT IN always true
+		if c.not {
+			return "1 = 1", nil, nil
+		}
+		return "1 = 0", nil, nil
+	}
+	op := "IN"
+	if c.not {
+		op = "NOT IN"
+	}
+	return fmt.Sprintf("%s %s (%s)", QuoteIdent(dialect, c.field), op, placeholders(len(c.vals))), c.vals, nil
+}
+
+func (c *nullCond) Build(dialect string) (string, []interface{}, error) {
+	if c.field == "" {
+		return "", nil, errors.New("empty field of condition")
+	}
+	if c.not {
+		return QuoteIdent(dialect, c.field) + " IS NOT NULL", nil, nil
+	}
+	return QuoteIdent(dialect, c.field) + " IS NULL", nil, nil
+}
+
+func (c *betweenCond) Build(dialect string) (string, []interface{}, error) {
+	if c.field == "" {
+		return "", nil, errors.New("empty field of condition")
+	}
+	return QuoteIdent(dialect, c.field) + " BETWEEN ? AND ?", []interface{}{c.from, c.to}, nil
+}
+
+func (c *logicCond) Build(dialect string) (string, []interface{}, error) {
+	parts := make([]string, 0, len(c.conds))
+	args := make([]interface{}, 0)
+	for _, cond := range c.conds {
+		if cond == nil {
+			continue
+		}
+		sql, condArgs, err := cond.Build(dialect)
+		if err != nil {
+			return "", nil, err
+		}
+		if !isAtomicCond(cond) {
+			sql = "(" + sql + ")"
+		}
+		parts = append(parts, sql)
+		args = append(args, condArgs...)
+	}
+	if len(parts) <= 0 {
+		if c.op == "OR" {
+			return "1 = 0", nil, nil
+		}
+		return "1 = 1", nil, nil
+	}
+	return strings.Join(parts, " " + c.op + " "), args, nil
+}
+
+func (c *notCond) Build(dialect string) (string, []interface{}, error) {
+	if c.cond == nil {
+		return "", nil, errors.New("empty condition of not")
+	}
+	sql, args, err := c.cond.Build(dialect)
+	if err != nil {
+		return "", nil, err
+	}
+	return "NOT (" + sql + ")", args, nil
+}
+
+func (c *exprCond) Build(dialect string) (string, []interface{}, error) {
+	if c.sql == "" {
+		return "", nil, errors.New("empty expression")
+	}
+	return c.sql, c.args, nil
+}
+
+////////////////
+//private func
+////////////////
+
+//check condition sql bind tighter than AND and OR
+//expression and custom condition may contain AND or OR
+func isAtomicCond(cond Cond) bool {
+	switch cond.(type) {
+	case *compareCond, *inCond, *nullCond, *betweenCond, *notCond:
+		return true
+	}
+	return false
+}
+
+//flatten single slice value into values
+func flattenVals(vals []interface{}) []interface{} {
+	if len(vals) != 1 || vals[0] == nil {
+		return vals
+	}
+	refVal := reflect.ValueOf(vals[0])
+	if refVal.Kind() != reflect.Slice || refVal.Type().Elem().Kind() == reflect.Uint8 {
+		return vals
+	}
+	result := make([]interface{}, 0, refVal.Len())
+	for i := 0; i < refVal.Len(); i++ {
+		result = append(result, refVal.Index(i).Interface())
+	}
+	return result
+}
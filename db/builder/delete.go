@@ -0,0 +1,55 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+//face info
+type DeleteBuilder struct {
+	dialect string
+	table string
+	conds []Cond
+	limit int
+}
+
+//construct
+func newDeleteBuilder(dialect, table string) *DeleteBuilder {
+	this := &DeleteBuilder{
+		dialect: dialect,
+		table: table,
+	}
+	return this
+}
+
+//add conditions, joined by AND
+func (f *DeleteBuilder) Where(conds ...Cond) *DeleteBuilder {
+	f.conds = append(f.conds, conds...)
+	return f
+}
+
+//set limit, mysql only
+func (f *DeleteBuilder) Limit(limit int) *DeleteBuilder {
+	f.limit = limit
+	return f
+}
+
+//gen sql and args
+func (f *DeleteBuilder) ToSql() (string, []interface{}, error) {
+	if f.table == "" {
+		return "", nil, errors.New("table not set")
+	}
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("DELETE FROM ")
+	buf.WriteString(QuoteIdent(f.dialect, f.table))
+	whereSql, args, err := buildWhere(f.dialect, f.conds)
+	if err != nil {
+		return "", nil, err
+	}
+	buf.WriteString(whereSql)
+	if f.limit > 0 && f.dialect == DialectOfMysql {
+		buf.WriteString(fmt.Sprintf(" LIMIT %d", f.limit))
+	}
+	return buf.String(), args, nil
+}
@@ -0,0 +1,146 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+)
+
+//face info
+type InsertBuilder struct {
+	dialect string
+	table string
+	columns []string
+	rows [][]interface{}
+	ignore bool
+	conflictKeys []string
+	updateColumns []string
+	updateExprs []updateSet
+}
+
+//construct
+func newInsertBuilder(dialect, table string) *InsertBuilder {
+	this := &InsertBuilder{
+		dialect: dialect,
+		table: table,
+	}
+	return this
+}
+
+//set columns
+func (f *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	f.columns = columns
+	return f
+}
+
+//add one row values, should match columns
+func (f *InsertBuilder) Values(vals ...interface{}) *InsertBuilder {
+	f.rows = append(f.rows, vals)
+	return f
+}
+
+//set columns and values by map, columns sorted for deterministic sql
+//should be called once, or with same keys for multi rows
+func (f *InsertBuilder) SetMap(data map[string]interface{}) *InsertBuilder {
+	columns := make([]string, 0, len(data))
+	for k := range data {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	vals := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		vals = append(vals, data[column])
+	}
+	f.columns = columns
+	f.rows = append(f.rows, vals)
+	return f
+}
+
+//ignore duplicate rows
+func (f *InsertBuilder) Ignore() *InsertBuilder {
+	f.ignore = true
+	return f
+}
+
+//upsert, update columns by inserted values when conflict
+//conflict keys used by sqlite, mysql use unique keys of table
+func (f *InsertBuilder) OnConflict(conflictKeys []string, updateColumns ...string) *InsertBuilder {
+	f.conflictKeys = conflictKeys
+	f.updateColumns = append(f.updateColumns, updateColumns...)
+	return f
+}
+
+//upsert, update column by expression when conflict
+//like: OnConflictExpr("count", "count + ?", 1)
+func (f *InsertBuilder) OnConflictExpr(column, expr string, args ...interface{}) *InsertBuilder {
+	f.updateExprs = append(f.updateExprs, updateSet{column: column, expr: expr, args: args})
+	return f
+}
+
+//gen sql and args
+func (f *InsertBuilder) ToSql() (string, []interface{}, error) {
+	var (
+		args = make([]interface{}, 0)
+		buf = bytes.NewBuffer(nil)
+	)
+	//check
+	if f.table == "" || len(f.columns) <= 0 || len(f.rows) <= 0 {
+		return "", nil, errors.New("table, columns or values not set")
+	}
+
+	//insert head
+	if f.ignore {
+		if f.dialect == DialectOfSqlite {
+			buf.WriteString("INSERT OR IGNORE INTO ")
+		}else{
+			buf.WriteString("INSERT IGNORE INTO ")
+		}
+	}else{
+		buf.WriteString("INSERT INTO ")
+	}
+	buf.WriteString(QuoteIdent(f.dialect, f.table))
+	buf.WriteString(" (")
+	buf.WriteString(quoteIdents(f.dialect, f.columns))
+	buf.WriteString(") VALUES ")
+
+	//values
+	for i, row := range f.rows {
+		if len(row) != len(f.columns) {
+			return "", nil, errors.New("values not match columns")
+		}
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString("(" + placeholders(len(row)) + ")")
+		args = append(args, row...)
+	}
+
+	//upsert
+	if len(f.updateColumns) <= 0 && len(f.updateExprs) <= 0 {
+		return buf.String(), args, nil
+	}
+	sets := make([]string, 0, len(f.updateColumns) + len(f.updateExprs))
+	for _, column := range f.updateColumns {
+		quoted := QuoteIdent(f.dialect, column)
+		if f.dialect == DialectOfSqlite {
+			sets = append(sets, quoted + " = excluded." + quoted)
+		}else{
+			sets = append(sets, quoted + " = VALUES(" + quoted + ")")
+		}
+	}
+	for _, set := range f.updateExprs {
+		sets = append(sets, QuoteIdent(f.dialect, set.column) + " = " + set.expr)
+		args = append(args, set.args...)
+	}
+	if f.dialect == DialectOfSqlite {
+		if len(f.conflictKeys) <= 0 {
+			return "", nil, errors.New("conflict keys required for sqlite upsert")
+		}
+		buf.WriteString(" ON CONFLICT (" + quoteIdents(f.dialect, f.conflictKeys) + ") DO UPDATE SET ")
+	}else{
+		buf.WriteString(" ON DUPLICATE KEY UPDATE ")
+	}
+	buf.WriteString(strings.Join(sets, ", "))
+	return buf.String(), args, nil
+}
@@ -0,0 +1,177 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//order by info
+type orderBy struct {
+	field interface{}
+	desc bool
+}
+
+//face info
+type SelectBuilder struct {
+	dialect string
+	columns []interface{}
+	table string
+	conds []Cond
+	groupBy []interface{}
+	having []Cond
+	orders []orderBy
+	limit int
+	offset int
+	forUpdate bool
+}
+
+//construct
+func newSelectBuilder(dialect string, columns ...interface{}) *SelectBuilder {
+	this := &SelectBuilder{
+		dialect: dialect,
+		columns: columns,
+	}
+	return this
+}
+
+//set table
+func (f *SelectBuilder) From(table string) *SelectBuilder {
+	f.table = table
+	return f
+}
+
+//add conditions, joined by AND
+func (f *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	f.conds = append(f.conds, conds...)
+	return f
+}
+
+//add group by fields, field is identifier string or `Raw`
+func (f *SelectBuilder) GroupBy(fields ...interface{}) *SelectBuilder {
+	f.groupBy = append(f.groupBy, fields...)
+	return f
+}
+
+//add having conditions
+func (f *SelectBuilder) Having(conds ...Cond) *SelectBuilder {
+	f.having = append(f.having, conds...)
+	return f
+}
+
+//order by field asc, field is identifier string or `Raw`
+func (f *SelectBuilder) OrderBy(fields ...interface{}) *SelectBuilder {
+	for _, field := range fields {
+		f.orders = append(f.orders, orderBy{field: field})
+	}
+	return f
+}
+
+//order by field desc, field is identifier string or `Raw`
+func (f *SelectBuilder) OrderByDesc(fields ...interface{}) *SelectBuilder {
+	for _, field := range fields {
+		f.orders = append(f.orders, orderBy{field: field, desc: true})
+	}
+	return f
+}
+
+//set limit
+func (f *SelectBuilder) Limit(limit int) *SelectBuilder {
+	f.limit = limit
+	return f
+}
+
+//set offset
+func (f *SelectBuilder) Offset(offset int) *SelectBuilder {
+	f.offset = offset
+	return f
+}
+
+//lock selected rows, mysql only
+func (f *SelectBuilder) ForUpdate() *SelectBuilder {
+	f.forUpdate = true
+	return f
+}
+
+//gen sql and args
+func (f *SelectBuilder) ToSql() (string, []interface{}, error) {
+	var (
+		args = make([]interface{}, 0)
+		buf = bytes.NewBuffer(nil)
+	)
+	if f.table == "" {
+		return "", nil, errors.New("table not set")
+	}
+
+	//columns and table
+	buf.WriteString("SELECT ")
+	if len(f.columns) <= 0 {
+		buf.WriteString("*")
+	}else{
+		columns, err := quoteColumns(f.dialect, f.columns)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(columns)
+	}
+	buf.WriteString(" FROM ")
+	buf.WriteString(QuoteIdent(f.dialect, f.table))
+
+	//where
+	whereSql, whereArgs, err := buildWhere(f.dialect, f.conds)
+	if err != nil {
+		return "", nil, err
+	}
+	buf.WriteString(whereSql)
+	args = append(args, whereArgs...)
+
+	//group by and having
+	if len(f.groupBy) > 0 {
+		groupBy, err := quoteColumns(f.dialect, f.groupBy)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(" GROUP BY ")
+		buf.WriteString(groupBy)
+	}
+	if len(f.having) > 0 {
+		havingSql, havingArgs, err := And(f.having...).Build(f.dialect)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(" HAVING ")
+		buf.WriteString(havingSql)
+		args = append(args, havingArgs...)
+	}
+
+	//order by
+	if len(f.orders) > 0 {
+		orders := make([]string, 0, len(f.orders))
+		for _, order := range f.orders {
+			direction := "ASC"
+			if order.desc {
+				direction = "DESC"
+			}
+			field, err := quoteColumn(f.dialect, order.field)
+			if err != nil {
+				return "", nil, err
+			}
+			orders = append(orders, field + " " + direction)
+		}
+		buf.WriteString(" ORDER BY ")
+		buf.WriteString(strings.Join(orders, ", "))
+	}
+
+	//limit and offset
+	if f.limit > 0 {
+		buf.WriteString(fmt.Sprintf(" LIMIT %d", f.limit))
+		if f.offset > 0 {
+			buf.WriteString(fmt.Sprintf(" OFFSET %d", f.offset))
+		}
+	}
+	if f.forUpdate && f.dialect == DialectOfMysql {
+		buf.WriteString(" FOR UPDATE")
+	}
+	return buf.String(), args, nil
+}
@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+//update set info
+type updateSet struct {
+	column string
+	expr string //empty means column = ?
+	args []interface{}
+}
+
+//face info
+type UpdateBuilder struct {
+	dialect string
+	table string
+	sets []updateSet
+	conds []Cond
+	limit int
+}
+
+//construct
+func newUpdateBuilder(dialect, table string) *UpdateBuilder {
+	this := &UpdateBuilder{
+		dialect: dialect,
+		table: table,
+	}
+	return this
+}
+
+//set column = val
+func (f *UpdateBuilder) Set(column string, val interface{}) *UpdateBuilder {
+	f.sets = append(f.sets, updateSet{column: column, args: []interface{}{val}})
+	return f
+}
+
+//set columns by map, columns sorted for deterministic sql
+func (f *UpdateBuilder) SetMap(data map[string]interface{}) *UpdateBuilder {
+	columns := make([]string, 0, len(data))
+	for k := range data {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		f.Set(column, data[column])
+	}
+	return f
+}
+
+//set column by expression, like SetExpr("count", "count + ?", 1)
+func (f *UpdateBuilder) SetExpr(column, expr string, args ...interface{}) *UpdateBuilder {
+	f.sets = append(f.sets, updateSet{column: column, expr: expr, args: args})
+	return f
+}
+
+//add conditions, joined by AND
+func (f *UpdateBuilder) Where(conds ...Cond) *UpdateBuilder {
+	f.conds = append(f.conds, conds...)
+	return f
+}
+
+//set limit, mysql only
+func (f *UpdateBuilder) Limit(limit int) *UpdateBuilder {
+	f.limit = limit
+	return f
+}
+
+//gen sql and args
+func (f *UpdateBuilder) ToSql() (string, []interface{}, error) {
+	var (
+		args = make([]interface{}, 0)
+		buf = bytes.NewBuffer(nil)
+	)
+	if f.table == "" || len(f.sets) <= 0 {
+		return "", nil, errors.New("table or set values not set")
+	}
+
+	//set values
+	buf.WriteString("UPDATE ")
+	buf.WriteString(QuoteIdent(f.dialect, f.table))
+	buf.WriteString(" SET ")
+	for i, set := range f.sets {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(QuoteIdent(f.dialect, set.column))
+		if set.expr == "" {
+			buf.WriteString(" = ?")
+		}else{
+			buf.WriteString(" = " + set.expr)
+		}
+		args = append(args, set.args...)
+	}
+
+	//where and limit
+	whereSql, whereArgs, err := buildWhere(f.dialect, f.conds)
+	if err != nil {
+		return "", nil, err
+	}
+	buf.WriteString(whereSql)
+	args = append(args, whereArgs...)
+	if f.limit > 0 && f.dialect == DialectOfMysql {
+		buf.WriteString(fmt.Sprintf(" LIMIT %d", f.limit))
+	}
+	return buf.String(), args, nil
+}
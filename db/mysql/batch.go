@@ -0,0 +1,500 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinycells/db/builder"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * batch insert and upsert for json data
+ * - rows chunked into multi rows `INSERT ... VALUES (...),(...)`
+ * - chunk size limited by rows, placeholders and `max_allowed_packet`,
+ *   packet size queried on primary once and cached in connect
+ * - `ON DUPLICATE KEY UPDATE` support replace, increase and json merge
+ * - `LOAD DATA LOCAL INFILE` for very large import, need `local_infile` on server
+ * - table and field names quoted as identifier
+ */
+
+//upsert update mode
+const (
+	BatchUpdateOfReplace = iota //field = VALUES(field)
+	BatchUpdateOfInc //field = field + VALUES(field)
+	BatchUpdateOfJsonMerge //field = JSON_MERGE_PATCH(field, VALUES(field))
+)
+
+//default value
+const (
+	BatchMaxRows = 1000
+	BatchMaxPlaceholders = 65535
+	BatchDefaultPacket = 4 * 1024 * 1024 //bytes
+	BatchPacketReserve = 1024 //bytes reserved for packet header, etc.
+)
+
+//batch option
+type BatchOption struct {
+	MaxRows int //max rows per statement
+	MaxPacket int //max bytes per statement, 0 means use server `max_allowed_packet`
+	Ignore bool //INSERT IGNORE
+	StopOnError bool //stop at first failed chunk
+}
+
+//batch chunk sql
+type BatchSql struct {
+	Sql string
+	Values []interface{}
+	Rows int
+}
+
+//batch chunk result
+type BatchResult struct {
+	Index int //chunk index
+	Rows int //rows of chunk
+	LastInsertId int64
+	EffectRows int64
+	Err error
+}
+
+//load data option
+type LoadDataOption struct {
+	FieldsTerminated string
+	Enclosed string
+	LinesTerminated string
+	IgnoreLines int
+	Replace bool //replace duplicate rows, default is ignore
+}
+
+//inter reader handler seq
+var loadDataSeq int64
+
+//gen default batch option
+func NewBatchOption() *BatchOption {
+	return &BatchOption{
+		MaxRows: BatchMaxRows,
+	}
+}
+
+//gen default load data option, csv format
+func NewLoadDataOption() *LoadDataOption {
+	return &LoadDataOption{
+		FieldsTerminated: ",",
+		Enclosed: "\"",
+		LinesTerminated: "\n",
+	}
+}
+
+//batch add json data into data field
+func (f *JsonData) AddBatchData(
+			jsonBytes [][]byte,
+			table string,
+			db *Connect,
+			opts ...*BatchOption,
+		) ([]*BatchResult, error) {
+	rows := make([]map[string]interface{}, 0, len(jsonBytes))
+	for _, v := range jsonBytes {
+		rows = append(rows, map[string]interface{}{
+			TableFieldOfData: v,
+		})
+	}
+	return f.AddBatchDataAdv(rows, table, db, opts...)
+}
+
+//batch add rows, all rows should has same fields
+//return result of each chunk, and first error
+func (f *JsonData) AddBatchDataAdv(
+			rows []map[string]interface{},
+			table string,
+			db *Connect,
+			opts ...*BatchOption,
+		) ([]*BatchResult, error) {
+	return f.UpsertBatchData(rows, nil, table, db, opts...)
+}
+
+//batch add rows with on duplicate update
+//updateMap is field -> update mode, like:
+//{"data": BatchUpdateOfJsonMerge, "count": BatchUpdateOfInc}
+func (f *JsonData) UpsertBatchData(
+			rows []map[string]interface{},
+			updateMap map[string]int,
+			table string,
+			db *Connect,
+			opts ...*BatchOption,
+		) ([]*BatchResult, error) {
+	return f.UpsertBatchDataContext(context.Background(), rows, updateMap, table, db, opts...)
+}
+
+//batch add rows with on duplicate update and context
+func (f *JsonData) UpsertBatchDataContext(
+			ctx context.Context,
+			rows []map[string]interface{},
+			updateMap map[string]int,
+			table string,
+			db *Connect,
+			opts ...*BatchOption,
+		) ([]*BatchResult, error) {
+	var (
+		firstErr error
+	)
+
+	//check
+	if db == nil {
+		return nil, errors.New("invalid parameter")
+	}
+	opt := NewBatchOption()
+	if opts != nil && len(opts) > 0 && opts[0] != nil {
+		//copy, option of caller kept
+		optCopy := *opts[0]
+		opt = &optCopy
+	}
+	if opt.MaxPacket <= 0 {
+		opt.MaxPacket = f.getMaxPacket(ctx, db)
+	}
+
+	//gen chunk sql
+	chunks, err := f.GenBatchInsert(rows, updateMap, table, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	//run chunks
+	results := make([]*BatchResult, 0, len(chunks))
+	for i, chunk := range chunks {
+		lastInsertId, effectRows, err := db.ExecuteContext(ctx, chunk.Sql, chunk.Values...)
+		results = append(results, &BatchResult{
+			Index: i,
+			Rows: chunk.Rows,
+			LastInsertId: lastInsertId,
+			EffectRows: effectRows,
+			Err: err,
+		})
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("chunk %v failed, err:%v", i, err)
+			if opt.StopOnError {
+				break
+			}
+		}
+	}
+	return results, firstErr
+}
+
+//gen chunked batch insert sql
+//if updateMap not empty, append `ON DUPLICATE KEY UPDATE`
+func (f *JsonData) GenBatchInsert(
+			rows []map[string]interface{},
+			updateMap map[string]int,
+			table string,
+			opt *BatchOption,
+		) ([]*BatchSql, error) {
+	//check
+	if rows == nil || len(rows) <= 0 || table == "" {
+		return nil, errors.New("invalid parameter")
+	}
+	if opt == nil {
+		opt = NewBatchOption()
+	}
+	maxRows := opt.MaxRows
+	if maxRows <= 0 {
+		maxRows = BatchMaxRows
+	}
+	maxPacket := opt.MaxPacket
+	if maxPacket <= 0 {
+		maxPacket = BatchDefaultPacket
+	}
+
+	//sorted fields of first row
+	fields := make([]string, 0, len(rows[0]))
+	for k := range rows[0] {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	if len(fields) <= 0 {
+		return nil, errors.New("row fields is empty")
+	}
+	if maxRows * len(fields) > BatchMaxPlaceholders {
+		maxRows = BatchMaxPlaceholders / len(fields)
+	}
+
+	//format sql head and tail
+	head := "INSERT INTO"
+	if opt.Ignore {
+		head = "INSERT IGNORE INTO"
+	}
+	head = fmt.Sprintf("%s %s(%s) VALUES", head, quoteIdent(table), quoteIdents(fields))
+	tail := f.formatDuplicateSql(updateMap)
+	rowHolder := fmt.Sprintf("(%s)", strings.TrimSuffix(strings.Repeat("?,", len(fields)), ","))
+
+	//chunk rows
+	chunks := make([]*BatchSql, 0)
+	var (
+		buffer *bytes.Buffer
+		chunk *BatchSql
+		chunkSize int
+	)
+	flush := func() {
+		if chunk == nil {
+			return
+		}
+		buffer.WriteString(tail)
+		chunk.Sql = buffer.String()
+		chunks = append(chunks, chunk)
+		chunk = nil
+	}
+	for i, row := range rows {
+		if len(row) != len(fields) {
+			return nil, fmt.Errorf("row %v fields not match", i)
+		}
+		values := make([]interface{}, 0, len(fields))
+		rowSize := len(rowHolder) + 1
+		for _, field := range fields {
+			v, ok := row[field]
+			if !ok {
+				return nil, fmt.Errorf("row %v field %v missing", i, field)
+			}
+			values = append(values, v)
+			rowSize += f.getValueSize(v)
+		}
+
+		//new chunk if over limit
+		if chunk != nil && (chunk.Rows >= maxRows || chunkSize + rowSize > maxPacket - BatchPacketReserve) {
+			flush()
+		}
+		if chunk == nil {
+			buffer = bytes.NewBuffer(nil)
+			buffer.WriteString(head)
+			chunk = &BatchSql{
+				Values: make([]interface{}, 0),
+			}
+			chunkSize = len(head) + len(tail)
+		}else{
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(rowHolder)
+		chunk.Values = append(chunk.Values, values...)
+		chunk.Rows++
+		chunkSize += rowSize
+	}
+	flush()
+	return chunks, nil
+}
+
+//load local file into table, file should be readable by current process
+//file streamed by reader handler, local file registration of driver not touched
+//return effect rows
+func (f *JsonData) LoadDataFile(
+			filePath string,
+			fields []string,
+			table string,
+			db *Connect,
+			opts ...*LoadDataOption,
+		) (int64, error) {
+	return f.LoadDataFileContext(context.Background(), filePath, fields, table, db, opts...)
+}
+
+//load local file into table with context
+//return effect rows
+func (f *JsonData) LoadDataFileContext(
+			ctx context.Context,
+			filePath string,
+			fields []string,
+			table string,
+			db *Connect,
+			opts ...*LoadDataOption,
+		) (int64, error) {
+	//check
+	if filePath == "" || table == "" || db == nil {
+		return 0, errors.New("invalid parameter")
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return f.LoadDataReaderContext(ctx, file, fields, table, db, opts...)
+}
+
+//load data from reader into table, like csv stream
+//if reader is io.ReadCloser, it will be closed after load
+//return effect rows
+func (f *JsonData) LoadDataReader(
+			reader io.Reader,
+			fields []string,
+			table string,
+			db *Connect,
+			opts ...*LoadDataOption,
+		) (int64, error) {
+	return f.LoadDataReaderContext(context.Background(), reader, fields, table, db, opts...)
+}
+
+//load data from reader into table with context
+//return effect rows
+func (f *JsonData) LoadDataReaderContext(
+			ctx context.Context,
+			reader io.Reader,
+			fields []string,
+			table string,
+			db *Connect,
+			opts ...*LoadDataOption,
+		) (int64, error) {
+	//check
+	if reader == nil || table == "" || db == nil {
+		return 0, errors.New("invalid parameter")
+	}
+	name := fmt.Sprintf("tc_load_%d_%d", time.Now().UnixNano(), atomic.AddInt64(&loadDataSeq, 1))
+	mysqlDriver.RegisterReaderHandler(name, func() io.Reader {
+		return reader
+	})
+	defer mysqlDriver.DeregisterReaderHandler(name)
+	return f.loadData(ctx, "Reader::" + name, fields, table, db, opts...)
+}
+
+////////////////
+//private func
+////////////////
+
+//run load data sql
+//LOAD DATA can't be prepared, run on db directly
+func (f *JsonData) loadData(
+			ctx context.Context,
+			name string,
+			fields []string,
+			table string,
+			db *Connect,
+			opts ...*LoadDataOption,
+		) (int64, error) {
+	opt := NewLoadDataOption()
+	if opts != nil && len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+	sqlDB, err := db.getDB()
+	if err != nil {
+		return 0, err
+	}
+
+	//format sql
+	buffer := bytes.NewBuffer(nil)
+	buffer.WriteString(fmt.Sprintf("LOAD DATA LOCAL INFILE %s", f.quoteString(name)))
+	if opt.Replace {
+		buffer.WriteString(" REPLACE")
+	}else{
+		buffer.WriteString(" IGNORE")
+	}
+	buffer.WriteString(fmt.Sprintf(" INTO TABLE %s", quoteIdent(table)))
+	if opt.FieldsTerminated != "" {
+		buffer.WriteString(fmt.Sprintf(" FIELDS TERMINATED BY %s", f.quoteString(opt.FieldsTerminated)))
+		if opt.Enclosed != "" {
+			buffer.WriteString(fmt.Sprintf(" OPTIONALLY ENCLOSED BY %s", f.quoteString(opt.Enclosed)))
+		}
+	}
+	if opt.LinesTerminated != "" {
+		buffer.WriteString(fmt.Sprintf(" LINES TERMINATED BY %s", f.quoteString(opt.LinesTerminated)))
+	}
+	if opt.IgnoreLines > 0 {
+		buffer.WriteString(fmt.Sprintf(" IGNORE %d LINES", opt.IgnoreLines))
+	}
+	if fields != nil && len(fields) > 0 {
+		buffer.WriteString(fmt.Sprintf(" (%s)", quoteIdents(fields)))
+	}
+
+	//exec sql
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	beginTime := time.Now()
+	result, err := sqlDB.ExecContext(ctx, buffer.String())
+	db.logSlow(beginTime, buffer.String())
+	db.markResult(err)
+	if err != nil {
+		return 0, err
+	}
+	effectRows, _ := result.RowsAffected()
+	return effectRows, nil
+}
+
+//format on duplicate update sql
+func (f *JsonData) formatDuplicateSql(updateMap map[string]int) string {
+	if updateMap == nil || len(updateMap) <= 0 {
+		return ""
+	}
+	fields := make([]string, 0, len(updateMap))
+	for k := range updateMap {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	updates := make([]string, 0, len(fields))
+	for _, field := range fields {
+		quoted := quoteIdent(field)
+		switch updateMap[field] {
+		case BatchUpdateOfInc:
+			updates = append(updates, fmt.Sprintf("%s = %s + VALUES(%s)", quoted, quoted, quoted))
+		case BatchUpdateOfJsonMerge:
+			updates = append(updates, fmt.Sprintf("%s = JSON_MERGE_PATCH(IFNULL(%s, JSON_OBJECT()), VALUES(%s))",
+				quoted, quoted, quoted))
+		default:
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", quoted, quoted))
+		}
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+//get max allowed packet of server
+//query on primary once and cached in connect, default size if failed
+func (f *JsonData) getMaxPacket(ctx context.Context, db *Connect) int {
+	origin := db.getOrigin()
+	if size := atomic.LoadInt64(&origin.maxPacket); size > 0 {
+		return int(size)
+	}
+	record, err := origin.UsePrimary().GetRowContext(ctx, "SELECT @@max_allowed_packet AS max_packet")
+	if err != nil {
+		return BatchDefaultPacket
+	}
+	size := f.getIntegerVal("max_packet", record)
+	if size <= 0 {
+		return BatchDefaultPacket
+	}
+	atomic.StoreInt64(&origin.maxPacket, size)
+	return int(size)
+}
+
+//estimate value size in packet
+func (f *JsonData) getValueSize(v interface{}) int {
+	switch val := v.(type) {
+	case []byte:
+		return len(val) + 2
+	case string:
+		return len(val) + 2
+	case nil:
+		return 4
+	}
+	return 20
+}
+
+//quote string for sql
+func (f *JsonData) quoteString(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "'", "\\'", -1)
+	s = strings.Replace(s, "\n", "\\n", -1)
+	s = strings.Replace(s, "\t", "\\t", -1)
+	return "'" + s + "'"
+}
+
+//quote identifier of mysql
+func quoteIdent(ident string) string {
+	return builder.QuoteIdent(builder.DialectOfMysql, ident)
+}
+
+//quote identifier list of mysql
+func quoteIdents(idents []string) string {
+	quoted := make([]string, 0, len(idents))
+	for _, ident := range idents {
+		quoted = append(quoted, quoteIdent(ident))
+	}
+	return strings.Join(quoted, ",")
+}
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"context"
+	"github.com/andyzhou/tinycells/db/builder"
+)
+
+/*
+ * run sql of `builder`
+ * like: conn.GetRowsBy(ctx, builder.Select().From("user").Where(builder.Eq("id", 1)))
+ */
+
+//execute sql of builder
+//return lastInsertId, effectRows, error
+func (f *Connect) ExecuteBy(ctx context.Context, b builder.IBuilder) (int64, int64, error) {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return 0, 0, err
+	}
+	return f.ExecuteContext(ctx, query, args...)
+}
+
+//get rows of builder
+func (f *Connect) GetRowsBy(ctx context.Context, b builder.IBuilder) ([]map[string]interface{}, error) {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetRowsContext(ctx, query, args...)
+}
+
+//select rows of builder into dest
+func (f *Connect) SelectBy(ctx context.Context, dest interface{}, b builder.IBuilder) error {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return err
+	}
+	return f.Select(ctx, dest, query, args...)
+}
+
+//get one row of builder into dest
+func (f *Connect) GetBy(ctx context.Context, dest interface{}, b builder.IBuilder) error {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return err
+	}
+	return f.Get(ctx, dest, query, args...)
+}
@@ -6,5 +6,26 @@ type Config struct {
 	User 		string 	`yaml:"user" json:"user"`
 	Password 	string 	`yaml:"password" json:"password"`
 	DBName 		string	`yaml:"dbName" json:"dbName"`
-	PoolSize 	int    	`yaml:"poolSize" json:"poolSize"`
-}
\ No newline at end of file
+	PoolSize 	int    	`yaml:"poolSize" json:"poolSize"` //deprecated, used as `MaxIdleConns` if not set
+	MaxOpenConns 	int 	`yaml:"maxOpenConns" json:"maxOpenConns"` //0 means unlimited
+	MaxIdleConns 	int 	`yaml:"maxIdleConns" json:"maxIdleConns"`
+	ConnMaxLifetime int 	`yaml:"connMaxLifetime" json:"connMaxLifetime"` //xxx seconds
+	ConnMaxIdleTime int 	`yaml:"connMaxIdleTime" json:"connMaxIdleTime"` //xxx seconds
+	Charset 	string 	`yaml:"charset" json:"charset"` //like `utf8mb4`
+	Collation 	string 	`yaml:"collation" json:"collation"`
+	ParseTime 	bool 	`yaml:"parseTime" json:"parseTime"` //scan DATETIME to time.Time
+	Loc 		string 	`yaml:"loc" json:"loc"` //like `Local`, `UTC`, `Asia/Shanghai`
+	TLS 		string 	`yaml:"tls" json:"tls"` //true, false, skip-verify, preferred or registered name
+	ConnectTimeout 	int 	`yaml:"connectTimeout" json:"connectTimeout"` //xxx milliseconds
+	ReadTimeout 	int 	`yaml:"readTimeout" json:"readTimeout"` //xxx milliseconds
+	WriteTimeout 	int 	`yaml:"writeTimeout" json:"writeTimeout"` //xxx milliseconds
+	AllowAllFiles 	bool 	`yaml:"allowAllFiles" json:"allowAllFiles"` //for `LOAD DATA LOCAL INFILE`
+	Params 		map[string]string `yaml:"params" json:"params"` //other dsn params
+	BreakerThreshold int 	`yaml:"breakerThreshold" json:"breakerThreshold"` //continuous conn errors to open breaker, 0 means disabled
+	BreakerCooldown int 	`yaml:"breakerCooldown" json:"breakerCooldown"` //xxx seconds
+	StmtCacheSize 	int 	`yaml:"stmtCacheSize" json:"stmtCacheSize"` //prepared statement cache size, 0 means disabled
+	QueryTimeout 	int 	`yaml:"queryTimeout" json:"queryTimeout"` //xxx milliseconds, 0 means no timeout
+	SlowThreshold 	int 	`yaml:"slowThreshold" json:"slowThreshold"` //xxx milliseconds, 0 means no slow query log
+	Replicas 	[]*Config `yaml:"replicas" json:"replicas"` //read replicas, empty fields inherit from primary
+	MaxReplicaLag 	int 	`yaml:"maxReplicaLag" json:"maxReplicaLag"` //xxx seconds, 0 means no lag check
+}
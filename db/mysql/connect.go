@@ -5,87 +5,120 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/andyzhou/tinycells/logger"
 	"github.com/andyzhou/tinycells/util"
-	_ "github.com/go-sql-driver/mysql"
 	"log"
 	"sync"
 	"time"
 )
 
+/*
+ * mysql connect
+ * - single `sql.DB` pool tuned by config
+ * - pool checker ping server and track health
+ * - circuit breaker fail fast when server down
+ * - optional prepared statement cache
+ */
+
 //face info
 type Connect struct {
 	dbConf *Config
-	poolMap map[int]*sql.DB
-	poolSize int
-	address string
+	db *sql.DB
+	healthy bool
+	breaker *breaker
+	stmtCache *stmtCache
 	checkChan chan struct{}
 	closeChan chan struct{}
+	logger *logger.Logger
+	loc *time.Location //location of time value, same as driver
+	maxPacket int64 //cached server `max_allowed_packet`, atomic
+	replicas []*replica
+	origin *Connect //origin connect of primary view
+	forcePrimary bool
 	util.Util
 	sync.RWMutex
 }
 
 //construct
+//replicas in `conf.Replicas` used for read
 func NewConnect(conf *Config) *Connect {
-	this := &Connect{
-		dbConf: conf,
-		poolMap: map[int]*sql.DB{},
-		checkChan: make(chan struct{}, 1),
-		closeChan: make(chan struct{}, 1),
+	this := newConnect(conf)
+	this.initReplicas()
+	go this.poolChecker()
+	return this
+}
+
+//construct with opened db, like from custom connector
+//db will be closed on `Quit`, replicas of conf are ignored
+func NewConnectWithDB(db *sql.DB, confs ...*Config) *Connect {
+	conf := &Config{}
+	if confs != nil && len(confs) > 0 && confs[0] != nil {
+		conf = confs[0]
 	}
-	this.interInit()
+	this := newConnectWithDB(db, conf)
 	go this.poolChecker()
 	return this
 }
 
 //quit
 func (f *Connect) Quit() {
-	f.closeChan <- struct{}{}
+	f.getOrigin().closeChan <- struct{}{}
 }
 
-//get db instance
+//set logger for slow query log, shared with primary view
+func (f *Connect) SetLogger(logger *logger.Logger) {
+	origin := f.getOrigin()
+	origin.Lock()
+	defer origin.Unlock()
+	origin.logger = logger
+}
+
+//get db instance of primary
 func (f *Connect) GetDB() *sql.DB {
-	return f.getRandomDB()
+	return f.getOrigin().db
 }
 
-//transaction
+//transaction, run single statement
+//retry on deadlock as `WithTx` default option, max `TxMaxRetry` times
+//see `WithTx` for multi statements
 func (f *Connect) Transaction(query string, args ...interface{}) (int64, int64, error) {
-	//get random db
-	db := f.getRandomDB()
-	if db == nil {
-		return 0, 0, errors.New("can't get db instance")
-	}
-	//begin transaction
-	tx, err := db.Begin()
-	if err != nil {
-		return 0, 0, err
-	}
-	//execute
-	result, err := tx.ExecContext(context.Background(), query, args...)
-	if err != nil {
-		return 0, 0, err
-	}
-	//commit
-	err = tx.Commit()
+	var (
+		lastInsertId, effectRows int64
+	)
+	err := f.WithTx(context.Background(), func(tx Tx) error {
+		var err error
+		lastInsertId, effectRows, err = tx.Execute(query, args...)
+		return err
+	})
 	if err != nil {
-		//rollback
-		tx.Rollback()
 		return 0, 0, err
 	}
-	lastInsertId, _ := result.LastInsertId()
-	effectRows, _ := result.RowsAffected()
 	return lastInsertId, effectRows, nil
 }
 
 //execute sql
 //return lastInsertId, effectRows, error
 func (f *Connect) Execute(query string, args ...interface{}) (int64, int64, error) {
-	//get random db
-	db := f.getRandomDB()
-	if db == nil {
-		return 0, 0, errors.New("can't get db instance")
+	return f.ExecuteContext(context.Background(), query, args...)
+}
+
+//execute sql with context
+//for gin request, pass `c.Request.Context()` so query canceled with request
+//return lastInsertId, effectRows, error
+func (f *Connect) ExecuteContext(ctx context.Context, query string, args ...interface{}) (int64, int64, error) {
+	//get primary db
+	db, err := f.getDB()
+	if err != nil {
+		return 0, 0, err
 	}
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
+
 	//exec sql
-	result, err := db.Exec(query, args...)
+	beginTime := time.Now()
+	result, err := f.exec(ctx, db, query, args...)
+	f.logSlow(beginTime, query, args...)
+	f.markResult(err)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -96,9 +129,14 @@ func (f *Connect) Execute(query string, args ...interface{}) (int64, int64, erro
 
 //get one row record
 func (f *Connect) GetRow(query string, args ...interface{}) (map[string]interface{}, error) {
+	return f.GetRowContext(context.Background(), query, args...)
+}
+
+//get one row record with context
+func (f *Connect) GetRowContext(ctx context.Context, query string, args ...interface{}) (map[string]interface{}, error) {
 	recordMap := make(map[string]interface{})
 	queryNew := fmt.Sprintf("%s LIMIT 1", query)
-	records, err := f.GetRows(queryNew, args...)
+	records, err := f.GetRowsContext(ctx, queryNew, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -115,74 +153,121 @@ func (f *Connect) GetRow(query string, args ...interface{}) (map[string]interfac
 
 //get batch row records
 func (f *Connect) GetRows(query string, args ...interface{}) ([]map[string]interface{}, error) {
-	//get random db
-	db := f.getRandomDB()
-	if db == nil {
-		return nil, errors.New("can't get db instance")
-	}
-	//format result
-	records := make([]map[string]interface{}, 0)
-	rows, err := db.Query(query, args...)
+	return f.GetRowsContext(context.Background(), query, args...)
+}
+
+//get batch row records with context
+func (f *Connect) GetRowsContext(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	//get read db
+	conn := f.getReadConn()
+	db, err := conn.getDB()
 	if err != nil {
 		return nil, err
 	}
-	//init map for return
-	columns, _ := rows.Columns()
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
 
-	scanArgs := make([]interface{}, len(columns))
-	values := make([]interface{}, len(columns))
-	for i := range values {
-		scanArgs[i] = &values[i]
-	}
-
-	//begin get record and copy into slice for return
-	k := 0
-	for rows.Next() {
-		//storage row data into record map
-		err = rows.Scan(scanArgs...)
-		record := make(map[string]interface{})
-		for i, col := range values {
-			if col != nil {
-				record[columns[i]] = col
-			}
-		}
-		//append record into slice list
-		records = append(records, record)
-		k++
+	//query and scan records
+	beginTime := time.Now()
+	defer f.logSlow(beginTime, query, args...)
+	var records []map[string]interface{}
+	err = conn.query(ctx, db, func(rows *sql.Rows) error {
+		records, err = scanRowsToMap(rows)
+		return err
+	}, query, args...)
+	conn.markResult(err)
+	if err != nil {
+		return nil, err
 	}
 	return records, nil
 }
 
 //ping server
 func (f *Connect) Ping() error {
-	//get random db
-	db := f.getRandomDB()
+	return f.PingContext(context.Background())
+}
+
+//ping server with context
+func (f *Connect) PingContext(ctx context.Context) error {
+	db := f.GetDB()
 	if db == nil {
 		return errors.New("can't get db instance")
 	}
-	return db.Ping()
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
+	err := db.PingContext(ctx)
+	f.markResult(err)
+	return err
 }
 
 ////////////////
 //private func
 ////////////////
 
-//get rand db from pool
-func (f *Connect) getRandomDB() *sql.DB {
-	if f.poolSize <= 0 {
-		return nil
+//set default query timeout if ctx has no deadline
+func (f *Connect) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	randIdx := f.GetRandomVal(f.poolSize)
-	f.Lock()
-	defer f.Unlock()
-	v, ok := f.poolMap[randIdx]
-	if ok && v != nil {
-		return v
+	if f.dbConf.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(f.dbConf.QueryTimeout) * time.Millisecond)
+}
+
+//log slow query which cost over threshold
+//only args count logged, values may contain sensitive data
+func (f *Connect) logSlow(beginTime time.Time, query string, args ...interface{}) {
+	if f.dbConf.SlowThreshold <= 0 {
+		return
+	}
+	cost := time.Since(beginTime)
+	if cost < time.Duration(f.dbConf.SlowThreshold) * time.Millisecond {
+		return
+	}
+	origin := f.getOrigin()
+	origin.RLock()
+	slowLogger := origin.logger
+	origin.RUnlock()
+	if slowLogger != nil {
+		slowLogger.SS().Warnw("mysql slow query",
+			"db", f.dbConf.DBName,
+			"cost", cost.String(),
+			"sql", query,
+			"args", len(args),
+		)
+		return
 	}
-	return nil
+	log.Printf("mysql slow query, db:%v, cost:%v, sql:%v, args:%v\n",
+		f.dbConf.DBName, cost, query, len(args))
+}
+
+//get primary db, fail fast if circuit breaker open
+func (f *Connect) getDB() (*sql.DB, error) {
+	origin := f.getOrigin()
+	if origin.db == nil {
+		return nil, errors.New("can't get db instance")
+	}
+	if !origin.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+	return origin.db, nil
+}
+
+//get location of time value
+func (f *Connect) getLoc() *time.Location {
+	return f.getOrigin().loc
+}
+
+//mark query result for circuit breaker
+func (f *Connect) markResult(err error) {
+	f.getOrigin().breaker.done(err)
 }
 
-//pool init and check process
+//pool check process
 func (f *Connect) poolChecker() {
 	defer func() {
 		if err := recover(); err != nil {
@@ -200,8 +285,9 @@ func (f *Connect) poolChecker() {
 		select {
 		case <- f.checkChan:
 			{
-				//connect check
-				f.checkOrConnect()
+				//health check
+				f.checkHealth()
+				f.checkReplicas()
 				//next ticker
 				time.Sleep(time.Second * ConnCheckRate)
 				f.checkChan <- struct{}{}
@@ -212,103 +298,90 @@ func (f *Connect) poolChecker() {
 	}
 }
 
-//check or connect server
-func (f *Connect) checkOrConnect() {
-	var (
-		err error
-	)
-	if f.poolMap == nil {
-		return
+//ping server and track health
+//connections are re-established by `sql.DB` itself
+func (f *Connect) checkHealth() bool {
+	if f.db == nil {
+		return false
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second * ConnPingTimeout)
+	defer cancel()
+	err := f.db.PingContext(ctx)
+	f.breaker.done(err)
+	healthy := err == nil
+
 	f.Lock()
-	defer f.Unlock()
-	for k, v := range f.poolMap {
-		if v == nil {
-			//try connect
-			newConn, _ := f.connectServer()
-			if newConn != nil {
-				f.poolMap[k] = newConn
-			}
-			continue
-		}
-		err = v.Ping()
-		if err == nil {
-			continue
-		}
-		//ping failed, try connect
-		v.Close()
-		newConn, _ := f.connectServer()
-		if newConn != nil {
-			f.poolMap[k] = newConn
-		}
+	recovered := healthy && !f.healthy
+	f.healthy = healthy
+	f.Unlock()
+	if err != nil {
+		log.Printf("mysql.Connect:checkHealth failed, addr:%v, err:%v\n", f.getHostAddress(), err)
 	}
+	if recovered && f.stmtCache != nil {
+		//statements prepared before server restart are invalid
+		f.stmtCache.clear()
+	}
+	return healthy
 }
 
 //release pool
 func (f *Connect) releasePool() {
-	if f.poolMap == nil {
-		return
+	if f.stmtCache != nil {
+		f.stmtCache.clear()
 	}
-	f.Lock()
-	defer f.Unlock()
-	for _, v := range f.poolMap {
-		if v != nil {
-			v.Close()
-		}
+	if f.db != nil {
+		f.db.Close()
+	}
+	for _, v := range f.replicas {
+		v.conn.releasePool()
 	}
-	f.poolMap = map[int]*sql.DB{}
 }
 
-//fill pool map
-func (f *Connect) fillPoolMap() {
-	for i := 0; i < f.dbConf.PoolSize; i++ {
-		db, err := f.connectServer()
-		if err != nil || db == nil {
-			continue
-		}
-		f.poolMap[i] = db
-	}
-	f.poolSize = len(f.poolMap)
+//get host address
+func (f *Connect) getHostAddress() string {
+	return fmt.Sprintf("%s:%d", f.dbConf.Host, f.dbConf.Port)
 }
 
-//try connect
-func (f *Connect) connectServer() (*sql.DB, error) {
-	//check
-	if f.dbConf == nil {
-		return nil, errors.New("db conf is nil")
-	}
-	//init db driver
-	db, err := sql.Open("mysql", f.address)
-	if err != nil {
-		return nil, err
-	}
-	//try ping db server
-	err = db.Ping()
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
+//create connect without pool checker
+func newConnect(conf *Config) *Connect {
+	this := newConnectWithDB(nil, conf)
+	this.interInit()
+	return this
 }
 
-//format db address
-func (f *Connect) getDBAddress() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
-		f.dbConf.User, f.dbConf.Password,
-		f.dbConf.Host, f.dbConf.Port,
-		f.dbConf.DBName,
-	)
+//create connect of opened db without pool checker
+func newConnectWithDB(db *sql.DB, conf *Config) *Connect {
+	this := &Connect{
+		dbConf: conf,
+		db: db,
+		healthy: true,
+		breaker: newBreaker(conf.BreakerThreshold, time.Duration(conf.BreakerCooldown) * time.Second),
+		stmtCache: newStmtCache(conf.StmtCacheSize),
+		checkChan: make(chan struct{}, 1),
+		closeChan: make(chan struct{}, 1),
+	}
+	this.loc = time.UTC
+	if conf.Loc != "" {
+		if loc, err := time.LoadLocation(conf.Loc); err == nil {
+			this.loc = loc
+		}
+	}
+	return this
 }
 
 //inter init
 func (f *Connect) interInit() {
-	//check
-	if f.dbConf.PoolSize <= 0 {
-		f.dbConf.PoolSize = DBPoolMin
+	//open db, real connect is lazy
+	dsn, err := f.formatDSN()
+	if err != nil {
+		log.Printf("mysql.Connect:interInit failed, err:%v\n", err)
+		return
 	}
-
-	//format address
-	f.address = f.getDBAddress()
-
-	//fill pool map
-	f.fillPoolMap()
-}
\ No newline at end of file
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		log.Printf("mysql.Connect:interInit failed, err:%v\n", err)
+		return
+	}
+	f.setupPool(db)
+	f.db = db
+}
@@ -2,6 +2,8 @@ package mysql
 
 const (
 	ConnCheckRate = 20 //xxx seconds
+	ConnPingTimeout = 5 //xxx seconds
+	BreakerCooldown = 10 //xxx seconds
 	DBPoolMin = 1
 )
 
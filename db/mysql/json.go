@@ -7,6 +7,7 @@ import (
 	"log"
 	"reflect"
 	"runtime/debug"
+	"sort"
 	"strconv"
 )
 
@@ -1096,10 +1097,18 @@ func (f *JsonData) formatWhereSql(
 		return whereBuffer, nil
 	}
 
+	//sort fields for deterministic sql
+	fields := make([]string, 0, len(whereMap))
+	for field := range whereMap {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
 	//format where sql
 	i := 0
 	whereBuffer.WriteString(" WHERE ")
-	for field, wherePara := range whereMap {
+	for _, field := range fields {
+		wherePara := whereMap[field]
 		if i > 0 {
 			whereBuffer.WriteString(" AND ")
 		}
@@ -8,6 +8,7 @@ import (
 //face info
 type Mysql struct {
 	connectMap map[string]*Connect //dbTag -> *Connect
+	shardMap map[string]*ShardTable //table -> *ShardTable
 	JsonData
 	sync.RWMutex
 }
@@ -16,6 +17,7 @@ type Mysql struct {
 func NewMysql() *Mysql {
 	this := &Mysql{
 		connectMap: map[string]*Connect{},
+		shardMap: map[string]*ShardTable{},
 	}
 	return this
 }
@@ -41,6 +43,7 @@ func (f *Mysql) CreateConnect(tag string, conf *Config) (*Connect, error) {
 	conn := NewConnect(conf)
 	err := conn.Ping()
 	if err != nil {
+		conn.Quit()
 		return nil, err
 	}
 
@@ -50,6 +53,29 @@ func (f *Mysql) CreateConnect(tag string, conf *Config) (*Connect, error) {
 	return conn, nil
 }
 
+//add opened connect, like from `NewConnectWithDB`
+func (f *Mysql) AddConnect(tag string, conn *Connect) error {
+	//check
+	if tag == "" || conn == nil {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.connectMap[tag] = conn
+	return nil
+}
+
+//remove and quit connect
+func (f *Mysql) RemoveConnect(tag string) {
+	f.Lock()
+	conn, ok := f.connectMap[tag]
+	delete(f.connectMap, tag)
+	f.Unlock()
+	if ok && conn != nil {
+		conn.Quit()
+	}
+}
+
 //gen new config
 func (f *Mysql)  GenNewConfig() *Config {
 	return &Config{}
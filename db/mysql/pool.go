@@ -0,0 +1,212 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+ * pool setup, dsn format, statistics and circuit breaker
+ */
+
+//circuit breaker state
+const (
+	BreakerStateClosed = iota
+	BreakerStateOpen
+	BreakerStateHalfOpen
+)
+
+//circuit breaker error
+var (
+	ErrCircuitOpen = errors.New("mysql circuit breaker open")
+)
+
+//pool statistics
+type PoolStats struct {
+	sql.DBStats
+	Address string
+	Healthy bool
+	BreakerState int
+	StmtCache *StmtCacheStats //nil if cache disabled
+	Replicas []*ReplicaStatus
+}
+
+//get pool statistics
+func (f *Connect) GetStats() *PoolStats {
+	origin := f.getOrigin()
+	stats := &PoolStats{
+		Address: origin.getHostAddress(),
+		BreakerState: origin.breaker.getState(),
+		StmtCache: origin.GetStmtCacheStats(),
+		Replicas: origin.GetReplicaStatus(),
+	}
+	if origin.db != nil {
+		stats.DBStats = origin.db.Stats()
+	}
+	origin.RLock()
+	stats.Healthy = origin.healthy
+	origin.RUnlock()
+	return stats
+}
+
+//check error is connection broken
+//context canceled or deadline exceeded is not, server may be fine
+func IsConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysqlDriver.ErrInvalidConn) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+////////////////
+//private func
+////////////////
+
+//format dsn from config
+func (f *Connect) formatDSN() (string, error) {
+	conf := mysqlDriver.NewConfig()
+	conf.User = f.dbConf.User
+	conf.Passwd = f.dbConf.Password
+	conf.Net = "tcp"
+	conf.Addr = f.getHostAddress()
+	conf.DBName = f.dbConf.DBName
+	conf.ParseTime = f.dbConf.ParseTime
+	conf.Collation = f.dbConf.Collation
+	conf.TLSConfig = f.dbConf.TLS
+	conf.AllowAllFiles = f.dbConf.AllowAllFiles
+	if f.dbConf.Loc != "" {
+		loc, err := time.LoadLocation(f.dbConf.Loc)
+		if err != nil {
+			return "", fmt.Errorf("invalid loc %v, err:%v", f.dbConf.Loc, err)
+		}
+		conf.Loc = loc
+	}
+	conf.Timeout = time.Duration(f.dbConf.ConnectTimeout) * time.Millisecond
+	conf.ReadTimeout = time.Duration(f.dbConf.ReadTimeout) * time.Millisecond
+	conf.WriteTimeout = time.Duration(f.dbConf.WriteTimeout) * time.Millisecond
+	conf.Params = map[string]string{}
+	if f.dbConf.Charset != "" {
+		conf.Params["charset"] = f.dbConf.Charset
+	}
+	for k, v := range f.dbConf.Params {
+		conf.Params[k] = v
+	}
+	return conf.FormatDSN(), nil
+}
+
+//setup pool by config
+func (f *Connect) setupPool(db *sql.DB) {
+	maxIdle := f.dbConf.MaxIdleConns
+	if maxIdle <= 0 {
+		//compatible with old pool size
+		maxIdle = f.dbConf.PoolSize
+	}
+	if maxIdle > 0 {
+		db.SetMaxIdleConns(maxIdle)
+	}
+	if f.dbConf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(f.dbConf.MaxOpenConns)
+	}
+	if f.dbConf.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(f.dbConf.ConnMaxLifetime) * time.Second)
+	}
+	if f.dbConf.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(time.Duration(f.dbConf.ConnMaxIdleTime) * time.Second)
+	}
+}
+
+/////////////////
+//circuit breaker
+/////////////////
+
+//breaker info
+//open after `threshold` continuous connection errors,
+//allow one probe after cooldown, close on probe success
+type breaker struct {
+	threshold int //0 means disabled
+	cooldown time.Duration
+	failures int
+	state int
+	openAt time.Time
+	sync.Mutex
+}
+
+//create breaker
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if cooldown <= 0 {
+		cooldown = BreakerCooldown * time.Second
+	}
+	return &breaker{
+		threshold: threshold,
+		cooldown: cooldown,
+	}
+}
+
+//check request allowed
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.state == BreakerStateClosed {
+		return true
+	}
+	if time.Since(b.openAt) < b.cooldown {
+		return false
+	}
+	//let one probe go, next probe after another cooldown
+	b.state = BreakerStateHalfOpen
+	b.openAt = time.Now()
+	return true
+}
+
+//record request result
+func (b *breaker) done(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		//server state unknown, failures kept
+		//probe not finished, wait another cooldown
+		if b.state == BreakerStateHalfOpen {
+			b.state = BreakerStateOpen
+			b.openAt = time.Now()
+		}
+		return
+	}
+	if !IsConnError(err) {
+		//server reachable
+		b.failures = 0
+		b.state = BreakerStateClosed
+		return
+	}
+	b.failures++
+	if b.state == BreakerStateHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerStateOpen
+		b.openAt = time.Now()
+	}
+}
+
+//get state
+func (b *breaker) getState() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.state
+}
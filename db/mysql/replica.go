@@ -0,0 +1,253 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+/*
+ * read/write splitting
+ * - writes and transactions always on primary
+ * - reads routed to random healthy replica, lag over `MaxReplicaLag` skipped
+ * - fallback to primary if no replica available
+ * - replica health and lag checked by primary `poolChecker`
+ */
+
+//replica status
+type ReplicaStatus struct {
+	Address string
+	Healthy bool
+	Lag int64 //seconds behind primary, -1 means unknown
+}
+
+//inter replica info
+type replica struct {
+	conn *Connect
+	healthy bool
+	lag int64
+}
+
+//return connect which routes all reads to primary
+//pools are shared with origin connect, like: conn.UsePrimary().GetRow(...)
+func (f *Connect) UsePrimary() *Connect {
+	if f.forcePrimary {
+		return f
+	}
+	origin := f.getOrigin()
+	this := &Connect{
+		dbConf: origin.dbConf,
+		origin: origin,
+		forcePrimary: true,
+	}
+	return this
+}
+
+//add replica of opened db, like from custom connector
+//config inherit from primary, db will be closed on `Quit`
+func (f *Connect) AddReplicaDB(db *sql.DB) error {
+	if db == nil {
+		return errors.New("invalid parameter")
+	}
+	origin := f.getOrigin()
+	v := &replica{
+		conn: newConnectWithDB(db, origin.genReplicaConf(&Config{})),
+		lag: -1,
+	}
+	origin.checkReplica(v)
+	origin.Lock()
+	defer origin.Unlock()
+	origin.replicas = append(origin.replicas, v)
+	return nil
+}
+
+//get db instance for read
+func (f *Connect) GetReadDB() *sql.DB {
+	db, _ := f.getReadConn().getDB()
+	return db
+}
+
+//get replica status
+func (f *Connect) GetReplicaStatus() []*ReplicaStatus {
+	origin := f.getOrigin()
+	origin.RLock()
+	defer origin.RUnlock()
+	result := make([]*ReplicaStatus, 0, len(origin.replicas))
+	for _, v := range origin.replicas {
+		result = append(result, &ReplicaStatus{
+			Address: v.conn.getHostAddress(),
+			Healthy: v.healthy,
+			Lag: v.lag,
+		})
+	}
+	return result
+}
+
+////////////////
+//private func
+////////////////
+
+//get origin connect of primary view
+func (f *Connect) getOrigin() *Connect {
+	if f.origin != nil {
+		return f.origin
+	}
+	return f
+}
+
+//get connect for read, pick healthy replica first
+func (f *Connect) getReadConn() *Connect {
+	if f.forcePrimary {
+		return f
+	}
+	f.RLock()
+	candidates := make([]*Connect, 0, len(f.replicas))
+	for _, v := range f.replicas {
+		if !v.healthy || v.conn.breaker.getState() == BreakerStateOpen {
+			continue
+		}
+		if f.dbConf.MaxReplicaLag > 0 && (v.lag < 0 || v.lag > int64(f.dbConf.MaxReplicaLag)) {
+			continue
+		}
+		candidates = append(candidates, v.conn)
+	}
+	f.RUnlock()
+	if len(candidates) <= 0 {
+		return f
+	}
+	return candidates[f.GetRandomVal(len(candidates))]
+}
+
+//init replica connects
+func (f *Connect) initReplicas() {
+	for _, conf := range f.dbConf.Replicas {
+		if conf == nil {
+			continue
+		}
+		f.replicas = append(f.replicas, &replica{
+			conn: newConnect(f.genReplicaConf(conf)),
+			lag: -1,
+		})
+	}
+}
+
+//check replicas health and lag
+func (f *Connect) checkReplicas() {
+	f.RLock()
+	replicas := f.replicas
+	f.RUnlock()
+	for _, v := range replicas {
+		f.checkReplica(v)
+	}
+}
+
+//check single replica health and lag
+func (f *Connect) checkReplica(v *replica) {
+	healthy, lag := v.conn.checkHealth(), int64(0)
+	if healthy && f.dbConf.MaxReplicaLag > 0 {
+		lag = v.conn.getReplicaLag()
+	}
+	f.Lock()
+	v.healthy = healthy
+	v.lag = lag
+	f.Unlock()
+}
+
+//get seconds behind primary, -1 means replication stopped or unknown
+func (f *Connect) getReplicaLag() int64 {
+	records, err := f.GetRows("SHOW SLAVE STATUS")
+	if err != nil {
+		return -1
+	}
+	if len(records) <= 0 {
+		//not a replica, no lag
+		return 0
+	}
+	record := records[0]
+	for _, field := range []string{"Seconds_Behind_Master", "Seconds_Behind_Source"} {
+		v, ok := record[field]
+		if !ok {
+			continue
+		}
+		lag, err := strconv.ParseInt(toString(v), 10, 64)
+		if v == nil || err != nil {
+			return -1
+		}
+		return lag
+	}
+	return -1
+}
+
+//gen replica config from primary, origin config kept
+func (f *Connect) genReplicaConf(conf *Config) *Config {
+	replicaConf := *conf
+	replicaConf.Replicas = nil
+	if conf.Params != nil {
+		replicaConf.Params = make(map[string]string, len(conf.Params))
+		for k, v := range conf.Params {
+			replicaConf.Params[k] = v
+		}
+	}
+	if replicaConf.Port <= 0 {
+		replicaConf.Port = f.dbConf.Port
+	}
+	if replicaConf.User == "" {
+		replicaConf.User = f.dbConf.User
+		replicaConf.Password = f.dbConf.Password
+	}
+	if replicaConf.DBName == "" {
+		replicaConf.DBName = f.dbConf.DBName
+	}
+	if replicaConf.PoolSize <= 0 {
+		replicaConf.PoolSize = f.dbConf.PoolSize
+	}
+	if replicaConf.QueryTimeout <= 0 {
+		replicaConf.QueryTimeout = f.dbConf.QueryTimeout
+	}
+	if replicaConf.SlowThreshold <= 0 {
+		replicaConf.SlowThreshold = f.dbConf.SlowThreshold
+	}
+
+	//pool and dsn options
+	if replicaConf.MaxOpenConns <= 0 {
+		replicaConf.MaxOpenConns = f.dbConf.MaxOpenConns
+	}
+	if replicaConf.MaxIdleConns <= 0 {
+		replicaConf.MaxIdleConns = f.dbConf.MaxIdleConns
+	}
+	if replicaConf.ConnMaxLifetime <= 0 {
+		replicaConf.ConnMaxLifetime = f.dbConf.ConnMaxLifetime
+	}
+	if replicaConf.ConnMaxIdleTime <= 0 {
+		replicaConf.ConnMaxIdleTime = f.dbConf.ConnMaxIdleTime
+	}
+	if replicaConf.Charset == "" {
+		replicaConf.Charset = f.dbConf.Charset
+	}
+	if replicaConf.Loc == "" {
+		replicaConf.Loc = f.dbConf.Loc
+	}
+	if replicaConf.TLS == "" {
+		replicaConf.TLS = f.dbConf.TLS
+	}
+	if f.dbConf.ParseTime {
+		replicaConf.ParseTime = true
+	}
+	if replicaConf.ConnectTimeout <= 0 {
+		replicaConf.ConnectTimeout = f.dbConf.ConnectTimeout
+	}
+	if replicaConf.ReadTimeout <= 0 {
+		replicaConf.ReadTimeout = f.dbConf.ReadTimeout
+	}
+	if replicaConf.WriteTimeout <= 0 {
+		replicaConf.WriteTimeout = f.dbConf.WriteTimeout
+	}
+	if replicaConf.StmtCacheSize <= 0 {
+		replicaConf.StmtCacheSize = f.dbConf.StmtCacheSize
+	}
+	if replicaConf.BreakerThreshold <= 0 {
+		replicaConf.BreakerThreshold = f.dbConf.BreakerThreshold
+		replicaConf.BreakerCooldown = f.dbConf.BreakerCooldown
+	}
+	return &replicaConf
+}
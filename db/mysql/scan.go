@@ -0,0 +1,475 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+/*
+ * struct scanning
+ * - column mapped to field by `db` tag, or snake case of field name
+ * - `db:"-"` to skip field, embedded struct fields are flattened,
+ *   except un-exported embedded struct pointer
+ * - convert DATETIME to time.Time, JSON to struct/map/slice,
+ *   DECIMAL to string or float, etc.
+ * - DATETIME text parsed in `Config.Loc` of connect, default UTC as driver
+ * - column without matched field is ignored
+ */
+
+//tag name
+const (
+	ScanTagName = "db"
+)
+
+//time layouts of mysql text value
+var scanTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02",
+}
+
+//field index cache, reflect.Type -> map[column][]int
+var scanFieldCache sync.Map
+
+//select rows into dest, dest should be pointer of struct slice
+//like: Select(ctx, &users, "SELECT * FROM user WHERE age > ?", 18)
+func (f *Connect) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	conn := f.getReadConn()
+	db, err := conn.getDB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
+	beginTime := time.Now()
+	defer f.logSlow(beginTime, query, args...)
+	err = conn.query(ctx, db, func(rows *sql.Rows) error {
+		return ScanRows(rows, dest, conn.getLoc())
+	}, query, args...)
+	conn.markResult(err)
+	return err
+}
+
+//get one row into dest, dest should be pointer of struct
+//return sql.ErrNoRows if not found
+func (f *Connect) Get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	conn := f.getReadConn()
+	db, err := conn.getDB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := f.withTimeout(ctx)
+	defer cancel()
+	beginTime := time.Now()
+	defer f.logSlow(beginTime, query, args...)
+	err = conn.query(ctx, db, func(rows *sql.Rows) error {
+		return ScanRow(rows, dest, conn.getLoc())
+	}, query, args...)
+	conn.markResult(err)
+	return err
+}
+
+//select rows into dest in tx
+func (f *connectTx) Select(dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := f.conn.withTimeout(f.ctx)
+	defer cancel()
+	rows, err := f.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return ScanRows(rows, dest, f.conn.getLoc())
+}
+
+//get one row into dest in tx
+func (f *connectTx) Get(dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := f.conn.withTimeout(f.ctx)
+	defer cancel()
+	rows, err := f.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return ScanRow(rows, dest, f.conn.getLoc())
+}
+
+//scan all rows into dest, rows will be closed
+//dest should be pointer of struct slice or struct pointer slice
+//loc is optional location of time text value, default UTC as driver
+func ScanRows(rows *sql.Rows, dest interface{}, locs ...*time.Location) error {
+	defer rows.Close()
+	loc := getScanLoc(locs...)
+
+	//check dest
+	destVal := reflect.ValueOf(dest)
+	if destVal.Kind() != reflect.Ptr || destVal.IsNil() || destVal.Elem().Kind() != reflect.Slice {
+		return errors.New("dest should be pointer of slice")
+	}
+	sliceVal := destVal.Elem()
+	elemType := sliceVal.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return errors.New("dest should be slice of struct")
+	}
+
+	//scan rows
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	sliceVal.Set(sliceVal.Slice(0, 0))
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		if err = scanStruct(rows, columns, elem.Elem(), loc); err != nil {
+			return err
+		}
+		if isPtr {
+			sliceVal.Set(reflect.Append(sliceVal, elem))
+		}else{
+			sliceVal.Set(reflect.Append(sliceVal, elem.Elem()))
+		}
+	}
+	return rows.Err()
+}
+
+//scan first row into dest, rows will be closed
+//dest should be pointer of struct, return sql.ErrNoRows if no rows
+func ScanRow(rows *sql.Rows, dest interface{}, locs ...*time.Location) error {
+	defer rows.Close()
+
+	//check dest
+	destVal := reflect.ValueOf(dest)
+	if destVal.Kind() != reflect.Ptr || destVal.IsNil() || destVal.Elem().Kind() != reflect.Struct {
+		return errors.New("dest should be pointer of struct")
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return scanStruct(rows, columns, destVal.Elem(), getScanLoc(locs...))
+}
+
+////////////////
+//private func
+////////////////
+
+//get location of scan, default UTC
+func getScanLoc(locs ...*time.Location) *time.Location {
+	if locs != nil && len(locs) > 0 && locs[0] != nil {
+		return locs[0]
+	}
+	return time.UTC
+}
+
+//scan rows into map slice, rows will be closed
+func scanRowsToMap(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	scanArgs := make([]interface{}, len(columns))
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	records := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		if err = rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+		record := make(map[string]interface{})
+		for i, col := range values {
+			if col != nil {
+				record[columns[i]] = col
+			}
+		}
+		records = append(records, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+//scan current row into struct value
+func scanStruct(rows *sql.Rows, columns []string, structVal reflect.Value, loc *time.Location) error {
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		return err
+	}
+	fieldMap := getFieldMap(structVal.Type())
+	for i, column := range columns {
+		index, ok := fieldMap[strings.ToLower(column)]
+		if !ok {
+			continue
+		}
+		field, err := fieldByIndex(structVal, index)
+		if err != nil {
+			return fmt.Errorf("scan column %v failed, err:%v", column, err)
+		}
+		if err = setFieldValue(field, values[i], loc); err != nil {
+			return fmt.Errorf("scan column %v failed, err:%v", column, err)
+		}
+	}
+	return nil
+}
+
+//get field by index, alloc embedded struct pointer if nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, fmt.Errorf("can't alloc embedded %v", v.Type())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	if !v.CanSet() {
+		return v, fmt.Errorf("field of %v can't be set", v.Type())
+	}
+	return v, nil
+}
+
+//get column -> field index map of struct type
+func getFieldMap(t reflect.Type) map[string][]int {
+	if v, ok := scanFieldCache.Load(t); ok {
+		return v.(map[string][]int)
+	}
+	fieldMap := map[string][]int{}
+	buildFieldMap(t, nil, fieldMap)
+	scanFieldCache.Store(t, fieldMap)
+	return fieldMap
+}
+
+//build field map, outer field has priority
+func buildFieldMap(t reflect.Type, parent []int, fieldMap map[string][]int) {
+	embedded := make([]reflect.StructField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get(ScanTagName)
+		if tag == "-" {
+			continue
+		}
+		index := append(append([]int{}, parent...), i)
+		field.Index = index
+
+		//embedded struct without tag, flatten later
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && tag == "" && fieldType.Kind() == reflect.Struct && fieldType != reflect.TypeOf(time.Time{}) {
+			//un-exported embedded pointer can't be allocated
+			if field.PkgPath == "" || field.Type.Kind() != reflect.Ptr {
+				embedded = append(embedded, field)
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue //un-exported
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = toSnakeCase(field.Name)
+		}
+		name = strings.ToLower(name)
+		if _, ok := fieldMap[name]; !ok {
+			fieldMap[name] = index
+		}
+	}
+	for _, field := range embedded {
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		buildFieldMap(fieldType, field.Index, fieldMap)
+	}
+}
+
+//set field value with type convert
+func setFieldValue(field reflect.Value, src interface{}, loc *time.Location) error {
+	//null value
+	if src == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	//sql.Scanner
+	if field.CanAddr() {
+		if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
+			return scanner.Scan(src)
+		}
+	}
+
+	//pointer
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setFieldValue(elem.Elem(), src, loc); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	//time
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := toTime(src, loc)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(toString(src))
+	case reflect.Bool:
+		switch v := src.(type) {
+		case bool:
+			field.SetBool(v)
+		case int64:
+			field.SetBool(v != 0)
+		default:
+			b, err := strconv.ParseBool(toString(src))
+			if err != nil {
+				return err
+			}
+			field.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := src.(type) {
+		case int64:
+			field.SetInt(v)
+		case float64:
+			field.SetInt(int64(v))
+		default:
+			i, err := strconv.ParseInt(toString(src), 10, 64)
+			if err != nil {
+				return err
+			}
+			field.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v := src.(type) {
+		case int64:
+			field.SetUint(uint64(v))
+		default:
+			i, err := strconv.ParseUint(toString(src), 10, 64)
+			if err != nil {
+				return err
+			}
+			field.SetUint(i)
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := src.(type) {
+		case float64:
+			field.SetFloat(v)
+		case float32:
+			field.SetFloat(float64(v))
+		case int64:
+			field.SetFloat(float64(v))
+		default:
+			fv, err := strconv.ParseFloat(toString(src), 64)
+			if err != nil {
+				return err
+			}
+			field.SetFloat(fv)
+		}
+	case reflect.Slice:
+		//[]byte
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			if b, ok := src.([]byte); ok {
+				field.SetBytes(append([]byte{}, b...))
+				return nil
+			}
+			field.SetBytes([]byte(toString(src)))
+			return nil
+		}
+		return json.Unmarshal([]byte(toString(src)), field.Addr().Interface())
+	case reflect.Struct, reflect.Map:
+		//json column
+		return json.Unmarshal([]byte(toString(src)), field.Addr().Interface())
+	case reflect.Interface:
+		if b, ok := src.([]byte); ok {
+			src = string(b)
+		}
+		field.Set(reflect.ValueOf(src))
+	default:
+		return fmt.Errorf("unsupported field type %v", field.Type())
+	}
+	return nil
+}
+
+//convert value to string
+func toString(src interface{}) string {
+	switch v := src.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.Format(scanTimeLayouts[1])
+	}
+	return fmt.Sprintf("%v", src)
+}
+
+//convert value to time, text value parsed in loc
+func toTime(src interface{}, loc *time.Location) (time.Time, error) {
+	if t, ok := src.(time.Time); ok {
+		return t, nil
+	}
+	str := toString(src)
+	if str == "" || strings.HasPrefix(str, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	for _, layout := range scanTimeLayouts {
+		if t, err := time.ParseInLocation(layout, str, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't parse %v as time", str)
+}
+
+//convert field name to snake case, like UserId -> user_id
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	buf := make([]rune, 0, len(runes) + 4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i + 1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				buf = append(buf, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf = append(buf, r)
+	}
+	return string(buf)
+}
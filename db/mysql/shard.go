@@ -0,0 +1,529 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+ * horizontal sharding for tables, like `user_0..user_63`
+ * - shard func map shard key to table index
+ * - table index range mapped to connect tag of `Mysql`
+ * - raw query use `{table}` as table placeholder
+ * - scatter gather run query on all shards, then merge, sort and limit
+ */
+
+//inter macro define
+const (
+	ShardFuncOfHash = "hash"
+	ShardFuncOfRange = "range"
+	ShardFuncOfLookup = "lookup"
+	ShardTablePlaceholder = "{table}"
+)
+
+//shard errors
+var (
+	ErrShardKeyInvalid = errors.New("shard key invalid")
+	ErrShardNotFound = errors.New("shard not found")
+)
+
+//shard node config, tables [From, To] on connect of tag
+//if `Conf` set and connect of tag not exists, will create it
+type ShardNode struct {
+	Tag 	string 	`yaml:"tag" json:"tag"`
+	From 	int 	`yaml:"from" json:"from"`
+	To 	int 	`yaml:"to" json:"to"`
+	Conf 	*Config `yaml:"conf" json:"conf"`
+}
+
+//shard key range of table index, key in [Min, Max)
+type ShardRange struct {
+	Min 	int64 	`yaml:"min" json:"min"`
+	Max 	int64 	`yaml:"max" json:"max"`
+	Index 	int 	`yaml:"index" json:"index"`
+}
+
+//shard table config
+type ShardConfig struct {
+	Table 	string 	`yaml:"table" json:"table"` //table prefix, like `user`
+	Shards 	int 	`yaml:"shards" json:"shards"` //table count
+	Func 	string 	`yaml:"func" json:"func"` //hash, range or lookup
+	Ranges 	[]*ShardRange `yaml:"ranges" json:"ranges"` //for range func
+	Lookup 	map[string]int `yaml:"lookup" json:"lookup"` //for lookup func, key -> table index
+	Nodes 	[]*ShardNode `yaml:"nodes" json:"nodes"`
+}
+
+//shard func
+type IShardFunc interface {
+	Shard(key interface{}) (int, error)
+}
+
+//gather option for scatter query
+type GatherOption struct {
+	OrderBy string
+	Desc bool
+	Offset int
+	Limit int //0 means no limit
+}
+
+/////////////////
+//hash mod shard
+/////////////////
+
+//face info
+type HashShard struct {
+	shards int
+}
+
+//construct
+func NewHashShard(shards int) *HashShard {
+	this := &HashShard{
+		shards: shards,
+	}
+	return this
+}
+
+//integer key and numeric string mod by abs value, others mod by crc32
+func (f *HashShard) Shard(key interface{}) (int, error) {
+	if f.shards <= 0 {
+		return 0, ErrShardNotFound
+	}
+	switch v := key.(type) {
+	case uint:
+		return int(uint64(v) % uint64(f.shards)), nil
+	case uint64:
+		return int(v % uint64(f.shards)), nil
+	case []byte:
+		key = string(v)
+	}
+	if val, err := shardKeyToInt(key); err == nil {
+		return int(absUint64(val) % uint64(f.shards)), nil
+	}
+	if v, ok := key.(string); ok {
+		//numeric string out of int64
+		if val, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return int(val % uint64(f.shards)), nil
+		}
+		return int(crc32.ChecksumIEEE([]byte(v)) % uint32(f.shards)), nil
+	}
+	return 0, ErrShardKeyInvalid
+}
+
+/////////////////
+//range shard
+/////////////////
+
+//face info
+type RangeShard struct {
+	ranges []*ShardRange
+}
+
+//construct
+func NewRangeShard(ranges ...*ShardRange) *RangeShard {
+	this := &RangeShard{
+		ranges: ranges,
+	}
+	return this
+}
+
+//find range of integer key
+func (f *RangeShard) Shard(key interface{}) (int, error) {
+	val, err := shardKeyToInt(key)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range f.ranges {
+		if val >= v.Min && val < v.Max {
+			return v.Index, nil
+		}
+	}
+	return 0, ErrShardNotFound
+}
+
+/////////////////
+//lookup shard
+/////////////////
+
+//lookup func for key not in table
+type ShardLookupFunc func(key interface{}) (int, error)
+
+//face info
+type LookupShard struct {
+	table map[string]int
+	lookup ShardLookupFunc
+	sync.RWMutex
+}
+
+//construct
+//lookup func is optional, result will be cached
+func NewLookupShard(table map[string]int, lookups ...ShardLookupFunc) *LookupShard {
+	this := &LookupShard{
+		table: map[string]int{},
+	}
+	for k, v := range table {
+		this.table[k] = v
+	}
+	if lookups != nil && len(lookups) > 0 {
+		this.lookup = lookups[0]
+	}
+	return this
+}
+
+//set key table index
+func (f *LookupShard) Set(key interface{}, index int) {
+	f.Lock()
+	defer f.Unlock()
+	f.table[toString(key)] = index
+}
+
+//find table index of key
+func (f *LookupShard) Shard(key interface{}) (int, error) {
+	keyStr := toString(key)
+	f.RLock()
+	index, ok := f.table[keyStr]
+	f.RUnlock()
+	if ok {
+		return index, nil
+	}
+	if f.lookup == nil {
+		return 0, ErrShardNotFound
+	}
+	index, err := f.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	f.Set(keyStr, index)
+	return index, nil
+}
+
+/////////////////
+//shard table
+/////////////////
+
+//face info
+type ShardTable struct {
+	table string
+	shards int
+	shardFunc IShardFunc
+	connMap map[int]*Connect //table index -> connect
+}
+
+//create shard table from config, connects get from mysql by node tag
+func (f *Mysql) CreateShardTable(conf *ShardConfig, shardFuncs ...IShardFunc) (*ShardTable, error) {
+	//check
+	if conf == nil || conf.Table == "" || conf.Shards <= 0 {
+		return nil, errors.New("invalid parameter")
+	}
+
+	//init shard func
+	var shardFunc IShardFunc
+	if shardFuncs != nil && len(shardFuncs) > 0 {
+		shardFunc = shardFuncs[0]
+	}else{
+		switch conf.Func {
+		case ShardFuncOfRange:
+			shardFunc = NewRangeShard(conf.Ranges...)
+		case ShardFuncOfLookup:
+			shardFunc = NewLookupShard(conf.Lookup)
+		case ShardFuncOfHash, "":
+			shardFunc = NewHashShard(conf.Shards)
+		default:
+			return nil, fmt.Errorf("unsupported shard func %v", conf.Func)
+		}
+	}
+
+	//check node ranges, no gap and no overlap
+	nodeMap := map[int]*ShardNode{} //table index -> node
+	for _, node := range conf.Nodes {
+		if node == nil || node.Tag == "" {
+			return nil, errors.New("invalid shard node")
+		}
+		if node.From < 0 || node.To >= conf.Shards || node.From > node.To {
+			return nil, fmt.Errorf("node %v range [%d, %d] out of shards %d",
+				node.Tag, node.From, node.To, conf.Shards)
+		}
+		for i := node.From; i <= node.To; i++ {
+			if v, ok := nodeMap[i]; ok {
+				return nil, fmt.Errorf("table %v_%d on both node %v and %v", conf.Table, i, v.Tag, node.Tag)
+			}
+			nodeMap[i] = node
+		}
+	}
+	for i := 0; i < conf.Shards; i++ {
+		if _, ok := nodeMap[i]; !ok {
+			return nil, fmt.Errorf("table %v_%d has no node", conf.Table, i)
+		}
+	}
+
+	//map table index to connect
+	st := &ShardTable{
+		table: conf.Table,
+		shards: conf.Shards,
+		shardFunc: shardFunc,
+		connMap: map[int]*Connect{},
+	}
+	createdTags := make([]string, 0)
+	for _, node := range conf.Nodes {
+		var err error
+		conn := f.GetConnect(node.Tag)
+		if conn == nil && node.Conf != nil {
+			if conn, err = f.CreateConnect(node.Tag, node.Conf); err == nil {
+				createdTags = append(createdTags, node.Tag)
+			}
+		}
+		if conn == nil && err == nil {
+			err = fmt.Errorf("connect of tag %v not found", node.Tag)
+		}
+		if err != nil {
+			//remove connects created by this table
+			for _, tag := range createdTags {
+				f.RemoveConnect(tag)
+			}
+			return nil, err
+		}
+		for i := node.From; i <= node.To; i++ {
+			st.connMap[i] = conn
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.shardMap[conf.Table] = st
+	return st, nil
+}
+
+//load shard tables from config
+func (f *Mysql) LoadShardConfig(confs []*ShardConfig) error {
+	for _, conf := range confs {
+		if _, err := f.CreateShardTable(conf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//get shard table
+func (f *Mysql) GetShardTable(table string) *ShardTable {
+	f.RLock()
+	defer f.RUnlock()
+	v, ok := f.shardMap[table]
+	if ok && v != nil {
+		return v
+	}
+	return nil
+}
+
+//route shard key to connect and table name
+func (f *ShardTable) Route(key interface{}) (*Connect, string, error) {
+	index, err := f.shardFunc.Shard(key)
+	if err != nil {
+		return nil, "", err
+	}
+	conn, ok := f.connMap[index]
+	if !ok || index < 0 || index >= f.shards {
+		return nil, "", ErrShardNotFound
+	}
+	return conn, f.GetTableName(index), nil
+}
+
+//get table name of index
+func (f *ShardTable) GetTableName(index int) string {
+	return fmt.Sprintf("%s_%d", f.table, index)
+}
+
+//get shard count
+func (f *ShardTable) GetShards() int {
+	return f.shards
+}
+
+//run fn on shard of key, for `JsonData` api
+//like: st.WithShard(uid, func(conn *Connect, table string) error {
+//	data, err = db.GetOneData(whereMap, "", table, conn)
+//	return err
+//})
+func (f *ShardTable) WithShard(key interface{}, fn func(conn *Connect, table string) error) error {
+	conn, table, err := f.Route(key)
+	if err != nil {
+		return err
+	}
+	return fn(conn, table)
+}
+
+//execute sql on shard of key
+func (f *ShardTable) Execute(ctx context.Context, key interface{}, query string, args ...interface{}) (int64, int64, error) {
+	conn, table, err := f.Route(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	return conn.ExecuteContext(ctx, f.formatSql(query, table), args...)
+}
+
+//get rows on shard of key
+func (f *ShardTable) GetRows(ctx context.Context, key interface{}, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	conn, table, err := f.Route(key)
+	if err != nil {
+		return nil, err
+	}
+	return conn.GetRowsContext(ctx, f.formatSql(query, table), args...)
+}
+
+//get one row on shard of key
+func (f *ShardTable) GetRow(ctx context.Context, key interface{}, query string, args ...interface{}) (map[string]interface{}, error) {
+	conn, table, err := f.Route(key)
+	if err != nil {
+		return nil, err
+	}
+	return conn.GetRowContext(ctx, f.formatSql(query, table), args...)
+}
+
+//run query on all shards concurrently, merge, sort and limit
+//query on each shard should limit `Offset + Limit` rows itself
+func (f *ShardTable) Scatter(
+			ctx context.Context,
+			opt *GatherOption,
+			query string,
+			args ...interface{},
+		) ([]map[string]interface{}, error) {
+	var (
+		wg sync.WaitGroup
+		lock sync.Mutex
+		firstErr error
+	)
+	result := make([]map[string]interface{}, 0)
+	for i := 0; i < f.shards; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			records, err := f.connMap[index].GetRowsContext(ctx, f.formatSql(query, f.GetTableName(index)), args...)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("shard %v failed, err:%v", f.GetTableName(index), err)
+				}
+				return
+			}
+			result = append(result, records...)
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return f.gather(result, opt), nil
+}
+
+////////////////
+//private func
+////////////////
+
+//merge sort and limit records
+func (f *ShardTable) gather(records []map[string]interface{}, opt *GatherOption) []map[string]interface{} {
+	if opt == nil {
+		return records
+	}
+	if opt.OrderBy != "" {
+		sort.SliceStable(records, func(i, j int) bool {
+			ret := compareShardVal(records[i][opt.OrderBy], records[j][opt.OrderBy])
+			if opt.Desc {
+				return ret > 0
+			}
+			return ret < 0
+		})
+	}
+	if opt.Offset > 0 {
+		if opt.Offset >= len(records) {
+			return make([]map[string]interface{}, 0)
+		}
+		records = records[opt.Offset:]
+	}
+	if opt.Limit > 0 && len(records) > opt.Limit {
+		records = records[:opt.Limit]
+	}
+	return records
+}
+
+//replace table placeholder
+func (f *ShardTable) formatSql(query, table string) string {
+	return strings.Replace(query, ShardTablePlaceholder, table, -1)
+}
+
+//compare field value, numeric first, nil is smallest
+func compareShardVal(a, b interface{}) int {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0
+		}
+		if a == nil {
+			return -1
+		}
+		return 1
+	}
+	aStr, bStr := toString(a), toString(b)
+	aNum, errA := strconv.ParseFloat(aStr, 64)
+	bNum, errB := strconv.ParseFloat(bStr, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(aStr, bStr)
+}
+
+//convert shard key to integer
+func shardKeyToInt(key interface{}) (int64, error) {
+	switch v := key.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return 0, ErrShardKeyInvalid
+		}
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, ErrShardKeyInvalid
+		}
+		return int64(v), nil
+	case string:
+		val, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, ErrShardKeyInvalid
+		}
+		return val, nil
+	}
+	return 0, ErrShardKeyInvalid
+}
+
+//abs of int64 as uint64, no overflow on min int64
+func absUint64(v int64) uint64 {
+	if v < 0 {
+		return uint64(^v) + 1
+	}
+	return uint64(v)
+}
@@ -0,0 +1,205 @@
+package mysql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+ * LRU cache of prepared statements, keyed by sql text
+ * - statement is reference counted, closed after evicted and released
+ * - cleared when server reconnected by pool checker
+ */
+
+//statement cache statistics
+type StmtCacheStats struct {
+	Size int
+	Capacity int
+	Hits uint64
+	Misses uint64
+}
+
+//cached statement
+type stmtEntry struct {
+	query string
+	stmt *sql.Stmt
+	refs int
+	evicted bool
+}
+
+//statement cache
+type stmtCache struct {
+	capacity int
+	hits uint64
+	misses uint64
+	lru *list.List
+	items map[string]*list.Element
+	sync.Mutex
+}
+
+//get statement cache statistics, nil if cache disabled
+func (f *Connect) GetStmtCacheStats() *StmtCacheStats {
+	cache := f.getOrigin().stmtCache
+	if cache == nil {
+		return nil
+	}
+	return cache.stats()
+}
+
+////////////////
+//private func
+////////////////
+
+//exec sql, use cached statement if enabled
+func (f *Connect) exec(ctx context.Context, db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	cache := f.getOrigin().stmtCache
+	if cache == nil {
+		return db.ExecContext(ctx, query, args...)
+	}
+	entry, err := cache.get(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer cache.put(entry)
+	return entry.stmt.ExecContext(ctx, args...)
+}
+
+//query sql and handle rows by fn, use cached statement if enabled
+//rows will be closed by fn
+func (f *Connect) query(
+			ctx context.Context,
+			db *sql.DB,
+			fn func(rows *sql.Rows) error,
+			query string,
+			args ...interface{},
+		) error {
+	var (
+		rows *sql.Rows
+		err error
+	)
+	cache := f.getOrigin().stmtCache
+	if cache == nil {
+		rows, err = db.QueryContext(ctx, query, args...)
+	}else{
+		var entry *stmtEntry
+		entry, err = cache.get(ctx, db, query)
+		if err != nil {
+			return err
+		}
+		defer cache.put(entry)
+		rows, err = entry.stmt.QueryContext(ctx, args...)
+	}
+	if err != nil {
+		return err
+	}
+	return fn(rows)
+}
+
+//create statement cache, nil if capacity <= 0
+func newStmtCache(capacity int) *stmtCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &stmtCache{
+		capacity: capacity,
+		lru: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+//get or prepare statement, should `put` after used
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, query string) (*stmtEntry, error) {
+	//hit
+	if entry := c.acquire(query); entry != nil {
+		atomic.AddUint64(&c.hits, 1)
+		return entry, nil
+	}
+
+	//miss, prepare without lock
+	atomic.AddUint64(&c.misses, 1)
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if el, ok := c.items[query]; ok {
+		//prepared by others
+		stmt.Close()
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		c.lru.MoveToFront(el)
+		return entry, nil
+	}
+	entry := &stmtEntry{
+		query: query,
+		stmt: stmt,
+		refs: 1,
+	}
+	c.items[query] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.capacity {
+		c.evict(c.lru.Back())
+	}
+	return entry, nil
+}
+
+//release statement
+func (c *stmtCache) put(entry *stmtEntry) {
+	c.Lock()
+	defer c.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs <= 0 {
+		entry.stmt.Close()
+	}
+}
+
+//clear all statements
+func (c *stmtCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+	for c.lru.Len() > 0 {
+		c.evict(c.lru.Back())
+	}
+}
+
+//get statistics
+func (c *stmtCache) stats() *StmtCacheStats {
+	c.Lock()
+	size := c.lru.Len()
+	c.Unlock()
+	return &StmtCacheStats{
+		Size: size,
+		Capacity: c.capacity,
+		Hits: atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+//acquire cached statement
+func (c *stmtCache) acquire(query string) *stmtEntry {
+	c.Lock()
+	defer c.Unlock()
+	el, ok := c.items[query]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*stmtEntry)
+	entry.refs++
+	c.lru.MoveToFront(el)
+	return entry
+}
+
+//evict element, closed if not in use
+func (c *stmtCache) evict(el *list.Element) {
+	entry := el.Value.(*stmtEntry)
+	c.lru.Remove(el)
+	delete(c.items, entry.query)
+	entry.evicted = true
+	if entry.refs <= 0 {
+		entry.stmt.Close()
+	}
+}
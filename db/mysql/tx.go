@@ -0,0 +1,232 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"math/rand"
+	"time"
+)
+
+/*
+ * multi statement transaction
+ * - commit on nil, rollback on error or panic
+ * - nested `WithTx` use savepoint
+ * - retry whole transaction on deadlock with backoff
+ */
+
+//error number of mysql
+const (
+	ErrNumOfDeadlock = 1213
+)
+
+//default value
+const (
+	TxMaxRetry = 3
+	TxRetryBackoff = 50 * time.Millisecond
+	TxSavepointPrefix = "tc_sp_"
+)
+
+//transaction option
+type TxOption struct {
+	Isolation sql.IsolationLevel //default is sql.LevelDefault
+	ReadOnly bool
+	MaxRetry int //retry times on deadlock, 0 means no retry
+	RetryBackoff time.Duration //base backoff, doubled each retry
+}
+
+//transaction interface
+type Tx interface {
+	Execute(query string, args ...interface{}) (int64, int64, error)
+	GetRow(query string, args ...interface{}) (map[string]interface{}, error)
+	GetRows(query string, args ...interface{}) ([]map[string]interface{}, error)
+	Select(dest interface{}, query string, args ...interface{}) error
+	Get(dest interface{}, query string, args ...interface{}) error
+	//nested transaction by savepoint
+	WithTx(ctx context.Context, fn func(tx Tx) error) error
+	GetTx() *sql.Tx
+	GetContext() context.Context
+}
+
+//tx info
+type connectTx struct {
+	conn *Connect
+	tx *sql.Tx
+	ctx context.Context
+	depth int
+}
+
+//gen default tx option
+func NewTxOption() *TxOption {
+	return &TxOption{
+		Isolation: sql.LevelDefault,
+		MaxRetry: TxMaxRetry,
+		RetryBackoff: TxRetryBackoff,
+	}
+}
+
+//run fn in transaction
+//commit if fn return nil, rollback if fn return error or panic
+//whole fn will be re-run on deadlock, so fn should be re-entrant
+func (f *Connect) WithTx(ctx context.Context, fn func(tx Tx) error, opts ...*TxOption) error {
+	//check
+	if fn == nil {
+		return errors.New("invalid parameter")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	opt := NewTxOption()
+	if opts != nil && len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+
+	//run with retry
+	backoff := opt.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := f.runTx(ctx, fn, opt)
+		if err == nil || !IsDeadlock(err) || attempt >= opt.MaxRetry {
+			return err
+		}
+		//wait backoff with jitter
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff) + 1))
+		select {
+		case <- time.After(wait):
+		case <- ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+//check error is deadlock
+func IsDeadlock(err error) bool {
+	var myErr *mysqlDriver.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == ErrNumOfDeadlock
+	}
+	return false
+}
+
+//execute sql in tx
+func (f *connectTx) Execute(query string, args ...interface{}) (int64, int64, error) {
+	ctx, cancel := f.conn.withTimeout(f.ctx)
+	defer cancel()
+	beginTime := time.Now()
+	result, err := f.tx.ExecContext(ctx, query, args...)
+	f.conn.logSlow(beginTime, query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	lastInsertId, _ := result.LastInsertId()
+	effectRows, _ := result.RowsAffected()
+	return lastInsertId, effectRows, nil
+}
+
+//get one row record in tx
+func (f *connectTx) GetRow(query string, args ...interface{}) (map[string]interface{}, error) {
+	records, err := f.GetRows(fmt.Sprintf("%s LIMIT 1", query), args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) <= 0 {
+		return map[string]interface{}{}, nil
+	}
+	return records[0], nil
+}
+
+//get batch row records in tx
+func (f *connectTx) GetRows(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	ctx, cancel := f.conn.withTimeout(f.ctx)
+	defer cancel()
+	beginTime := time.Now()
+	defer f.conn.logSlow(beginTime, query, args...)
+	rows, err := f.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRowsToMap(rows)
+}
+
+//nested transaction by savepoint
+//rollback to savepoint if fn return error or panic
+func (f *connectTx) WithTx(ctx context.Context, fn func(tx Tx) error) (err error) {
+	if fn == nil {
+		return errors.New("invalid parameter")
+	}
+	if ctx == nil {
+		ctx = f.ctx
+	}
+	savepoint := fmt.Sprintf("%s%d", TxSavepointPrefix, f.depth + 1)
+	if _, err = f.tx.ExecContext(ctx, "SAVEPOINT " + savepoint); err != nil {
+		return err
+	}
+	nested := &connectTx{
+		conn: f.conn,
+		tx: f.tx,
+		ctx: ctx,
+		depth: f.depth + 1,
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			f.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT " + savepoint)
+			panic(p)
+		}
+		if err != nil {
+			f.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT " + savepoint)
+			return
+		}
+		_, err = f.tx.ExecContext(ctx, "RELEASE SAVEPOINT " + savepoint)
+	}()
+	err = fn(nested)
+	return err
+}
+
+//get original tx
+func (f *connectTx) GetTx() *sql.Tx {
+	return f.tx
+}
+
+//get context of tx
+func (f *connectTx) GetContext() context.Context {
+	return f.ctx
+}
+
+////////////////
+//private func
+////////////////
+
+//run fn in single transaction
+func (f *Connect) runTx(ctx context.Context, fn func(tx Tx) error, opt *TxOption) (err error) {
+	db, err := f.getDB()
+	if err != nil {
+		return err
+	}
+	sqlTx, err := db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: opt.Isolation,
+		ReadOnly: opt.ReadOnly,
+	})
+	f.markResult(err)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			sqlTx.Rollback()
+			return
+		}
+		err = sqlTx.Commit()
+	}()
+	err = fn(&connectTx{
+		conn: f,
+		tx: sqlTx,
+		ctx: ctx,
+	})
+	return err
+}
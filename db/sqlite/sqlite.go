@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/andyzhou/tinycells/db/builder"
 	_ "github.com/mattn/go-sqlite3"
 	"log"
 )
@@ -44,9 +45,20 @@ func (s *SqlLite) OpenDBFile(dbFile string) error {
 
 	//sync db
 	s.db = db
+	s.dbFile = dbFile
 	return nil
 }
 
+//get db instance
+func (s *SqlLite) GetDB() *sql.DB {
+	return s.db
+}
+
+//get db file
+func (s *SqlLite) GetDBFile() string {
+	return s.dbFile
+}
+
 //close db
 func (s *SqlLite) Close() {
 	if s.db != nil {
@@ -79,6 +91,24 @@ func (s *SqlLite) Execute(sql string, args []interface{}) (int64, int64, error)
 	return lastInsertId, effectRows, nil
 }
 
+//execute sql of builder, builder should use sqlite dialect
+func (s *SqlLite) ExecuteBy(b builder.IBuilder) (int64, int64, error) {
+	sql, args, err := b.ToSql()
+	if err != nil {
+		return 0, 0, err
+	}
+	return s.Execute(sql, args)
+}
+
+//query sql of builder, builder should use sqlite dialect
+func (s *SqlLite) QueryBy(b builder.IBuilder) ([]map[string]string, error) {
+	sql, args, err := b.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(sql, args)
+}
+
 //query
 func (s *SqlLite) Query(sql string, args []interface{}) ([]map[string]string, error) {
 	var (
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/andyzhou/tinycells/crypt"
+	"github.com/andyzhou/tinycells/web"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJwtAuthGetToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := web.NewJwtAuth(crypt.NewJwt("secret"))
+
+	//header first, then cookie, then query
+	newCtx := func(header, cookie, query string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/?access_token=" + query, nil)
+		if header != "" {
+			c.Request.Header.Set(web.AuthHeader, web.AuthBearerPrefix + header)
+		}
+		if cookie != "" {
+			c.Request.AddCookie(&http.Cookie{Name: web.AuthCookieName, Value: cookie})
+		}
+		return c
+	}
+	if token := auth.GetToken(newCtx("h", "c", "q")); token != "h" {
+		t.Fatalf("header token should be first, token:%v", token)
+	}
+	if token := auth.GetToken(newCtx("", "c", "q")); token != "c" {
+		t.Fatalf("cookie token should be second, token:%v", token)
+	}
+	if token := auth.GetToken(newCtx("", "", "q")); token != "q" {
+		t.Fatalf("query token should be last, token:%v", token)
+	}
+}
+
+func TestJwtAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := web.NewJwtAuth(crypt.NewJwt("secret"))
+
+	//issue without redis, access token only
+	token, err := auth.IssueToken("u1", []string{"read"})
+	if err != nil || token.AccessToken == "" || token.RefreshToken != "" {
+		t.Fatalf("issue token failed, token:%v, err:%v", token, err)
+	}
+	if _, err = auth.Refresh("any"); err != web.ErrAuthNoRedis {
+		t.Fatalf("refresh without redis should fail, err:%v", err)
+	}
+
+	//scope check
+	router := gin.New()
+	ok := func(c *gin.Context) {
+		c.String(http.StatusOK, web.GetAuthClaims(c).Subject)
+	}
+	router.GET("/read", auth.Middleware("read"), ok)
+	router.GET("/write", auth.Middleware("write"), ok)
+	request := func(path, accessToken string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accessToken != "" {
+			req.Header.Set(web.AuthHeader, web.AuthBearerPrefix + accessToken)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := request("/read", token.AccessToken); w.Code != http.StatusOK || w.Body.String() != "u1" {
+		t.Fatalf("read should pass, code:%v", w.Code)
+	}
+	if w := request("/write", token.AccessToken); w.Code != http.StatusForbidden {
+		t.Fatalf("write should be forbidden, code:%v", w.Code)
+	}
+	if w := request("/read", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("no token should be unauthorized, code:%v", w.Code)
+	}
+	if w := request("/read", token.AccessToken + "x"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad token should be unauthorized, code:%v", w.Code)
+	}
+}
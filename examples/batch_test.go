@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/andyzhou/tinycells/db/mysql"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenBatchInsert(t *testing.T) {
+	rows := make([]map[string]interface{}, 0)
+	for i := 0; i < 10; i++ {
+		rows = append(rows, map[string]interface{}{
+			"id": i,
+			"data": []byte(fmt.Sprintf(`{"n":%d}`, i)),
+		})
+	}
+
+	//chunk by rows
+	jd := &mysql.JsonData{}
+	chunks, err := jd.GenBatchInsert(rows, nil, "user", &mysql.BatchOption{MaxRows: 3})
+	if err != nil || len(chunks) != 4 || chunks[3].Rows != 1 {
+		t.Fatalf("gen batch insert failed, chunks:%v, err:%v", len(chunks), err)
+	}
+
+	//run on sqlite
+	db := newSqliteTestDB(t, "CREATE TABLE user (id INTEGER PRIMARY KEY, data TEXT)")
+	defer db.Close()
+	for _, chunk := range chunks {
+		if _, err = db.Exec(chunk.Sql, chunk.Values...); err != nil {
+			t.Fatalf("exec chunk failed, sql:%v, err:%v", chunk.Sql, err)
+		}
+	}
+	var total int
+	db.QueryRow("SELECT COUNT(*) FROM user").Scan(&total)
+	if total != 10 {
+		t.Fatalf("total invalid, total:%v", total)
+	}
+
+	//chunk by packet size and upsert sql
+	chunks, _ = jd.GenBatchInsert(rows, map[string]int{
+		"data": mysql.BatchUpdateOfJsonMerge,
+	}, "user", &mysql.BatchOption{MaxRows: 100, MaxPacket: 1024 + 200})
+	if len(chunks) <= 1 {
+		t.Fatalf("chunk by packet failed, chunks:%v", len(chunks))
+	}
+	if !strings.HasSuffix(chunks[0].Sql, "ON DUPLICATE KEY UPDATE `data` = JSON_MERGE_PATCH(IFNULL(`data`, JSON_OBJECT()), VALUES(`data`))") {
+		t.Fatalf("upsert sql invalid, sql:%v", chunks[0].Sql)
+	}
+
+	//table and field names quoted
+	chunks, _ = jd.GenBatchInsert([]map[string]interface{}{{"na`me": "a", "order": 1}},
+		map[string]int{"order": mysql.BatchUpdateOfInc}, "user log", nil)
+	expect := "INSERT INTO `user log`(`na``me`,`order`) VALUES(?,?) ON DUPLICATE KEY UPDATE `order` = `order` + VALUES(`order`)"
+	if len(chunks) != 1 || chunks[0].Sql != expect {
+		t.Fatalf("quoted sql invalid, sql:%v", chunks[0].Sql)
+	}
+
+	//rows fields not match
+	rows = append(rows, map[string]interface{}{"id": 10})
+	if _, err = jd.GenBatchInsert(rows, nil, "user", nil); err == nil {
+		t.Fatal("fields not match should fail")
+	}
+}
+
+func TestUpsertBatchData(t *testing.T) {
+	conn := newSqliteTestConnect(t, "CREATE TABLE user (id INTEGER PRIMARY KEY, data TEXT)",
+		"INSERT INTO user VALUES (3, '{}')")
+	defer conn.Quit()
+	rows := make([]map[string]interface{}, 0)
+	for i := 0; i < 6; i++ {
+		rows = append(rows, map[string]interface{}{"id": i, "data": "{}"})
+	}
+
+	//chunk with duplicate key failed alone, option of caller kept
+	//`ON DUPLICATE KEY UPDATE` is mysql only, update clause not executed here
+	jd := &mysql.JsonData{}
+	opt := &mysql.BatchOption{MaxRows: 2}
+	results, err := jd.UpsertBatchDataContext(context.Background(), rows, nil, "user", conn, opt)
+	if err == nil || len(results) != 3 || opt.MaxPacket != 0 {
+		t.Fatalf("upsert result invalid, results:%v, err:%v", len(results), err)
+	}
+	for i, result := range results {
+		if result.Index != i || result.Rows != 2 || (result.Err != nil) != (i == 1) {
+			t.Fatalf("chunk result invalid, result:%+v", result)
+		}
+		if result.Err == nil && result.EffectRows != 2 {
+			t.Fatalf("chunk effect rows invalid, result:%+v", result)
+		}
+	}
+	if records, _ := conn.GetRows("SELECT id FROM user"); len(records) != 5 {
+		t.Fatalf("rows of succeed chunks invalid, records:%v", len(records))
+	}
+
+	//stop on error
+	conn.Execute("DELETE FROM user WHERE id <> 3")
+	opt.StopOnError = true
+	results, err = jd.UpsertBatchData(rows, nil, "user", conn, opt)
+	if err == nil || len(results) != 2 {
+		t.Fatalf("stop on error invalid, results:%v, err:%v", len(results), err)
+	}
+}
+
+func TestLoadDataFile(t *testing.T) {
+	//not exists file, fail before run
+	db := mysql.NewConnectWithDB(nil)
+	defer db.Quit()
+	jd := &mysql.JsonData{}
+	if _, err := jd.LoadDataFileContext(context.Background(), "not_exists.csv", nil, "user", db); !os.IsNotExist(err) {
+		t.Fatalf("load not exists file should fail, err:%v", err)
+	}
+	if _, err := jd.LoadDataFile("", nil, "user", db); err == nil {
+		t.Fatal("load empty file path should fail")
+	}
+}
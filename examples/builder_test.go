@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/andyzhou/tinycells/db/builder"
+	"github.com/andyzhou/tinycells/db/sqlite"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	//select
+	sql, args, err := builder.Select("id", "u.name", builder.Raw("count(*) AS total")).From("user").
+		Where(builder.Eq("status", 1),
+			builder.Or(builder.Gt("age", 18), builder.In("role", []string{"admin", "vip"})),
+			builder.Not(builder.Like("name", "test%"))).
+		GroupBy("id").OrderByDesc("id").Limit(10).Offset(20).ToSql()
+	expect := "SELECT `id`, `u`.`name`, count(*) AS total FROM `user` WHERE `status` = ? AND " +
+		"(`age` > ? OR `role` IN (?, ?)) AND NOT (`name` LIKE ?) GROUP BY `id` ORDER BY `id` DESC LIMIT 10 OFFSET 20"
+	if err != nil || sql != expect || !reflect.DeepEqual(args, []interface{}{1, 18, "admin", "vip", "test%"}) {
+		t.Fatalf("select sql invalid, sql:%v, args:%v, err:%v", sql, args, err)
+	}
+
+	//identifier always quoted, quote char escaped
+	sql, _, _ = builder.Select("*", "na`me", "u.*").From("user; DROP TABLE user").
+		Where(builder.Eq("a` = 1 OR `b", 1)).OrderBy(builder.Raw("RAND()")).ToSql()
+	expect = "SELECT *, `na``me`, `u`.* FROM `user; DROP TABLE user` WHERE `a`` = 1 OR ``b` = ? ORDER BY RAND() ASC"
+	if sql != expect {
+		t.Fatalf("quoted sql invalid, sql:%v", sql)
+	}
+	if _, _, err = builder.Select(1).From("user").ToSql(); err == nil {
+		t.Fatal("invalid column type should fail")
+	}
+	sql, _, _ = builder.New(builder.DialectOfSqlite).Select(`na"me`).From("user").ToSql()
+	if sql != `SELECT "na""me" FROM "user"` {
+		t.Fatalf("sqlite quoted sql invalid, sql:%v", sql)
+	}
+
+	//expression and empty or wrapped
+	sql, args, _ = builder.Delete("user").
+		Where(builder.Expr("role = ? OR role = ?", "a", "b"), builder.Eq("status", 0), builder.Or()).ToSql()
+	if sql != "DELETE FROM `user` WHERE (role = ? OR role = ?) AND `status` = ? AND (1 = 0)" ||
+		!reflect.DeepEqual(args, []interface{}{"a", "b", 0}) {
+		t.Fatalf("expression sql invalid, sql:%v, args:%v", sql, args)
+	}
+
+	//upsert
+	sql, args, _ = builder.Insert("counter").SetMap(map[string]interface{}{"name": "a", "num": 1}).
+		OnConflict([]string{"name"}).OnConflictExpr("num", "`num` + ?", 1).ToSql()
+	if sql != "INSERT INTO `counter` (`name`, `num`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `num` = `num` + ?" {
+		t.Fatalf("upsert sql invalid, sql:%v", sql)
+	}
+
+	//run on sqlite
+	dbFile := "builder_test.db"
+	defer os.Remove(dbFile)
+	db := sqlite.NewSqlLite()
+	db.OpenDBFile(dbFile)
+	defer db.Close()
+	db.Execute("CREATE TABLE counter (name TEXT PRIMARY KEY, num INTEGER)", nil)
+	b := builder.New(builder.DialectOfSqlite)
+	for i := 0; i < 2; i++ {
+		upsert := b.Insert("counter").Columns("name", "num").Values("a", 1).
+			OnConflict([]string{"name"}).OnConflictExpr("num", `"num" + excluded."num"`)
+		if _, _, err = db.ExecuteBy(upsert); err != nil {
+			t.Fatalf("sqlite upsert failed, err:%v", err)
+		}
+	}
+	db.ExecuteBy(b.Update("counter").SetExpr("num", `"num" * ?`, 10).Where(builder.Eq("name", "a")))
+	rows, err := db.QueryBy(b.Select("num").From("counter").Where(builder.Eq("name", "a")))
+	if err != nil || len(rows) != 1 || rows[0]["num"] != "20" {
+		t.Fatalf("sqlite query failed, rows:%v, err:%v", rows, err)
+	}
+	db.ExecuteBy(b.Delete("counter").Where(builder.In("name", "a")))
+	if rows, _ = db.QueryBy(b.Select().From("counter")); len(rows) != 0 {
+		t.Fatalf("sqlite delete failed, rows:%v", rows)
+	}
+}
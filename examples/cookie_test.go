@@ -0,0 +1,92 @@
+package main
+
+import (
+	"github.com/andyzhou/tinycells/web"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type cookieUser struct {
+	Id int64 `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestSecureCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldHash, oldBlock := []byte("old-hash-key-old-hash-key-old-ha"), []byte("old-block-key-old-block-key-old-")
+	newHash, newBlock := []byte("new-hash-key-new-hash-key-new-ha"), []byte("new-block-key-new-block-key-new-")
+
+	//set by old key
+	cookie := web.NewCookie()
+	cookie.SetSecureKeys(oldHash, oldBlock)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	err := cookie.SetSecureCookie("user", &cookieUser{Id: 1, Name: "tc"}, 60, c)
+	if err != nil {
+		t.Fatalf("set secure cookie failed, err:%v", err)
+	}
+	setCookie := w.Result().Cookies()
+	if len(setCookie) != 1 || setCookie[0].SameSite != http.SameSiteLaxMode || !setCookie[0].HttpOnly {
+		t.Fatalf("cookie option invalid, cookies:%v", setCookie)
+	}
+
+	//rotate keys, old cookie still readable
+	cookie.SetSecureKeys(newHash, newBlock, oldHash, oldBlock)
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.AddCookie(setCookie[0])
+	user := &cookieUser{}
+	if err = cookie.GetSecureCookie("user", user, c); err != nil || user.Name != "tc" {
+		t.Fatalf("get secure cookie failed, user:%v, err:%v", user, err)
+	}
+
+	//drop old key, cookie rejected
+	cookie.SetSecureKeys(newHash, newBlock)
+	if err = cookie.GetSecureCookie("user", user, c); err == nil {
+		t.Fatal("cookie with removed key should fail")
+	}
+}
+
+//cookie with fixed secure keys
+func newTestCookie() *web.Cookie {
+	cookie := web.NewCookie()
+	cookie.SetSecureKeys([]byte("test-hash-key-test-hash-key-test"), []byte("test-block-key-test-block-key-te"))
+	return cookie
+}
+
+func TestSecureCookieNoKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	//no secure keys, fail closed
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.AddCookie(&http.Cookie{Name: "user", Value: "x"})
+	cookie := web.NewCookie()
+	if err := cookie.SetSecureCookie("user", &cookieUser{Id: 1}, 60, c); err != web.ErrCookieNoKey {
+		t.Fatalf("set secure cookie without key should fail, err:%v", err)
+	}
+	if err := cookie.GetSecureCookie("user", &cookieUser{}, c); err != web.ErrCookieNoKey {
+		t.Fatalf("get secure cookie without key should fail, err:%v", err)
+	}
+}
+
+func TestDelCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cookie := web.NewCookie()
+
+	//path, secure and same site kept same as set
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	option := &web.CookieOption{Path: "/app", Domain: "a.com", Secure: true, SameSite: http.SameSiteStrictMode}
+	if err := cookie.DelCookie("user", "", c, option); err != nil {
+		t.Fatalf("del cookie failed, err:%v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge != -1 || cookies[0].Path != "/app" || cookies[0].Domain != "a.com" ||
+		!cookies[0].Secure || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("del cookie option invalid, cookies:%+v", cookies)
+	}
+}
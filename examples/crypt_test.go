@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"github.com/andyzhou/tinycells/crypt"
+	"github.com/andyzhou/tinycells/media"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAead(t *testing.T) {
+	aead := crypt.NewAead()
+	key, _ := aead.GenKey()
+	aead.SetKey(key)
+	aead.SetAlg(crypt.AeadAlgOfXChaCha20)
+
+	//key mode
+	encStr, err := aead.EncryptString("hello")
+	if err != nil {
+		t.Fatalf("encrypt failed, err:%v", err)
+	}
+	plain, err := aead.DecryptString(encStr)
+	if err != nil || plain != "hello" {
+		t.Fatalf("decrypt failed, plain:%v, err:%v", plain, err)
+	}
+
+	//password mode
+	data, _ := aead.EncryptWithPassword([]byte("pwd"), []byte("world"))
+	if _, err = aead.DecryptWithPassword([]byte("bad"), data); err == nil {
+		t.Fatal("wrong password should fail")
+	}
+
+	//kdf params kept in header, decrypted by default params
+	custom := crypt.NewAead()
+	custom.SetKdf(crypt.AeadKdfOfScrypt)
+	if err = custom.SetKdfParams(&crypt.PasswordParams{Argon2Time: 1, Argon2Memory: 8 * 1024,
+		Argon2Threads: 1, ScryptLogN: 10, ScryptR: 8, ScryptP: 1}); err != nil {
+		t.Fatalf("set kdf params failed, err:%v", err)
+	}
+	data, _ = custom.EncryptWithPassword([]byte("pwd"), []byte("world"))
+	if plainData, err := aead.DecryptWithPassword([]byte("pwd"), data); err != nil || string(plainData) != "world" {
+		t.Fatalf("decrypt with header params failed, err:%v", err)
+	}
+	data[3] = 40
+	if _, err = aead.DecryptWithPassword([]byte("pwd"), data); err == nil {
+		t.Fatal("out of range kdf params should fail")
+	}
+
+	//migrate legacy value
+	simple := crypt.NewSimpleEncrypt()
+	legacy, _ := simple.Encrypt("legacy")
+	newStr, err := aead.MigrateSimple(simple, legacy)
+	if err != nil {
+		t.Fatalf("migrate failed, err:%v", err)
+	}
+	plain, isLegacy, err := aead.DecryptCompat(simple, newStr)
+	if err != nil || isLegacy || plain != "legacy" {
+		t.Fatalf("decrypt compat failed, plain:%v, err:%v", plain, err)
+	}
+	if plain, isLegacy, err = aead.DecryptCompat(simple, legacy); err != nil || !isLegacy || plain != "legacy" {
+		t.Fatalf("decrypt compat legacy failed, plain:%v, err:%v", plain, err)
+	}
+
+	//tampered aead value should not fall back to legacy
+	tampered := []byte(newStr)
+	last := len(tampered) - 2
+	if tampered[last] == 'A' {
+		tampered[last] = 'B'
+	}else{
+		tampered[last] = 'A'
+	}
+	if _, isLegacy, err = aead.DecryptCompat(simple, string(tampered)); err == nil || isLegacy {
+		t.Fatalf("tampered value should fail, legacy:%v, err:%v", isLegacy, err)
+	}
+}
+
+func TestRsa(t *testing.T) {
+	r := crypt.NewRsa()
+	prvPem, pubPem, err := r.GenRsaKey(crypt.RsaKeySizeOf2048)
+	if err != nil {
+		t.Fatalf("gen key failed, err:%v", err)
+	}
+
+	//re-marshal as encrypted PKCS#8
+	prvKey, _ := r.ParsePrivateKey(prvPem)
+	encPem, err := r.MarshalPrivateKey(prvKey, "pwd")
+	if err != nil {
+		t.Fatalf("marshal key failed, err:%v", err)
+	}
+	if err = r.SetKey(string(pubPem), string(encPem), "pwd"); err != nil {
+		t.Fatalf("set key failed, err:%v", err)
+	}
+
+	//sign and hybrid encrypt
+	data := make([]byte, 4096)
+	sign, _ := r.Sign(data)
+	if err = r.Verify(data, sign); err != nil {
+		t.Fatalf("verify failed, err:%v", err)
+	}
+	cipherText, err := r.HybridEncrypt(data)
+	if err != nil {
+		t.Fatalf("hybrid encrypt failed, err:%v", err)
+	}
+	plain, err := r.HybridDecrypt(cipherText)
+	if err != nil || len(plain) != len(data) {
+		t.Fatalf("hybrid decrypt failed, err:%v", err)
+	}
+}
+
+func TestEcc(t *testing.T) {
+	ecc := crypt.NewEcc()
+	prvPem, pubPem, _ := ecc.GenEcdsaKey()
+	if err := ecc.SetKey(string(pubPem), string(prvPem)); err != nil {
+		t.Fatalf("set key failed, err:%v", err)
+	}
+	sign, _ := ecc.Sign([]byte("data"))
+	if err := ecc.Verify([]byte("data"), sign); err != nil {
+		t.Fatalf("verify failed, err:%v", err)
+	}
+
+	//x25519 key exchange
+	prvA, pubA, _ := ecc.GenX25519Key()
+	prvB, pubB, _ := ecc.GenX25519Key()
+	aeadA, _ := ecc.SharedAead(prvA, pubB, []byte("device"))
+	aeadB, _ := ecc.SharedAead(prvB, pubA, []byte("device"))
+	cipherText, _ := aeadA.Encrypt([]byte("hello"))
+	plain, err := aeadB.Decrypt(cipherText)
+	if err != nil || string(plain) != "hello" {
+		t.Fatalf("shared key decrypt failed, err:%v", err)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	pwd := crypt.NewPassword()
+	hash, err := pwd.Hash("secret")
+	if err != nil {
+		t.Fatalf("hash failed, err:%v", err)
+	}
+	ok, err := pwd.Verify("secret", hash)
+	if !ok || err != nil {
+		t.Fatalf("verify failed, hash:%v, err:%v", hash, err)
+	}
+	if ok, _ = pwd.Verify("wrong", hash); ok {
+		t.Fatal("wrong password should not pass")
+	}
+
+	//out of range params should be rejected, not panic
+	parts := strings.Split(hash, "$")
+	for _, params := range []string{"m=65536,t=3,p=0", "m=65536,t=0,p=4", "m=4194304,t=3,p=4", "m=65536,t=100000,p=4"} {
+		parts[3] = params
+		if _, err = pwd.Verify("secret", strings.Join(parts, "$")); err == nil {
+			t.Fatalf("params should be rejected, params:%v", params)
+		}
+	}
+	if _, err = pwd.Verify("secret", "$scrypt$ln=40,r=8,p=1$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA"); err == nil {
+		t.Fatal("scrypt params should be rejected")
+	}
+
+	//out of range params for new hash rejected, current kept
+	for _, change := range []func(p *crypt.PasswordParams){
+		func(p *crypt.PasswordParams) { p.Argon2Threads = 0 },
+		func(p *crypt.PasswordParams) { p.ScryptLogN = 40 },
+		func(p *crypt.PasswordParams) { p.BcryptCost = 2 },
+		func(p *crypt.PasswordParams) { p.BcryptCost = 40 },
+	} {
+		params := pwd.GetParams()
+		change(&params)
+		if err = pwd.SetParams(&params); err == nil {
+			t.Fatalf("set params should fail, params:%+v", params)
+		}
+	}
+	params := pwd.GetParams()
+	params.BcryptCost = 5
+	if err = pwd.SetParams(&params); err != nil || pwd.GetParams().BcryptCost != 5 {
+		t.Fatalf("set params failed, err:%v", err)
+	}
+
+	//switch algorithm, old hash need rehash
+	pwd.SetAlg(crypt.PasswordAlgOfBcrypt)
+	if !pwd.NeedsRehash(hash) {
+		t.Fatal("hash should need rehash")
+	}
+	hash, _ = pwd.Hash("secret")
+	if ok, _ = pwd.Verify("secret", hash); !ok || pwd.NeedsRehash(hash) {
+		t.Fatalf("bcrypt verify failed, hash:%v", hash)
+	}
+}
+
+func TestKeyRing(t *testing.T) {
+	master, _ := crypt.NewAead().GenKey()
+	ring, _ := crypt.NewKeyRing(master)
+	oldId, err := ring.Rotate()
+	if err != nil {
+		t.Fatalf("rotate failed, err:%v", err)
+	}
+	oldData, _ := ring.Encrypt([]byte("hello"))
+
+	//rotate, old data still can be decrypted
+	newId, _ := ring.Rotate()
+	plain, err := ring.Decrypt(oldData)
+	if err != nil || string(plain) != "hello" {
+		t.Fatalf("decrypt old data failed, err:%v", err)
+	}
+	newData, _ := ring.ReEncrypt(oldData)
+	if id, _ := ring.GetKeyId(newData); id != newId || id == oldId {
+		t.Fatalf("re-encrypt key id invalid, id:%v", id)
+	}
+
+	//rewrap and reload by new master
+	newMaster, _ := crypt.NewAead().GenKey()
+	if err = ring.Rewrap(newMaster); err != nil {
+		t.Fatalf("rewrap failed, err:%v", err)
+	}
+	data, _ := ring.Export()
+	loaded, _ := crypt.NewKeyRing(newMaster)
+	if err = loaded.Import(data); err != nil {
+		t.Fatalf("import failed, err:%v", err)
+	}
+	plain, err = loaded.Decrypt(oldData)
+	if err != nil || string(plain) != "hello" {
+		t.Fatalf("decrypt by loaded ring failed, err:%v", err)
+	}
+	oldRing, _ := crypt.NewKeyRing(master)
+	if err = oldRing.Import(data); err == nil {
+		t.Fatal("import with old master should fail")
+	}
+
+	//wrong master should not replace current one
+	if err = loaded.SetMasterKey(master); err == nil {
+		t.Fatal("set wrong master should fail")
+	}
+	if plain, err = loaded.Decrypt(newData); err != nil || string(plain) != "hello" {
+		t.Fatalf("decrypt after wrong master failed, err:%v", err)
+	}
+	if _, err = loaded.Rotate(); err != nil {
+		t.Fatalf("rotate after wrong master failed, err:%v", err)
+	}
+	if err = loaded.Rewrap(newMaster); err != nil {
+		t.Fatalf("rewrap by current master failed, err:%v", err)
+	}
+	if _, err = crypt.NewKeyRing([]byte("short")); err == nil {
+		t.Fatal("invalid master should fail")
+	}
+}
+
+func TestOTP(t *testing.T) {
+	otp := crypt.NewOTP()
+
+	//RFC 6238 test vector, sha1 8 digits
+	otp.SetDigits(8)
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" //"12345678901234567890"
+	code, _ := otp.TOTP(secret, time.Unix(59, 0))
+	if code != "94287082" {
+		t.Fatalf("totp code invalid, code:%v", code)
+	}
+
+	//verify with drift
+	otp.SetDigits(6)
+	now := time.Now()
+	code, _ = otp.TOTP(secret, now.Add(-30 * time.Second))
+	if ok, _ := otp.VerifyTOTP(secret, code, now); !ok {
+		t.Fatal("totp in drift window should pass")
+	}
+	if ok, _ := otp.VerifyTOTP(secret, code, now.Add(90 * time.Second)); ok {
+		t.Fatal("totp out of drift window should fail")
+	}
+
+	//hotp, RFC 4226 test vector
+	next, ok, _ := otp.VerifyHOTP(secret, "338314", 2)
+	if !ok || next != 5 {
+		t.Fatalf("hotp verify failed, next:%v", next)
+	}
+
+	//uri and qr code
+	uri := otp.TotpURI("Tiny Cells", "admin@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Tiny%20Cells:admin@example.com?") {
+		t.Fatalf("uri invalid, uri:%v", uri)
+	}
+	if png, err := media.GenQrCodePng([]byte(uri)); err != nil || len(png) <= 0 {
+		t.Fatalf("gen qr code failed, err:%v", err)
+	}
+
+	//recovery codes
+	otp.SetRecoveryKey([]byte("pepper"))
+	codes, hashes, err := otp.GenRecoveryCodes(2)
+	if err != nil || len(codes) != 2 {
+		t.Fatalf("gen recovery codes failed, err:%v", err)
+	}
+	if idx, _ := otp.VerifyRecoveryCode(strings.ToUpper(codes[1]), hashes); idx != 1 {
+		t.Fatalf("verify recovery code failed, idx:%v", idx)
+	}
+	if idx, _ := otp.VerifyRecoveryCode("aaaa-bbbb", hashes); idx != -1 {
+		t.Fatalf("wrong recovery code should not match, idx:%v", idx)
+	}
+	otp.SetRecoveryKey(nil)
+	if idx, _ := otp.VerifyRecoveryCode(codes[1], hashes); idx != -1 {
+		t.Fatalf("recovery code with other key should not match, idx:%v", idx)
+	}
+}
+
+func TestStream(t *testing.T) {
+	key, _ := crypt.NewAead().GenKey()
+	stream := crypt.NewStream(key)
+	stream.SetChunkSize(1024)
+
+	//multi chunks and exact chunk size
+	for _, size := range []int{0, 1024, 5000} {
+		plain := bytes.Repeat([]byte("a"), size)
+		enc := bytes.NewBuffer(nil)
+		if err := stream.Encrypt(enc, bytes.NewReader(plain)); err != nil {
+			t.Fatalf("encrypt failed, err:%v", err)
+		}
+		encData := enc.Bytes()
+		dec := bytes.NewBuffer(nil)
+		if err := stream.Decrypt(dec, bytes.NewReader(encData)); err != nil || !bytes.Equal(dec.Bytes(), plain) {
+			t.Fatalf("decrypt failed, size:%v, err:%v", size, err)
+		}
+
+		//truncated stream should fail
+		if size > 1024 {
+			truncated := encData[:len(encData) - 100]
+			if err := stream.Decrypt(ioutil.Discard, bytes.NewReader(truncated)); err == nil {
+				t.Fatal("truncated stream should fail")
+			}
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	file, _ := ioutil.TempFile("", "checksum")
+	file.WriteString("hello")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	//checksum
+	checksum := crypt.NewChecksum()
+	sum, _ := checksum.SumFile(file.Name())
+	if sum != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("sha256 invalid, sum:%v", sum)
+	}
+	if _, err := checksum.SumFile(file.Name(), crypt.ChecksumAlgOfBlake2b); err != nil {
+		t.Fatalf("blake2b failed, err:%v", err)
+	}
+
+	//detached signature
+	rsa := crypt.NewRsa()
+	prvKey, pubKey, _ := rsa.GenRsaKey()
+	rsa.SetKey(string(pubKey), string(prvKey))
+	sig, err := checksum.SignFile(file.Name(), rsa)
+	if err != nil {
+		t.Fatalf("sign file failed, err:%v", err)
+	}
+	if err = checksum.VerifyFileSignature(file.Name(), sig, rsa); err != nil {
+		t.Fatalf("verify signature failed, err:%v", err)
+	}
+	ioutil.WriteFile(file.Name(), []byte("changed"), 0644)
+	if err = checksum.VerifyFileSignature(file.Name(), sig, rsa); err == nil {
+		t.Fatal("changed file should fail")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/andyzhou/tinycells/web"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCsrf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	csrf := web.NewCsrf(newTestCookie())
+	engine := gin.New()
+	engine.Use(web.NewSecurityHeaders().Middleware(), csrf.Middleware())
+	engine.GET("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, web.GetCsrfToken(c))
+	})
+	engine.POST("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	engine.GET("/embed", web.OverrideHeaders(map[string]string{web.HeaderOfFrameOptions: ""}),
+		func(c *gin.Context) {
+			c.String(http.StatusOK, "embed")
+		})
+
+	//get token
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	token := w.Body.String()
+	cookies := w.Result().Cookies()
+	if token == "" || len(cookies) <= 0 || w.Header().Get(web.HeaderOfFrameOptions) != "DENY" {
+		t.Fatalf("get token failed, header:%v", w.Header())
+	}
+
+	//post without and with token
+	for _, submit := range []string{"", token} {
+		w = httptest.NewRecorder()
+		form := url.Values{web.CsrfFormField: []string{submit}}
+		req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookies[0])
+		engine.ServeHTTP(w, req)
+		if (submit == "") != (w.Code == http.StatusForbidden) {
+			t.Fatalf("csrf check failed, submit:%v, code:%v", submit, w.Code)
+		}
+	}
+
+	//route override
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed", nil))
+	if w.Header().Get(web.HeaderOfFrameOptions) != "" {
+		t.Fatal("frame options should be removed")
+	}
+	if !strings.Contains(string(csrf.Field(token)), token) {
+		t.Fatal("csrf field invalid")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"github.com/andyzhou/tinycells/featureflag"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFeatureFlag(t *testing.T) {
+	ff := featureflag.NewFeatureFlag()
+	err := ff.LoadFromMap(map[string]interface{}{
+		"flags": map[string]interface{}{
+			"newUI": true,
+			"beta": map[string]interface{}{
+				"enabled": true,
+				"allowUsers": []interface{}{"1", 2.0},
+			},
+			"half": map[string]interface{}{
+				"enabled": true,
+				"percentage": 50.0,
+			},
+			"over": map[string]interface{}{
+				"enabled": true,
+				"percentage": "150",
+			},
+			"expired": map[string]interface{}{
+				"enabled": true,
+				"endTime": "2000-01-01 00:00:00",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("load flags failed, err:%v", err)
+	}
+	if !ff.IsEnabled("newUI", nil) || ff.IsEnabled("expired", nil) || ff.IsEnabled("none", nil) {
+		t.Fatal("unexpected flag result")
+	}
+	if !ff.IsEnabled("beta", &featureflag.Context{UserId: "2"}) ||
+		ff.IsEnabled("beta", &featureflag.Context{UserId: "3"}) {
+		t.Fatal("unexpected allow list result")
+	}
+	ctx := &featureflag.Context{UserId: "100"}
+	if ff.IsEnabled("half", ctx) != ff.IsEnabled("half", ctx) {
+		t.Fatal("percentage rollout should be stable")
+	}
+
+	//anonymous without session not in partial rollout, with session stable
+	if ff.IsEnabled("half", nil) || ff.IsEnabled("half", &featureflag.Context{}) {
+		t.Fatal("anonymous without session should be disabled")
+	}
+	sessCtx := &featureflag.Context{SessionId: "s1"}
+	for i := 0; i < 10; i++ {
+		if ff.IsEnabled("half", sessCtx) != ff.IsEnabled("half", sessCtx) {
+			t.Fatal("percentage rollout of session should be stable")
+		}
+	}
+
+	//percentage clamped
+	if flag := ff.GetFlag("over"); flag.Percentage != featureflag.PercentageMax || !ff.IsEnabled("over", nil) {
+		t.Fatalf("percentage should be clamped, flag:%+v", flag)
+	}
+}
+
+func TestFeatureFlagTimeWindow(t *testing.T) {
+	ff := featureflag.NewFeatureFlag()
+	now := time.Now().Unix()
+	for name, window := range map[string][2]int64{
+		"inWindow": {now - 3600, now + 3600},
+		"notStarted": {now + 3600, 0},
+		"ended": {0, now},
+		"startOnly": {now - 3600, 0},
+	} {
+		flag := featureflag.NewFlag(name)
+		flag.Enabled = true
+		flag.StartTime, flag.EndTime = window[0], window[1]
+		ff.SetFlag(flag)
+	}
+	expects := map[string]bool{"inWindow": true, "notStarted": false, "ended": false, "startOnly": true}
+	for name, expect := range expects {
+		if ff.IsEnabled(name, nil) != expect {
+			t.Fatalf("time window result invalid, flag:%v, expect:%v", name, expect)
+		}
+	}
+}
+
+func TestFeatureFlagMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ff := featureflag.NewFeatureFlag()
+	beta := featureflag.NewFlag("beta")
+	beta.Enabled = true
+	beta.AllowUsers = map[string]bool{"1": true}
+	ff.SetFlag(beta)
+
+	//user from header
+	engine := gin.New()
+	engine.Use(ff.Middleware(func(c *gin.Context) *featureflag.Context {
+		return &featureflag.Context{UserId: c.GetHeader("X-User")}
+	}))
+	engine.GET("/", func(c *gin.Context) {
+		if featureflag.IsEnabledInGin(c, "beta") {
+			c.String(http.StatusOK, "beta")
+			return
+		}
+		c.String(http.StatusOK, "stable")
+	})
+	for user, expect := range map[string]string{"1": "beta", "2": "stable", "": "stable"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", user)
+		engine.ServeHTTP(w, req)
+		if w.Body.String() != expect {
+			t.Fatalf("middleware result invalid, user:%v, body:%v", user, w.Body.String())
+		}
+	}
+
+	//not evaluated without middleware
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if featureflag.IsEnabledInGin(c, "beta") || featureflag.GetFlagsInGin(c) != nil {
+		t.Fatal("flags should be empty without middleware")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/andyzhou/tinycells/config"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfigHistory(t *testing.T) {
+	history := config.NewHistory()
+	_, err := history.Record("app.json", map[string]interface{}{
+		"port": 8080.0,
+		"db": map[string]interface{}{"host": "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("record failed, err:%v", err)
+	}
+	diff, err := history.Record("app.json", map[string]interface{}{
+		"port": 8090.0,
+		"db": map[string]interface{}{"host": "127.0.0.1", "user": "root"},
+	})
+	if err != nil || diff == nil {
+		t.Fatalf("record failed, diff:%v, err:%v", diff, err)
+	}
+	if len(diff.Items) != 2 || diff.Items[0].Key != "db.user" || diff.Items[1].Kind != config.DiffKindOfChanged {
+		t.Fatalf("unexpected diff items:%v", diff.Items)
+	}
+	diff, _ = history.Record("app.json", map[string]interface{}{
+		"port": 8090.0,
+		"db": map[string]interface{}{"host": "127.0.0.1", "user": "root"},
+	})
+	if diff != nil {
+		t.Fatalf("unchanged config should not record, diff:%v", diff)
+	}
+	t.Logf("versions:%v", len(history.GetSnapshots("app.json")))
+}
+
+func TestConfigHistoryMask(t *testing.T) {
+	history := config.NewHistory()
+	history.AddSensitiveKeys("dsn")
+	history.Record("app.json", map[string]interface{}{
+		"db": map[string]interface{}{"password": "123", "dsn": "root:123@tcp"},
+	})
+	history.Record("app.json", map[string]interface{}{
+		"db": map[string]interface{}{"password": "456", "dsn": "root:456@tcp", "host": "h"},
+	})
+
+	//web entry should mask sensitive value
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?tag=app.json&version=2", nil)
+	history.Entry(c)
+	body := w.Body.String()
+	if strings.Contains(body, "456") || !strings.Contains(body, config.HistoryMaskedValue) ||
+		!strings.Contains(body, `"h"`) {
+		t.Fatalf("snapshot not masked, body:%v", body)
+	}
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?tag=app.json", nil)
+	history.Entry(c)
+	if body = w.Body.String(); strings.Contains(body, "123") || strings.Contains(body, "456") {
+		t.Fatalf("diffs not masked, body:%v", body)
+	}
+
+	//raw snapshot kept for compare
+	if history.GetLatest("app.json").Data["db.password"] != "456" {
+		t.Fatal("raw snapshot should be kept")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/andyzhou/tinycells/crypt"
+	"testing"
+	"time"
+)
+
+type userClaims struct {
+	crypt.JwtClaims
+	Uid int64 `json:"uid"`
+}
+
+func TestJwt(t *testing.T) {
+	j := crypt.NewJwt("secret")
+	j.SetValidation(&crypt.JwtValidation{Issuer: "tc", Audience: "api", Leeway: time.Second})
+
+	//legacy map claims
+	token, err := j.Encode(map[string]interface{}{"uid": 1})
+	if err != nil {
+		t.Fatalf("encode failed, err:%v", err)
+	}
+	if _, err = j.Decode(token); err != crypt.ErrJwtInvalidIssuer {
+		t.Fatalf("issuer should be checked, err:%v", err)
+	}
+
+	//rotate to ed25519 key
+	_, prvKey, _ := ed25519.GenerateKey(rand.Reader)
+	j.AddKey(crypt.NewJwtEd25519Key("k2", prvKey))
+	j.SetActiveKey("k2")
+	claims := &userClaims{JwtClaims: *j.GenClaims("u1", time.Minute), Uid: 100}
+	token, err = j.EncodeClaims(claims)
+	if err != nil {
+		t.Fatalf("encode claims failed, err:%v", err)
+	}
+	decoded := &userClaims{}
+	if err = j.DecodeClaims(token, decoded); err != nil || decoded.Uid != 100 || decoded.Subject != "u1" {
+		t.Fatalf("decode claims failed, claims:%v, err:%v", decoded, err)
+	}
+
+	//expired token
+	claims.ExpiresAt = time.Now().Unix() - 10
+	token, _ = j.EncodeClaims(claims)
+	if err = j.DecodeClaims(token, &userClaims{}); err != crypt.ErrJwtExpired {
+		t.Fatalf("token should be expired, err:%v", err)
+	}
+
+	jwks, _ := j.GenJwks().Encode()
+	t.Logf("jwks:%v", string(jwks))
+}
+
+func TestJwtForge(t *testing.T) {
+	//no key installed by default
+	j := crypt.NewJwt()
+	if _, err := j.Encode(map[string]interface{}{"uid": 1}); err != crypt.ErrJwtUnknownKey {
+		t.Fatalf("encode without key should fail, err:%v", err)
+	}
+
+	//rotate to ed25519 key, kid-less hs256 token signed by old built-in secret rejected
+	_, prvKey, _ := ed25519.GenerateKey(rand.Reader)
+	j.AddKey(crypt.NewJwtEd25519Key("k1", prvKey))
+	j.SetActiveKey("k1")
+	forged, _ := crypt.NewJwt("Q1%x&9/jS1j%3omv!nVmA#lM").Encode(map[string]interface{}{"uid": 1})
+	if _, err := j.Decode(forged); err != crypt.ErrJwtUnknownKey {
+		t.Fatalf("forged token should be rejected, err:%v", err)
+	}
+
+	//non-integer exp should fail
+	hs := crypt.NewJwt("secret")
+	token, _ := hs.Encode(map[string]interface{}{"uid": 1, "exp": "never"})
+	if _, err := hs.Decode(token); err != crypt.ErrJwtInvalidToken {
+		t.Fatalf("invalid exp should fail, err:%v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"github.com/andyzhou/tinycells/migrate"
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//lock by sqlite lock file, GET_LOCK of mysql not covered
+func TestMigrate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "migrate")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "20220101000000_add_user.up.sql"),
+		[]byte("CREATE TABLE user (id INTEGER, name TEXT); -- user;\nINSERT INTO user VALUES (1, 'a;b');"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "20220101000000_add_user.down.sql"),
+		[]byte("DROP TABLE user;"), 0644)
+
+	db, _ := sql.Open("sqlite3", filepath.Join(dir, "test.db"))
+	defer db.Close()
+	m := migrate.NewMigrator(db, migrate.DialectOfSqlite)
+	if err := m.SetTable("schema; DROP TABLE user"); err == nil {
+		t.Fatal("invalid table name should fail")
+	}
+	m.SetOutput(ioutil.Discard)
+	if err := m.LoadDir(dir); err != nil {
+		t.Fatalf("load dir failed, err:%v", err)
+	}
+	m.Register(20220102000000, "add_score", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "ALTER TABLE user ADD COLUMN score INTEGER")
+		return err
+	}, nil)
+
+	//dry run and status, no write
+	out := bytes.NewBuffer(nil)
+	m.SetOutput(out)
+	m.SetDryRun(true)
+	m.Up(context.Background())
+	if !strings.Contains(out.String(), "'a;b'") {
+		t.Fatalf("dry run output invalid, out:%v", out.String())
+	}
+	m.SetDryRun(false)
+	if status, err := m.Status(context.Background()); err != nil || len(status) != 2 || status[0].Applied {
+		t.Fatalf("status before up invalid, status:%v, err:%v", status, err)
+	}
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables)
+	if tables != 0 {
+		t.Fatalf("dry run and status should not create table, tables:%v", tables)
+	}
+
+	//locked by default lock file of db file
+	lockFile := filepath.Join(dir, "test.db" + migrate.LockFileSuffix)
+	ioutil.WriteFile(lockFile, []byte("1"), 0644)
+	if _, err := m.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("up should be locked, err:%v", err)
+	}
+	os.Remove(lockFile)
+
+	//up
+	versions, err := m.Up(context.Background())
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("up failed, versions:%v, err:%v", versions, err)
+	}
+	var name string
+	db.QueryRow("SELECT name FROM user WHERE score IS NULL").Scan(&name)
+	if name != "a;b" {
+		t.Fatalf("migrated data invalid, name:%v", name)
+	}
+
+	//down go migration without down func, should fail
+	if _, err = m.Down(context.Background()); err == nil {
+		t.Fatal("down without down func should fail")
+	}
+	status, _ := m.Status(context.Background())
+	if len(status) != 2 || !status[0].Applied || !status[1].Applied {
+		t.Fatalf("status invalid, status:%v", status)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	sqlText := "CREATE TABLE user (id INTEGER, num INTEGER);\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"/* comment; */ -- comment;\n" +
+		"-- migrate:block\n" +
+		"CREATE TRIGGER user_num AFTER INSERT ON user\n" +
+		"BEGIN\n" +
+		"  UPDATE user SET num = num + 1 WHERE id = NEW.id;\n" +
+		"END;\n" +
+		"-- migrate:endblock\n" +
+		"INSERT INTO user VALUES (1, 0);"
+	statements := migrate.SplitStatements(sqlText)
+	if len(statements) != 4 || statements[1] != "/*!40101 SET NAMES utf8mb4 */" ||
+		!strings.HasPrefix(statements[2], "CREATE TRIGGER") || !strings.HasSuffix(statements[2], "END") ||
+		statements[3] != "INSERT INTO user VALUES (1, 0)" {
+		t.Fatalf("split statements invalid, statements:%q", statements)
+	}
+
+	//run on sqlite
+	db := newSqliteTestDB(t)
+	defer db.Close()
+	for i, stmt := range statements {
+		if i == 1 {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("exec statement failed, stmt:%v, err:%v", stmt, err)
+		}
+	}
+	var num int
+	db.QueryRow("SELECT num FROM user WHERE id = 1").Scan(&num)
+	if num != 1 {
+		t.Fatalf("trigger not work, num:%v", num)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/andyzhou/tinycells/db/mysql"
+	"github.com/andyzhou/tinycells/logger"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+//slow query on sqlite, cost about hundreds of milliseconds
+const slowTestSql = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < ?) SELECT COUNT(*) AS total FROM c"
+
+func TestCircuitBreaker(t *testing.T) {
+	//no server on port 1, connection refused
+	conn := mysql.NewConnect(&mysql.Config{
+		Host: "127.0.0.1",
+		Port: 1,
+		User: "root",
+		DBName: "test",
+		MaxOpenConns: 2,
+		ConnectTimeout: 200,
+		BreakerThreshold: 1,
+		BreakerCooldown: 60,
+		StmtCacheSize: 8,
+	})
+	defer conn.Quit()
+	if err := conn.Ping(); err == nil || !mysql.IsConnError(err) {
+		t.Fatalf("ping should fail with conn error, err:%v", err)
+	}
+
+	//breaker open, fail fast
+	if _, _, err := conn.Execute("SELECT 1"); err != mysql.ErrCircuitOpen {
+		t.Fatalf("execute should fail fast, err:%v", err)
+	}
+	stats := conn.GetStats()
+	if stats.BreakerState != mysql.BreakerStateOpen || stats.MaxOpenConnections != 2 ||
+		stats.StmtCache == nil || stats.StmtCache.Capacity != 8 {
+		t.Fatalf("stats invalid, stats:%+v", stats)
+	}
+}
+
+func TestIsConnError(t *testing.T) {
+	//context error is not conn error, even though deadline exceeded is net.Error
+	for _, err := range []error{context.DeadlineExceeded, context.Canceled,
+		fmt.Errorf("query: %w", context.DeadlineExceeded)} {
+		if mysql.IsConnError(err) {
+			t.Fatalf("context error should not be conn error, err:%v", err)
+		}
+	}
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	for _, err := range []error{driver.ErrBadConn, netErr, fmt.Errorf("query: %w", netErr)} {
+		if !mysql.IsConnError(err) {
+			t.Fatalf("should be conn error, err:%v", err)
+		}
+	}
+
+	//query timeout not open breaker
+	db := newSqliteTestDB(t)
+	conn := mysql.NewConnectWithDB(db, &mysql.Config{QueryTimeout: 10, BreakerThreshold: 1, BreakerCooldown: 60})
+	defer conn.Quit()
+	if _, err := conn.GetRows(slowTestSql, 1000000); err == nil {
+		t.Fatal("query should fail by timeout")
+	}
+	if state := conn.GetStats().BreakerState; state != mysql.BreakerStateClosed {
+		t.Fatalf("breaker should keep closed, state:%v", state)
+	}
+}
+
+func TestCircuitBreakerContextError(t *testing.T) {
+	conn := mysql.NewConnect(&mysql.Config{
+		Host: "127.0.0.1",
+		Port: 1,
+		User: "root",
+		DBName: "test",
+		ConnectTimeout: 200,
+		BreakerThreshold: 1,
+		BreakerCooldown: 1,
+	})
+	defer conn.Quit()
+	conn.Ping()
+	if state := conn.GetStats().BreakerState; state != mysql.BreakerStateOpen {
+		t.Fatalf("breaker should be open, state:%v", state)
+	}
+
+	//probe ended by context error, breaker back to open
+	time.Sleep(1100 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.GetRowsContext(ctx, "SELECT 1"); err != context.Canceled {
+		t.Fatalf("probe should be canceled, err:%v", err)
+	}
+	if state := conn.GetStats().BreakerState; state != mysql.BreakerStateOpen {
+		t.Fatalf("breaker should be open again, state:%v", state)
+	}
+	if _, _, err := conn.Execute("SELECT 1"); err != mysql.ErrCircuitOpen {
+		t.Fatalf("execute should fail fast, err:%v", err)
+	}
+}
+
+func TestInvalidLoc(t *testing.T) {
+	conn := mysql.NewConnect(&mysql.Config{Host: "127.0.0.1", Port: 1, Loc: "Invalid/Zone"})
+	defer conn.Quit()
+	if conn.GetDB() != nil || conn.Ping() == nil {
+		t.Fatal("connect with invalid loc should fail")
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db := newSqliteTestDB(t)
+	conn := mysql.NewConnectWithDB(db, &mysql.Config{QueryTimeout: 20})
+	defer conn.Quit()
+
+	//ctx without deadline, default timeout used
+	if _, err := conn.GetRows(slowTestSql, 1000000); err == nil {
+		t.Fatal("query should fail by default timeout")
+	}
+
+	//ctx with deadline left alone
+	ctx, cancel := context.WithTimeout(context.Background(), 30 * time.Second)
+	defer cancel()
+	records, err := conn.GetRowsContext(ctx, slowTestSql, 1000000)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("query with ctx deadline failed, err:%v", err)
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	db := newSqliteTestDB(t)
+	conn := mysql.NewConnectWithDB(db, &mysql.Config{DBName: "test", SlowThreshold: 1})
+	defer conn.Quit()
+
+	//args not logged
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	query := strings.Replace(slowTestSql, "AS total", "AS total, ? AS name", 1)
+	if _, err := conn.GetRows(query, 100000, "secret-arg"); err != nil {
+		t.Fatalf("query failed, err:%v", err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, "mysql slow query") || !strings.Contains(output, "args:2") {
+		t.Fatalf("slow query log invalid, log:%v", output)
+	}
+	if strings.Contains(output, "secret-arg") {
+		t.Fatalf("slow query args should not be logged, log:%v", output)
+	}
+
+	//logger set later seen by primary view, not std log
+	view := conn.UsePrimary()
+	conn.SetLogger(logger.NewLogger())
+	buf.Reset()
+	if _, err := view.GetRows(slowTestSql, 100000); err != nil {
+		t.Fatalf("query failed, err:%v", err)
+	}
+	if strings.Contains(buf.String(), "mysql slow query") {
+		t.Fatalf("slow query should be logged by logger, log:%v", buf.String())
+	}
+}
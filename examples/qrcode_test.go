@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/andyzhou/tinycells/media"
+	"strings"
+	"testing"
+)
+
+//qr code module rows, `#` is dark
+func qrCodeRows(qr *media.QrCode) []string {
+	size := qr.GetSize()
+	rows := make([]string, 0, size)
+	for y := 0; y < size; y++ {
+		row := make([]byte, size)
+		for x := 0; x < size; x++ {
+			row[x] = '.'
+			if qr.IsDark(x, y) {
+				row[x] = '#'
+			}
+		}
+		rows = append(rows, string(row))
+	}
+	return rows
+}
+
+//known answers generated by reference encoder, byte mode and ecc level M
+func TestQrCode(t *testing.T) {
+	//version 1, full matrix
+	expect := []string{
+		"#######..##...#######",
+		"#.....#.##....#.....#",
+		"#.###.#..#.##.#.###.#",
+		"#.###.#...##..#.###.#",
+		"#.###.#.##..#.#.###.#",
+		"#.....#.....#.#.....#",
+		"#######.#.#.#.#######",
+		"..........###........",
+		"#.#.#.#..#.#....#..#.",
+		"..#.##....#...#....##",
+		".#.#..#.###.#...#####",
+		"##..#.........#....#.",
+		".##.#.##..#.#.#.#....",
+		"........####.#.#..###",
+		"#######...##.###..###",
+		"#.....#...####.##....",
+		"#.###.#.#.##.###...##",
+		"#.###.#..#....##..##.",
+		"#.###.#.###.#...#.#.#",
+		"#.....#..#....#.#..#.",
+		"#######.###.#.##...##",
+	}
+	qr, err := media.NewQrCode([]byte("hello"))
+	if err != nil {
+		t.Fatalf("new qr code failed, err:%v", err)
+	}
+	rows := qrCodeRows(qr)
+	if len(rows) != len(expect) {
+		t.Fatalf("qr code size invalid, size:%v", len(rows))
+	}
+	for i, row := range rows {
+		if row != expect[i] {
+			t.Fatalf("qr code row %v invalid, row:%v, expect:%v", i, row, expect[i])
+		}
+	}
+
+	//larger versions, sha256 of matrix rows
+	cases := []struct {
+		data string
+		size int
+		hash string
+	}{
+		{"https://github.com/andyzhou/tinycells", 29, "b20a50c040c1465fc9ed4344d33ec6475feba2678018dafe25fb8b9d528c5a36"},
+		{"otpauth://totp/Tiny%20Cells:admin@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Tiny%20Cells&algorithm=SHA1&digits=6&period=30", 49, "c976b5a2d0a828dbfbf67069b259094665f4d608878653941b0900dfdf95d5cb"},
+	}
+	for _, c := range cases {
+		qr, err = media.NewQrCode([]byte(c.data))
+		if err != nil || qr.GetSize() != c.size {
+			t.Fatalf("new qr code failed, data:%v, err:%v", c.data, err)
+		}
+		sum := sha256.Sum256([]byte(strings.Join(qrCodeRows(qr), "\n")))
+		if hex.EncodeToString(sum[:]) != c.hash {
+			t.Fatalf("qr code matrix invalid, data:%v", c.data)
+		}
+	}
+
+	//png render
+	if png, err := media.GenQrCodePng([]byte("hello")); err != nil || len(png) <= 0 {
+		t.Fatalf("gen qr code png failed, err:%v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/andyzhou/tinycells/db/mysql"
+	"testing"
+)
+
+//db with node name, replica lag of mysql `SHOW SLAVE STATUS` not covered
+func newReplicaTestDB(t *testing.T, node string) *sql.DB {
+	return newSqliteTestDB(t, "CREATE TABLE node (name TEXT)", fmt.Sprintf("INSERT INTO node VALUES ('%s')", node))
+}
+
+//get node name of read
+func getReplicaTestNode(t *testing.T, conn *mysql.Connect) string {
+	record, err := conn.GetRow("SELECT name FROM node")
+	if err != nil {
+		t.Fatalf("get row failed, err:%v", err)
+	}
+	return record["name"].(string)
+}
+
+func TestReplicaRouting(t *testing.T) {
+	conf := &mysql.Config{DBName: "test", QueryTimeout: 1000}
+	conn := mysql.NewConnectWithDB(newReplicaTestDB(t, "primary"), conf)
+	defer conn.Quit()
+
+	//no replica, read on primary
+	if node := getReplicaTestNode(t, conn); node != "primary" {
+		t.Fatalf("read without replica invalid, node:%v", node)
+	}
+
+	//read on healthy replica, write on primary
+	conn.AddReplicaDB(newReplicaTestDB(t, "replica"))
+	if node := getReplicaTestNode(t, conn); node != "replica" {
+		t.Fatalf("read should route to replica, node:%v", node)
+	}
+	conn.Execute("UPDATE node SET name = ?", "primary-new")
+	if node := getReplicaTestNode(t, conn.UsePrimary()); node != "primary-new" {
+		t.Fatalf("write should be on primary, node:%v", node)
+	}
+	if node := getReplicaTestNode(t, conn); node != "replica" {
+		t.Fatalf("read should route to replica, node:%v", node)
+	}
+	status := conn.GetReplicaStatus()
+	if len(status) != 1 || !status[0].Healthy || status[0].Lag != 0 {
+		t.Fatalf("replica status invalid, status:%+v", status[0])
+	}
+
+	//config of caller kept
+	if conf.Replicas != nil || conf.Port != 0 {
+		t.Fatalf("config of caller changed, conf:%+v", conf)
+	}
+}
+
+func TestReplicaFallback(t *testing.T) {
+	//unhealthy replica skipped
+	conn := mysql.NewConnectWithDB(newReplicaTestDB(t, "primary"))
+	defer conn.Quit()
+	broken := newReplicaTestDB(t, "broken")
+	broken.Close()
+	conn.AddReplicaDB(broken)
+	if node := getReplicaTestNode(t, conn); node != "primary" {
+		t.Fatalf("unhealthy replica should be skipped, node:%v", node)
+	}
+	if status := conn.GetReplicaStatus(); len(status) != 1 || status[0].Healthy {
+		t.Fatalf("replica status invalid, status:%+v", status[0])
+	}
+
+	//replica with unknown lag skipped
+	lagConn := mysql.NewConnectWithDB(newReplicaTestDB(t, "primary"), &mysql.Config{MaxReplicaLag: 5})
+	defer lagConn.Quit()
+	lagConn.AddReplicaDB(newReplicaTestDB(t, "replica"))
+	if node := getReplicaTestNode(t, lagConn); node != "primary" {
+		t.Fatalf("replica with unknown lag should be skipped, node:%v", node)
+	}
+	if status := lagConn.GetReplicaStatus(); len(status) != 1 || !status[0].Healthy || status[0].Lag != -1 {
+		t.Fatalf("replica status invalid, status:%+v", status[0])
+	}
+}
+
+func TestReplicaConfig(t *testing.T) {
+	//replica config filled by copy, caller config kept
+	replicaConf := &mysql.Config{Host: "127.0.0.1", Params: map[string]string{"a": "1"}}
+	conf := &mysql.Config{
+		Host: "127.0.0.1",
+		Port: 1,
+		User: "root",
+		DBName: "test",
+		ConnectTimeout: 100,
+		Replicas: []*mysql.Config{replicaConf},
+	}
+	conn := mysql.NewConnect(conf)
+	defer conn.Quit()
+	if replicaConf.Port != 0 || replicaConf.User != "" || replicaConf.DBName != "" || len(replicaConf.Params) != 1 {
+		t.Fatalf("replica config of caller changed, conf:%+v", replicaConf)
+	}
+	if status := conn.GetReplicaStatus(); len(status) != 1 || status[0].Address != "127.0.0.1:1" {
+		t.Fatalf("replica config not filled, status:%+v", status)
+	}
+}
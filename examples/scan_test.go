@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/andyzhou/tinycells/db/mysql"
+	"testing"
+	"time"
+)
+
+type scanBase struct {
+	Id int64 `db:"id"`
+	CreateAt time.Time `db:"create_at"`
+}
+
+type scanProfile struct {
+	City string `json:"city"`
+}
+
+type scanUser struct {
+	scanBase
+	UserName string
+	Balance string `db:"balance"`
+	Score *float64 `db:"score"`
+	Profile scanProfile `db:"profile"`
+	Ignored string `db:"-"`
+}
+
+type scanInner struct {
+	Id int64 `db:"id"`
+}
+
+type ScanExtra struct {
+	Memo string `db:"memo"`
+}
+
+type scanEmbedPtr struct {
+	*scanInner
+	*ScanExtra
+	Name string `db:"name"`
+}
+
+func TestScanRows(t *testing.T) {
+	db := newSqliteTestDB(t,
+		"CREATE TABLE user (id INTEGER, user_name TEXT, balance TEXT, " +
+			"score REAL, profile TEXT, create_at DATETIME, extra TEXT)",
+		"INSERT INTO user VALUES (1, 'tc', '12.50', 9.5, '{\"city\":\"sh\"}', '2022-01-02 03:04:05', 'x')",
+		"INSERT INTO user VALUES (2, 'cells', '0', NULL, '{}', '2022-01-02 03:04:05', 'y')")
+	defer db.Close()
+
+	//select into slice
+	users := make([]*scanUser, 0)
+	rows, _ := db.Query("SELECT * FROM user ORDER BY id")
+	if err := mysql.ScanRows(rows, &users); err != nil {
+		t.Fatalf("scan rows failed, err:%v", err)
+	}
+	if len(users) != 2 || users[0].UserName != "tc" || users[0].Balance != "12.50" ||
+		users[0].Profile.City != "sh" || *users[0].Score != 9.5 || users[1].Score != nil ||
+		users[0].CreateAt.Year() != 2022 {
+		t.Fatalf("scan rows result invalid, user:%+v", users[0])
+	}
+
+	//get one row
+	user := &scanUser{}
+	rows, _ = db.Query("SELECT id, user_name FROM user WHERE id = ?", 3)
+	if err := mysql.ScanRow(rows, user); err != sql.ErrNoRows {
+		t.Fatalf("scan row should be no rows, err:%v", err)
+	}
+
+	//scan error surfaced
+	rows, _ = db.Query("SELECT user_name AS id FROM user")
+	if err := mysql.ScanRows(rows, &users); err == nil {
+		t.Fatal("scan invalid int should fail")
+	}
+
+	//time text parsed in loc of connect
+	locConn := mysql.NewConnectWithDB(db, &mysql.Config{Loc: "Asia/Shanghai"})
+	timeUser := &scanUser{}
+	if err := locConn.Get(context.Background(), timeUser, "SELECT '2022-01-02 03:04:05' AS create_at"); err != nil {
+		t.Fatalf("get time failed, err:%v", err)
+	}
+	if timeUser.CreateAt.Location().String() != "Asia/Shanghai" || timeUser.CreateAt.Hour() != 3 {
+		t.Fatalf("time location invalid, time:%v", timeUser.CreateAt)
+	}
+	rows, _ = db.Query("SELECT '2022-01-02 03:04:05' AS create_at")
+	if err := mysql.ScanRow(rows, timeUser); err != nil || timeUser.CreateAt.Location() != time.UTC {
+		t.Fatalf("default time location should be utc, time:%v, err:%v", timeUser.CreateAt, err)
+	}
+
+	//un-exported embedded pointer skipped, exported one alloc
+	conn := mysql.NewConnectWithDB(db)
+	embed := &scanEmbedPtr{}
+	if err := conn.Get(context.Background(), embed, "SELECT 1 AS id, 'a' AS name, 'm' AS memo"); err != nil {
+		t.Fatalf("get with embedded pointer failed, err:%v", err)
+	}
+	if embed.Name != "a" || embed.scanInner != nil || embed.ScanExtra == nil || embed.Memo != "m" {
+		t.Fatalf("embedded pointer result invalid, row:%+v", embed)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/andyzhou/tinycells/db/sqlite"
+	"github.com/andyzhou/tinycells/session"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+//run login and read requests against store
+func runSessionTest(t *testing.T, store session.IStore) {
+	gin.SetMode(gin.TestMode)
+	manager := session.NewManager(store, newTestCookie())
+	engine := gin.New()
+	engine.Use(manager.Middleware())
+	engine.GET("/login", func(c *gin.Context) {
+		sess := session.GetSession(c)
+		sess.Regenerate()
+		sess.Set("user", "tc")
+		sess.AddFlash("welcome")
+		c.String(http.StatusOK, sess.GetId())
+	})
+	engine.GET("/me", func(c *gin.Context) {
+		sess := session.GetSession(c)
+		flashes := sess.Flashes()
+		c.String(http.StatusOK, "%s:%d", sess.GetString("user"), len(flashes))
+	})
+
+	//login
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) <= 0 {
+		t.Fatal("session cookie not set")
+	}
+	sessCookie := cookies[len(cookies)-1]
+
+	//read twice, flash only once
+	for _, expect := range []string{"tc:1", "tc:0"} {
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.AddCookie(sessCookie)
+		engine.ServeHTTP(w, req)
+		if w.Body.String() != expect {
+			t.Fatalf("session data invalid, expect:%v, got:%v", expect, w.Body.String())
+		}
+	}
+}
+
+func TestSessionMemory(t *testing.T) {
+	store := session.NewMemoryStore()
+	defer store.Quit()
+	runSessionTest(t, store)
+}
+
+func TestSessionSqlite(t *testing.T) {
+	dbFile := "session_test.db"
+	defer os.Remove(dbFile)
+	db := sqlite.NewSqlLite()
+	if err := db.OpenDBFile(dbFile); err != nil {
+		t.Fatalf("open db failed, err:%v", err)
+	}
+	defer db.Close()
+	store, err := session.NewSqliteStore(db)
+	if err != nil {
+		t.Fatalf("create store failed, err:%v", err)
+	}
+	runSessionTest(t, store)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/andyzhou/tinycells/db/mysql"
+	"math"
+	"testing"
+)
+
+func TestShardFunc(t *testing.T) {
+	//hash mod
+	hash := mysql.NewHashShard(64)
+	if idx, _ := hash.Shard(int64(130)); idx != 2 {
+		t.Fatalf("hash shard invalid, idx:%v", idx)
+	}
+	//same route of numeric string and any integer type
+	for _, key := range []interface{}{"130", []byte("130"), 130, int8(-66), int16(130), uint(130), uint16(130), uint64(130)} {
+		if idx, err := hash.Shard(key); err != nil || idx != 2 {
+			t.Fatalf("hash shard of %T invalid, idx:%v, err:%v", key, idx, err)
+		}
+	}
+	if idx, err := hash.Shard(int64(math.MinInt64)); err != nil || idx != 0 {
+		t.Fatalf("hash shard of min int64 invalid, idx:%v, err:%v", idx, err)
+	}
+	idxA, _ := hash.Shard("user-a")
+	idxB, _ := hash.Shard("user-a")
+	if idxA != idxB || idxA < 0 || idxA >= 64 {
+		t.Fatalf("hash string shard invalid, idx:%v", idxA)
+	}
+
+	//range
+	ranges := mysql.NewRangeShard(
+		&mysql.ShardRange{Min: 0, Max: 1000, Index: 0},
+		&mysql.ShardRange{Min: 1000, Max: 2000, Index: 1},
+	)
+	if idx, _ := ranges.Shard("1500"); idx != 1 {
+		t.Fatalf("range shard invalid, idx:%v", idx)
+	}
+	if _, err := ranges.Shard(3000); err != mysql.ErrShardNotFound {
+		t.Fatalf("out of range should fail, err:%v", err)
+	}
+
+	//lookup with fallback
+	lookup := mysql.NewLookupShard(map[string]int{"vip": 3}, func(key interface{}) (int, error) {
+		return 7, nil
+	})
+	if idx, _ := lookup.Shard("vip"); idx != 3 {
+		t.Fatalf("lookup shard invalid, idx:%v", idx)
+	}
+	if idx, _ := lookup.Shard(42); idx != 7 {
+		t.Fatalf("lookup fallback invalid, idx:%v", idx)
+	}
+
+	//shard table without node should fail
+	db := mysql.NewMysql()
+	_, err := db.CreateShardTable(&mysql.ShardConfig{Table: "user", Shards: 2})
+	if err == nil {
+		t.Fatal("shard table without node should fail")
+	}
+
+	//node range out of shards, overlap or gap should fail
+	badNodes := [][]*mysql.ShardNode{
+		{{Tag: "a", From: 0, To: 2}},
+		{{Tag: "a", From: -1, To: 1}},
+		{{Tag: "a", From: 1, To: 0}, {Tag: "b", From: 0, To: 1}},
+		{{Tag: "a", From: 0, To: 1}, {Tag: "b", From: 1, To: 1}},
+		{{Tag: "a", From: 0, To: 0}},
+	}
+	db.AddConnect("a", mysql.NewConnectWithDB(newShardTestDB(t)))
+	db.AddConnect("b", mysql.NewConnectWithDB(newShardTestDB(t)))
+	defer db.RemoveConnect("a")
+	defer db.RemoveConnect("b")
+	for _, nodes := range badNodes {
+		if _, err = db.CreateShardTable(&mysql.ShardConfig{Table: "user", Shards: 2, Nodes: nodes}); err == nil {
+			t.Fatalf("invalid nodes should fail, nodes:%v", nodes)
+		}
+	}
+
+	//node connect failed, no connect left
+	_, err = db.CreateShardTable(&mysql.ShardConfig{Table: "user", Shards: 2, Nodes: []*mysql.ShardNode{
+		{Tag: "a", From: 0, To: 0},
+		{Tag: "c", From: 1, To: 1, Conf: &mysql.Config{Host: "127.0.0.1", Port: 1, ConnectTimeout: 100}},
+	}})
+	if err == nil || db.GetConnect("c") != nil || db.GetShardTable("user") != nil {
+		t.Fatalf("shard table with failed node should fail, err:%v", err)
+	}
+}
+
+//sqlite memory db with user_0..user_3 tables
+func newShardTestDB(t *testing.T) *sql.DB {
+	statements := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		statements = append(statements, fmt.Sprintf("CREATE TABLE user_%d (id INTEGER PRIMARY KEY, score INTEGER)", i))
+	}
+	return newSqliteTestDB(t, statements...)
+}
+
+//nodes on sqlite, node connect created by mysql config not covered
+func TestShardScatter(t *testing.T) {
+	db := mysql.NewMysql()
+	db.AddConnect("node0", mysql.NewConnectWithDB(newShardTestDB(t)))
+	db.AddConnect("node1", mysql.NewConnectWithDB(newShardTestDB(t)))
+	defer db.RemoveConnect("node0")
+	defer db.RemoveConnect("node1")
+	st, err := db.CreateShardTable(&mysql.ShardConfig{
+		Table: "user",
+		Shards: 4,
+		Nodes: []*mysql.ShardNode{
+			{Tag: "node0", From: 0, To: 1},
+			{Tag: "node1", From: 2, To: 3},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create shard table failed, err:%v", err)
+	}
+
+	//id 1..20 route by hash, score is id * 10
+	ctx := context.Background()
+	for id := 1; id <= 20; id++ {
+		_, _, err = st.Execute(ctx, id, "INSERT INTO {table} (id, score) VALUES (?, ?)", id, id * 10)
+		if err != nil {
+			t.Fatalf("insert failed, id:%v, err:%v", id, err)
+		}
+	}
+	record, _ := st.GetRow(ctx, 7, "SELECT score FROM {table} WHERE id = ?", 7)
+	if fmt.Sprint(record["score"]) != "70" {
+		t.Fatalf("get row by shard invalid, record:%v", record)
+	}
+
+	//merge all, sort desc, skip 3 and take 5
+	records, err := st.Scatter(ctx, &mysql.GatherOption{OrderBy: "score", Desc: true, Offset: 3, Limit: 5},
+		"SELECT id, score FROM {table} ORDER BY score DESC LIMIT 8")
+	if err != nil || len(records) != 5 {
+		t.Fatalf("scatter failed, records:%v, err:%v", records, err)
+	}
+	for i, record := range records {
+		if expect := fmt.Sprint((17 - i) * 10); fmt.Sprint(record["score"]) != expect {
+			t.Fatalf("scatter order invalid, idx:%v, record:%v, expect:%v", i, record, expect)
+		}
+	}
+
+	//sort asc, offset out of range
+	records, _ = st.Scatter(ctx, &mysql.GatherOption{OrderBy: "score"}, "SELECT score FROM {table}")
+	if len(records) != 20 || fmt.Sprint(records[0]["score"]) != "10" || fmt.Sprint(records[19]["score"]) != "200" {
+		t.Fatalf("scatter asc invalid, records:%v", records)
+	}
+	if records, _ = st.Scatter(ctx, &mysql.GatherOption{Offset: 20}, "SELECT score FROM {table}"); len(records) != 0 {
+		t.Fatalf("offset out of range should be empty, records:%v", records)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"github.com/andyzhou/tinycells/crypt"
+	"github.com/andyzhou/tinycells/web"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHmacSigner(t *testing.T) {
+	secret := []byte("secret")
+	signer := crypt.NewHmacSigner()
+	signer.AddKey("k1", secret)
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api?b=2&a=1", bytes.NewBufferString("hello"))
+		if err := signer.SignRequest(req); err != nil {
+			t.Fatalf("sign failed, err:%v", err)
+		}
+		return req
+	}
+
+	//round trip, body restored
+	req := newRequest()
+	info, err := signer.VerifyRequest(req)
+	if err != nil || info.KeyId != "k1" {
+		t.Fatalf("verify failed, err:%v", err)
+	}
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != "hello" {
+		t.Fatalf("body not restored, body:%v", string(body))
+	}
+
+	//tampered query and body
+	req = newRequest()
+	req.URL.RawQuery = "a=1&b=3"
+	if _, err = signer.VerifyRequest(req); err != crypt.ErrSignInvalid {
+		t.Fatalf("tampered query should fail, err:%v", err)
+	}
+	req = newRequest()
+	req.Body = ioutil.NopCloser(bytes.NewBufferString("hellO"))
+	if _, err = signer.VerifyRequest(req); err != crypt.ErrSignInvalid {
+		t.Fatalf("tampered body should fail, err:%v", err)
+	}
+
+	//stale timestamp, re-signed with old time
+	req = newRequest()
+	stale := time.Now().Add(-10 * time.Minute).Unix()
+	nonce := req.Header.Get(crypt.SignHeaderOfNonce)
+	req.Header.Set(crypt.SignHeaderOfTimestamp, strconv.FormatInt(stale, 10))
+	req.Header.Set(crypt.SignHeaderOfSignature, signer.Sign(secret,
+		signer.Canonical(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, []byte("hello"), stale, nonce)))
+	if _, err = signer.VerifyRequest(req); err != crypt.ErrSignExpired {
+		t.Fatalf("stale timestamp should fail, err:%v", err)
+	}
+
+	//body too large
+	signer.SetMaxBodySize(4)
+	if _, err = signer.VerifyRequest(newRequest()); err != crypt.ErrSignBodyTooLarge {
+		t.Fatalf("large body should fail, err:%v", err)
+	}
+}
+
+func TestSignatureAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signer := crypt.NewHmacSigner()
+	signer.AddKey("k1", []byte("secret"))
+	//no redis, memory nonce store by default
+	auth := web.NewSignatureAuth(signer, nil)
+	auth.SetNonceStore(nil)
+	router := gin.New()
+	router.POST("/api", auth.Middleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, web.GetSignInfo(c).KeyId)
+	})
+
+	//first request pass, replayed request rejected
+	req := httptest.NewRequest(http.MethodPost, "/api", bytes.NewBufferString("hello"))
+	signer.SignRequest(req)
+	header := req.Header.Clone()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "k1" {
+		t.Fatalf("signed request should pass, code:%v", w.Code)
+	}
+	replay := httptest.NewRequest(http.MethodPost, "/api", bytes.NewBufferString("hello"))
+	replay.Header = header
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, replay)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed request should fail, code:%v", w.Code)
+	}
+}
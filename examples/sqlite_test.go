@@ -0,0 +1,36 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/andyzhou/tinycells/db/mysql"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+)
+
+/*
+ * shared sqlite fixture for db tests
+ * - memory db with single conn, so data kept across queries
+ * - only driver independent logic covered, mysql only sql like
+ *   GET_LOCK, ON DUPLICATE KEY UPDATE, FOR UPDATE and real 1213 deadlock
+ *   of mysql driver need a mysql server, not covered here
+ */
+
+//open sqlite memory db and run statements in order
+func newSqliteTestDB(t *testing.T, statements ...string) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db failed, err:%v", err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, stmt := range statements {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatalf("exec failed, sql:%v, err:%v", stmt, err)
+		}
+	}
+	return db
+}
+
+//connect on sqlite memory db
+func newSqliteTestConnect(t *testing.T, statements ...string) *mysql.Connect {
+	return mysql.NewConnectWithDB(newSqliteTestDB(t, statements...))
+}
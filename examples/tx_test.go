@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinycells/db/mysql"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"testing"
+	"time"
+)
+
+//connect with user table
+func newTxTestConnect(t *testing.T) *mysql.Connect {
+	return newSqliteTestConnect(t, "CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT)")
+}
+
+func countTxTestUser(t *testing.T, conn *mysql.Connect) int {
+	records, err := conn.GetRows("SELECT id FROM user")
+	if err != nil {
+		t.Fatalf("get rows failed, err:%v", err)
+	}
+	return len(records)
+}
+
+func TestIsDeadlock(t *testing.T) {
+	err := fmt.Errorf("update user: %w", &mysqlDriver.MySQLError{Number: mysql.ErrNumOfDeadlock})
+	if !mysql.IsDeadlock(err) {
+		t.Fatal("wrapped deadlock error should match")
+	}
+	if mysql.IsDeadlock(&mysqlDriver.MySQLError{Number: 1062}) || mysql.IsDeadlock(errors.New("deadlock")) {
+		t.Fatal("other error should not match")
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	conn := newTxTestConnect(t)
+	defer conn.Quit()
+
+	//commit on nil
+	err := conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+		_, _, err := tx.Execute("INSERT INTO user (id, name) VALUES (?, ?)", 1, "a")
+		return err
+	})
+	if err != nil || countTxTestUser(t, conn) != 1 {
+		t.Fatalf("commit failed, err:%v", err)
+	}
+
+	//rollback on error
+	errFail := errors.New("fail")
+	err = conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+		tx.Execute("INSERT INTO user (id, name) VALUES (?, ?)", 2, "b")
+		return errFail
+	})
+	if err != errFail || countTxTestUser(t, conn) != 1 {
+		t.Fatalf("rollback on error failed, err:%v", err)
+	}
+
+	//rollback on panic, panic passed through
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Fatal("panic should be passed through")
+			}
+		}()
+		conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+			tx.Execute("INSERT INTO user (id, name) VALUES (?, ?)", 3, "c")
+			panic("boom")
+		})
+	}()
+	if countTxTestUser(t, conn) != 1 {
+		t.Fatal("rollback on panic failed")
+	}
+
+	//savepoint, failed nested tx rollback only itself
+	err = conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+		if _, _, err := tx.Execute("INSERT INTO user (id, name) VALUES (?, ?)", 4, "d"); err != nil {
+			return err
+		}
+		nestedErr := tx.WithTx(nil, func(nested mysql.Tx) error {
+			nested.Execute("INSERT INTO user (id, name) VALUES (?, ?)", 5, "e")
+			return errFail
+		})
+		if nestedErr != errFail {
+			return fmt.Errorf("nested err invalid, err:%v", nestedErr)
+		}
+		return tx.WithTx(nil, func(nested mysql.Tx) error {
+			_, _, err := nested.Execute("INSERT INTO user (id, name) VALUES (?, ?)", 6, "f")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("savepoint tx failed, err:%v", err)
+	}
+	if records, _ := conn.GetRows("SELECT id FROM user WHERE id IN (4, 5, 6)"); len(records) != 2 {
+		t.Fatalf("savepoint rollback invalid, records:%v", records)
+	}
+}
+
+//deadlock simulated by returned driver error, real 1213 of mysql server not covered
+func TestWithTxDeadlockRetry(t *testing.T) {
+	conn := newTxTestConnect(t)
+	defer conn.Quit()
+	deadlock := &mysqlDriver.MySQLError{Number: mysql.ErrNumOfDeadlock, Message: "Deadlock found"}
+
+	//retry until success, failed attempts rollback
+	attempts := 0
+	opt := mysql.NewTxOption()
+	opt.RetryBackoff = time.Millisecond
+	err := conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+		attempts++
+		if _, _, err := tx.Execute("INSERT INTO user (id, name) VALUES (?, ?)", 1, "a"); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return fmt.Errorf("update: %w", deadlock)
+		}
+		return nil
+	}, opt)
+	if err != nil || attempts != 3 || countTxTestUser(t, conn) != 1 {
+		t.Fatalf("retry failed, attempts:%v, err:%v", attempts, err)
+	}
+
+	//give up after max retry
+	attempts = 0
+	opt.MaxRetry = 2
+	err = conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+		attempts++
+		return deadlock
+	}, opt)
+	if !mysql.IsDeadlock(err) || attempts != 3 {
+		t.Fatalf("max retry invalid, attempts:%v, err:%v", attempts, err)
+	}
+
+	//backoff doubled each retry
+	attempts = 0
+	opt.RetryBackoff = 20 * time.Millisecond
+	beginTime := time.Now()
+	conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+		attempts++
+		return deadlock
+	}, opt)
+	if cost := time.Since(beginTime); cost < 60 * time.Millisecond {
+		t.Fatalf("backoff too short, cost:%v", cost)
+	}
+
+	//no retry for other error
+	attempts = 0
+	conn.WithTx(context.Background(), func(tx mysql.Tx) error {
+		attempts++
+		return errors.New("fail")
+	}, opt)
+	if attempts != 1 {
+		t.Fatalf("other error should not retry, attempts:%v", attempts)
+	}
+
+	//stop waiting backoff when ctx done
+	ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Millisecond)
+	defer cancel()
+	opt.RetryBackoff = time.Second
+	err = conn.WithTx(ctx, func(tx mysql.Tx) error {
+		return deadlock
+	}, opt)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("ctx done should stop retry, err:%v", err)
+	}
+}
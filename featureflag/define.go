@@ -0,0 +1,25 @@
+package featureflag
+
+//config key define
+const (
+	FlagConfigKey = "flags" //root key of json config
+	FlagSectionPrefix = "flag." //section prefix of ini config, like [flag.xxx]
+
+	FlagFieldOfEnabled = "enabled"
+	FlagFieldOfPercentage = "percentage"
+	FlagFieldOfAllowUsers = "allowUsers"
+	FlagFieldOfDenyUsers = "denyUsers"
+	FlagFieldOfStartTime = "startTime"
+	FlagFieldOfEndTime = "endTime"
+)
+
+//gin context key
+const (
+	GinContextKeyOfFlags = "featureFlags"
+)
+
+//default value
+const (
+	PercentageMax = 100
+	ListSplit = ","
+)
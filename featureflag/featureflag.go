@@ -0,0 +1,190 @@
+package featureflag
+
+import (
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinycells/config"
+	"github.com/andyzhou/tinycells/util"
+	"log"
+	"strings"
+	"sync"
+)
+
+/*
+ * feature flag engine
+ * - flags loaded from json or ini config
+ * - support boolean, percentage rollout, allow/deny user list and time window
+ * - hot reload through `config.SubConfig`
+ */
+
+//global variable
+var (
+	_featureFlag *FeatureFlag
+	_featureFlagOnce sync.Once
+)
+
+//face info
+type FeatureFlag struct {
+	flagMap map[string]*Flag //name -> *Flag
+	subConfig *config.SubConfig
+	util.Util
+	sync.RWMutex
+}
+
+//get single instance
+func GetFeatureFlag() *FeatureFlag {
+	_featureFlagOnce.Do(func() {
+		_featureFlag = NewFeatureFlag()
+	})
+	return _featureFlag
+}
+
+//construct
+func NewFeatureFlag() *FeatureFlag {
+	this := &FeatureFlag{
+		flagMap: map[string]*Flag{},
+	}
+	return this
+}
+
+//quit
+func (f *FeatureFlag) Quit() {
+	if f.subConfig != nil {
+		f.subConfig.Quit()
+		f.subConfig = nil
+	}
+}
+
+//check flag is enabled
+//un-configured flag is always disabled
+func (f *FeatureFlag) IsEnabled(flag string, ctx *Context) bool {
+	f.RLock()
+	v, ok := f.flagMap[flag]
+	f.RUnlock()
+	if !ok || v == nil {
+		return false
+	}
+	return v.IsEnabled(ctx, &f.Util)
+}
+
+//get all enabled flags for context
+func (f *FeatureFlag) GetEnabledFlags(ctx *Context) map[string]bool {
+	result := map[string]bool{}
+	f.RLock()
+	defer f.RUnlock()
+	for name, v := range f.flagMap {
+		result[name] = v.IsEnabled(ctx, &f.Util)
+	}
+	return result
+}
+
+//get flag define
+func (f *FeatureFlag) GetFlag(flag string) *Flag {
+	f.RLock()
+	defer f.RUnlock()
+	v, ok := f.flagMap[flag]
+	if ok {
+		return v
+	}
+	return nil
+}
+
+//set or overwrite one flag
+func (f *FeatureFlag) SetFlag(flag *Flag) error {
+	if flag == nil || flag.Name == "" {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.flagMap[flag.Name] = flag
+	return nil
+}
+
+//watch json config file, reload flags when file changed
+func (f *FeatureFlag) WatchJsonFile(confFile string, checkRate ...int) error {
+	if confFile == "" {
+		return errors.New("invalid parameter")
+	}
+	if f.subConfig != nil {
+		return errors.New("flag config had watched")
+	}
+	f.subConfig = config.NewSubConfig(confFile, f.cbForReload, checkRate...)
+	return nil
+}
+
+//load flags from json config map
+//flags under `FlagConfigKey` key, or whole map if key not exists
+func (f *FeatureFlag) LoadFromMap(confMap map[string]interface{}) error {
+	if confMap == nil {
+		return errors.New("invalid parameter")
+	}
+	flagsMap := confMap
+	if v, ok := confMap[FlagConfigKey].(map[string]interface{}); ok {
+		flagsMap = v
+	}
+
+	//parse all flags
+	newFlagMap := map[string]*Flag{}
+	for name, v := range flagsMap {
+		flag := NewFlag(name)
+		switch data := v.(type) {
+		case bool:
+			flag.Enabled = data
+		case map[string]interface{}:
+			if err := flag.fillFromMap(data); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid flag %v define", name)
+		}
+		newFlagMap[name] = flag
+	}
+
+	//replace flags
+	f.Lock()
+	defer f.Unlock()
+	f.flagMap = newFlagMap
+	return nil
+}
+
+//load flags from ini config file
+//each flag is one section, like [flag.xxx]
+func (f *FeatureFlag) LoadFromIni(file config.File) error {
+	if file == nil {
+		return errors.New("invalid parameter")
+	}
+
+	//parse all flags
+	newFlagMap := map[string]*Flag{}
+	for section, values := range file.GetAllSection() {
+		if !strings.HasPrefix(section, FlagSectionPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(section, FlagSectionPrefix)
+		flag := NewFlag(name)
+		if err := flag.fillFromSection(values); err != nil {
+			return err
+		}
+		newFlagMap[name] = flag
+	}
+
+	//replace flags
+	f.Lock()
+	defer f.Unlock()
+	f.flagMap = newFlagMap
+	return nil
+}
+
+////////////////
+//private func
+////////////////
+
+//cb for sub config reload
+func (f *FeatureFlag) cbForReload(confMap map[string]interface{}) bool {
+	err := f.LoadFromMap(confMap)
+	if err != nil {
+		log.Printf("FeatureFlag::cbForReload failed, err:%v\n", err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package featureflag
+
+import (
+	"fmt"
+	"github.com/andyzhou/tinycells/util"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * single feature flag define and evaluate
+ */
+
+//evaluate context
+//session id used as bucket of anonymous user, like cookie or device id
+type Context struct {
+	UserId string
+	SessionId string
+	Attributes map[string]string
+}
+
+//flag info
+type Flag struct {
+	Name string `json:"name"`
+	Enabled bool `json:"enabled"`
+	Percentage int `json:"percentage"` //0~100, PercentageMax means all
+	AllowUsers map[string]bool `json:"allowUsers"`
+	DenyUsers map[string]bool `json:"denyUsers"`
+	StartTime int64 `json:"startTime"` //utc unix, 0 means no limit
+	EndTime int64 `json:"endTime"` //utc unix, 0 means no limit
+}
+
+//construct
+func NewFlag(name string) *Flag {
+	this := &Flag{
+		Name: name,
+		Percentage: PercentageMax,
+		AllowUsers: map[string]bool{},
+		DenyUsers: map[string]bool{},
+	}
+	return this
+}
+
+//check flag is enabled for context
+//evaluate order: switcher, time window, deny list, allow list, percentage
+func (f *Flag) IsEnabled(ctx *Context, u *util.Util) bool {
+	if !f.Enabled {
+		return false
+	}
+
+	//check time window
+	now := u.Now().Unix()
+	if f.StartTime > 0 && now < f.StartTime {
+		return false
+	}
+	if f.EndTime > 0 && now >= f.EndTime {
+		return false
+	}
+
+	//check user list
+	userId := ""
+	if ctx != nil {
+		userId = ctx.UserId
+	}
+	if userId != "" {
+		if f.DenyUsers[userId] {
+			return false
+		}
+		if f.AllowUsers[userId] {
+			return true
+		}
+	}
+
+	//check percentage rollout
+	//full percentage with allow list means only allowed users
+	if f.Percentage >= PercentageMax {
+		return len(f.AllowUsers) <= 0
+	}
+	if f.Percentage <= 0 {
+		return false
+	}
+	bucketKey := userId
+	if bucketKey == "" && ctx != nil {
+		bucketKey = ctx.SessionId
+	}
+	if bucketKey == "" {
+		//no stable key, partial rollout disabled
+		return false
+	}
+	return f.bucket(bucketKey) < f.Percentage
+}
+
+//fill flag from json config map
+func (f *Flag) fillFromMap(data map[string]interface{}) error {
+	for k, v := range data {
+		switch k {
+		case FlagFieldOfEnabled:
+			f.Enabled = f.toBool(v)
+		case FlagFieldOfPercentage:
+			f.Percentage = f.toPercentage(v)
+		case FlagFieldOfAllowUsers:
+			f.AllowUsers = f.toSet(v)
+		case FlagFieldOfDenyUsers:
+			f.DenyUsers = f.toSet(v)
+		case FlagFieldOfStartTime:
+			t, err := f.toTime(v)
+			if err != nil {
+				return err
+			}
+			f.StartTime = t
+		case FlagFieldOfEndTime:
+			t, err := f.toTime(v)
+			if err != nil {
+				return err
+			}
+			f.EndTime = t
+		}
+	}
+	return nil
+}
+
+//fill flag from ini section
+func (f *Flag) fillFromSection(section map[string]string) error {
+	data := make(map[string]interface{}, len(section))
+	for k, v := range section {
+		data[k] = v
+	}
+	return f.fillFromMap(data)
+}
+
+//get stable bucket of user or session, 0~99
+func (f *Flag) bucket(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(fmt.Sprintf("%s:%s", f.Name, key)))
+	return int(h.Sum32() % PercentageMax)
+}
+
+//convert value to bool
+func (f *Flag) toBool(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(val))
+		return b
+	case float64:
+		return val != 0
+	}
+	return false
+}
+
+//convert value to int
+func (f *Flag) toInt(v interface{}) int64 {
+	switch val := v.(type) {
+	case float64:
+		return int64(val)
+	case int:
+		return int64(val)
+	case int64:
+		return val
+	case string:
+		i, _ := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		return i
+	}
+	return 0
+}
+
+//convert value to percentage, clamp into 0~100
+func (f *Flag) toPercentage(v interface{}) int {
+	percentage := f.toInt(v)
+	if percentage < 0 {
+		return 0
+	}
+	if percentage > PercentageMax {
+		return PercentageMax
+	}
+	return int(percentage)
+}
+
+//convert value to string set
+//support json array or comma split string
+func (f *Flag) toSet(v interface{}) map[string]bool {
+	result := map[string]bool{}
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			switch id := item.(type) {
+			case string:
+				result[id] = true
+			case float64:
+				result[strconv.FormatInt(int64(id), 10)] = true
+			}
+		}
+	case string:
+		for _, id := range strings.Split(val, ListSplit) {
+			id = strings.TrimSpace(id)
+			if id != "" {
+				result[id] = true
+			}
+		}
+	}
+	return result
+}
+
+//convert value to utc unix time
+//support unix number or `util.TimeLayoutStr` format
+func (f *Flag) toTime(v interface{}) (int64, error) {
+	str, ok := v.(string)
+	if !ok {
+		return f.toInt(v), nil
+	}
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, nil
+	}
+	if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return i, nil
+	}
+	t, err := time.Parse(util.TimeLayoutStr, str)
+	if err != nil {
+		return 0, fmt.Errorf("flag %v invalid time %v", f.Name, str)
+	}
+	return t.Unix(), nil
+}
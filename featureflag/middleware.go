@@ -0,0 +1,49 @@
+package featureflag
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+/*
+ * gin middleware for feature flag
+ */
+
+//context builder for request
+type (
+	ContextBuilder func(c *gin.Context) *Context
+)
+
+//gin middleware, evaluate all flags and set into gin context
+//if builder is nil, evaluate as anonymous user without session, partial rollout disabled
+func (f *FeatureFlag) Middleware(builder ContextBuilder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var (
+			ctx *Context
+		)
+		if builder != nil {
+			ctx = builder(c)
+		}
+		c.Set(GinContextKeyOfFlags, f.GetEnabledFlags(ctx))
+		c.Next()
+	}
+}
+
+//check flag is enabled in gin context
+//should be called after `Middleware`
+func IsEnabledInGin(c *gin.Context, flag string) bool {
+	flags := GetFlagsInGin(c)
+	return flags[flag]
+}
+
+//get all evaluated flags in gin context
+func GetFlagsInGin(c *gin.Context) map[string]bool {
+	if c == nil {
+		return nil
+	}
+	v, ok := c.Get(GinContextKeyOfFlags)
+	if !ok {
+		return nil
+	}
+	flags, _ := v.(map[string]bool)
+	return flags
+}
@@ -0,0 +1,569 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+/*
+ * QR code generator, ISO/IEC 18004
+ * - byte mode, error correction level M
+ * - version 1~10, max 213 bytes, enough for otpauth uri
+ * - png output
+ */
+
+//inter default value
+const (
+	QrMaxVersion = 10
+	QrQuietZone = 4 //modules
+	QrDefaultScale = 6 //pixels per module
+)
+
+//block info of level M, index by version
+//total codewords, ec codewords per block, group1 blocks, group1 data codewords, group2 blocks, group2 data codewords
+var qrBlockTable = [QrMaxVersion + 1][6]int{
+	{},
+	{26, 10, 1, 16, 0, 0},
+	{44, 16, 1, 28, 0, 0},
+	{70, 26, 1, 44, 0, 0},
+	{100, 18, 2, 32, 0, 0},
+	{134, 24, 2, 43, 0, 0},
+	{172, 16, 4, 27, 0, 0},
+	{196, 18, 4, 31, 0, 0},
+	{242, 22, 2, 38, 2, 39},
+	{292, 22, 3, 36, 2, 37},
+	{346, 26, 4, 43, 1, 44},
+}
+
+//alignment pattern positions, index by version
+var qrAlignTable = [QrMaxVersion + 1][]int{
+	{}, {},
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+//face info
+type QrCode struct {
+	version int
+	size int
+	modules [][]bool //[y][x], true is dark
+	isFunction [][]bool
+}
+
+//construct, encode data into qr code
+func NewQrCode(data []byte) (*QrCode, error) {
+	//choose min version
+	version := 0
+	for v := 1; v <= QrMaxVersion; v++ {
+		if len(data) <= qrCapacity(v) {
+			version = v
+			break
+		}
+	}
+	if version <= 0 {
+		return nil, errors.New("data too long for qr code")
+	}
+
+	//self init
+	size := version * 4 + 17
+	this := &QrCode{
+		version: version,
+		size: size,
+		modules: make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := 0; i < size; i++ {
+		this.modules[i] = make([]bool, size)
+		this.isFunction[i] = make([]bool, size)
+	}
+
+	//draw and choose best mask
+	this.drawFunctionPatterns()
+	this.drawCodewords(this.addEcAndInterleave(this.encodeData(data)))
+	bestMask, minPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		this.applyMask(mask)
+		this.drawFormatBits(mask)
+		penalty := this.getPenalty()
+		if minPenalty < 0 || penalty < minPenalty {
+			bestMask, minPenalty = mask, penalty
+		}
+		this.applyMask(mask) //undo
+	}
+	this.applyMask(bestMask)
+	this.drawFormatBits(bestMask)
+	return this, nil
+}
+
+//get module size
+func (f *QrCode) GetSize() int {
+	return f.size
+}
+
+//check module is dark
+func (f *QrCode) IsDark(x, y int) bool {
+	if x < 0 || y < 0 || x >= f.size || y >= f.size {
+		return false
+	}
+	return f.modules[y][x]
+}
+
+//gen image with quiet zone
+//scale is pixels per module, optional
+func (f *QrCode) Image(scales ...int) image.Image {
+	scale := QrDefaultScale
+	if scales != nil && len(scales) > 0 && scales[0] > 0 {
+		scale = scales[0]
+	}
+	width := (f.size + QrQuietZone * 2) * scale
+	img := image.NewGray(image.Rect(0, 0, width, width))
+	for py := 0; py < width; py++ {
+		for px := 0; px < width; px++ {
+			c := color.Gray{Y: 0xff}
+			if f.IsDark(px / scale - QrQuietZone, py / scale - QrQuietZone) {
+				c = color.Gray{Y: 0}
+			}
+			img.SetGray(px, py, c)
+		}
+	}
+	return img
+}
+
+//gen png data
+func (f *QrCode) Png(scales ...int) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := png.Encode(buf, f.Image(scales...)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//encode data as qr code png
+func GenQrCodePng(data []byte, scales ...int) ([]byte, error) {
+	qr, err := NewQrCode(data)
+	if err != nil {
+		return nil, err
+	}
+	return qr.Png(scales...)
+}
+
+////////////////
+//private func
+////////////////
+
+//get byte capacity of version
+func qrCapacity(version int) int {
+	info := qrBlockTable[version]
+	dataBits := (info[2] * info[3] + info[4] * info[5]) * 8
+	return (dataBits - 4 - qrCountBits(version)) / 8
+}
+
+//get char count bits of byte mode
+func qrCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+//encode data into data codewords
+func (f *QrCode) encodeData(data []byte) []byte {
+	info := qrBlockTable[f.version]
+	capacity := info[2] * info[3] + info[4] * info[5]
+	bits := make([]bool, 0, capacity * 8)
+	appendBits := func(val, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (val >> uint(i)) & 1 != 0)
+		}
+	}
+
+	//mode, count and data
+	appendBits(0x4, 4)
+	appendBits(len(data), qrCountBits(f.version))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	//terminator and pad to byte
+	for i := 0; i < 4 && len(bits) < capacity * 8; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits) % 8 != 0 {
+		bits = append(bits, false)
+	}
+
+	//bits to bytes, pad bytes
+	result := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << uint(7 - j)
+			}
+		}
+		result = append(result, b)
+	}
+	for pad := byte(0xEC); len(result) < capacity; pad ^= 0xEC ^ 0x11 {
+		result = append(result, pad)
+	}
+	return result
+}
+
+//split into blocks, add ec and interleave
+func (f *QrCode) addEcAndInterleave(data []byte) []byte {
+	info := qrBlockTable[f.version]
+	ecLen := info[1]
+	divisor := qrRsDivisor(ecLen)
+
+	//split blocks
+	dataBlocks := make([][]byte, 0)
+	ecBlocks := make([][]byte, 0)
+	offset := 0
+	for g := 0; g < 2; g++ {
+		blocks, blockLen := info[2 + g * 2], info[3 + g * 2]
+		for i := 0; i < blocks; i++ {
+			block := data[offset : offset + blockLen]
+			offset += blockLen
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, qrRsRemainder(block, divisor))
+		}
+	}
+
+	//interleave
+	result := make([]byte, 0, info[0])
+	maxLen := info[3]
+	if info[5] > maxLen {
+		maxLen = info[5]
+	}
+	for i := 0; i < maxLen; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+//draw finder, timing, alignment patterns and reserve format area
+func (f *QrCode) drawFunctionPatterns() {
+	//timing patterns
+	for i := 0; i < f.size; i++ {
+		f.setFunction(6, i, i % 2 == 0)
+		f.setFunction(i, 6, i % 2 == 0)
+	}
+
+	//finder patterns
+	f.drawFinder(3, 3)
+	f.drawFinder(f.size - 4, 3)
+	f.drawFinder(3, f.size - 4)
+
+	//alignment patterns, skip overlap with finders
+	positions := qrAlignTable[f.version]
+	num := len(positions)
+	for i := 0; i < num; i++ {
+		for j := 0; j < num; j++ {
+			if (i == 0 && j == 0) || (i == 0 && j == num - 1) || (i == num - 1 && j == 0) {
+				continue
+			}
+			f.drawAlignment(positions[i], positions[j])
+		}
+	}
+
+	//reserve format area, draw version
+	f.drawFormatBits(0)
+	f.drawVersion()
+}
+
+//draw finder pattern with separator
+func (f *QrCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x + dx, y + dy
+			if xx < 0 || yy < 0 || xx >= f.size || yy >= f.size {
+				continue
+			}
+			dist := qrMax(qrAbs(dx), qrAbs(dy))
+			f.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+//draw alignment pattern
+func (f *QrCode) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			f.setFunction(x + dx, y + dy, qrMax(qrAbs(dx), qrAbs(dy)) != 1)
+		}
+	}
+}
+
+//draw format bits, level M
+func (f *QrCode) drawFormatBits(mask int) {
+	data := mask //level M format bits is 0
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data << 10 | rem) ^ 0x5412
+	bit := func(i int) bool {
+		return (bits >> uint(i)) & 1 != 0
+	}
+
+	//first copy
+	for i := 0; i <= 5; i++ {
+		f.setFunction(8, i, bit(i))
+	}
+	f.setFunction(8, 7, bit(6))
+	f.setFunction(8, 8, bit(7))
+	f.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		f.setFunction(14 - i, 8, bit(i))
+	}
+
+	//second copy
+	for i := 0; i < 8; i++ {
+		f.setFunction(f.size - 1 - i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		f.setFunction(8, f.size - 15 + i, bit(i))
+	}
+	f.setFunction(8, f.size - 8, true) //dark module
+}
+
+//draw version info, version 7 and above
+func (f *QrCode) drawVersion() {
+	if f.version < 7 {
+		return
+	}
+	rem := f.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := f.version << 12 | rem
+	for i := 0; i < 18; i++ {
+		dark := (bits >> uint(i)) & 1 != 0
+		a, b := f.size - 11 + i % 3, i / 3
+		f.setFunction(a, b, dark)
+		f.setFunction(b, a, dark)
+	}
+}
+
+//draw codewords in zigzag order
+func (f *QrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := f.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < f.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right + 1) & 2 == 0 {
+					y = f.size - 1 - vert //upward
+				}
+				if f.isFunction[y][x] {
+					continue
+				}
+				//remainder bits keep light
+				if i < len(data) * 8 {
+					f.modules[y][x] = (data[i >> 3] >> uint(7 - i & 7)) & 1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+//apply mask on data modules, call again to undo
+func (f *QrCode) applyMask(mask int) {
+	for y := 0; y < f.size; y++ {
+		for x := 0; x < f.size; x++ {
+			if f.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x + y) % 2 == 0
+			case 1:
+				invert = y % 2 == 0
+			case 2:
+				invert = x % 3 == 0
+			case 3:
+				invert = (x + y) % 3 == 0
+			case 4:
+				invert = (x / 3 + y / 2) % 2 == 0
+			case 5:
+				invert = x * y % 2 + x * y % 3 == 0
+			case 6:
+				invert = (x * y % 2 + x * y % 3) % 2 == 0
+			case 7:
+				invert = ((x + y) % 2 + x * y % 3) % 2 == 0
+			}
+			if invert {
+				f.modules[y][x] = !f.modules[y][x]
+			}
+		}
+	}
+}
+
+//get penalty score of current modules
+//N1 same color runs, N2 2x2 blocks, N3 finder like patterns, N4 dark proportion
+//outside of symbol is light, as the quiet zone
+func (f *QrCode) getPenalty() int {
+	score, dark := 0, 0
+	for i := 0; i < f.size; i++ {
+		for _, horizontal := range []bool{true, false} {
+			color, run := false, 0
+			history := make([]int, 7)
+			for j := 0; j < f.size; j++ {
+				cur := f.modules[i][j]
+				if !horizontal {
+					cur = f.modules[j][i]
+				}
+				if cur == color {
+					run++
+					if run == 5 {
+						score += 3
+					} else if run > 5 {
+						score++
+					}
+					continue
+				}
+				f.addRunHistory(run, history)
+				if !color {
+					score += f.countFinderLike(history) * 40
+				}
+				color, run = cur, 1
+			}
+			//terminate with light quiet zone
+			if color {
+				f.addRunHistory(run, history)
+				run = 0
+			}
+			f.addRunHistory(run + f.size, history)
+			score += f.countFinderLike(history) * 40
+		}
+	}
+
+	//2x2 blocks and dark proportion
+	for y := 0; y < f.size; y++ {
+		for x := 0; x < f.size; x++ {
+			if f.modules[y][x] {
+				dark++
+			}
+			if x + 1 < f.size && y + 1 < f.size {
+				c := f.modules[y][x]
+				if c == f.modules[y][x+1] && c == f.modules[y+1][x] && c == f.modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+	total := f.size * f.size
+	score += ((qrAbs(dark * 20 - total * 10) + total - 1) / total - 1) * 10
+	return score
+}
+
+//push run length into history, newest first
+//first run counts quiet zone in
+func (f *QrCode) addRunHistory(run int, history []int) {
+	if history[0] == 0 {
+		run += f.size
+	}
+	copy(history[1:], history[:len(history) - 1])
+	history[0] = run
+}
+
+//count 1:1:3:1:1 patterns with 4 light modules on each side
+//history should end with a light run
+func (f *QrCode) countFinderLike(history []int) int {
+	n := history[1]
+	core := n > 0 && history[2] == n && history[3] == n * 3 && history[4] == n && history[5] == n
+	count := 0
+	if core && history[0] >= n * 4 && history[6] >= n {
+		count++
+	}
+	if core && history[6] >= n * 4 && history[0] >= n {
+		count++
+	}
+	return count
+}
+
+//set function module
+func (f *QrCode) setFunction(x, y int, dark bool) {
+	f.modules[y][x] = dark
+	f.isFunction[y][x] = true
+}
+
+//reed solomon divisor of degree
+func qrRsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree - 1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			result[j] = qrGfMul(result[j], root)
+			if j + 1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrGfMul(root, 0x02)
+	}
+	return result
+}
+
+//reed solomon remainder
+func qrRsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result) - 1] = 0
+		for i, coef := range divisor {
+			result[i] ^= qrGfMul(coef, factor)
+		}
+	}
+	return result
+}
+
+//multiply in GF(2^8/0x11D)
+func qrGfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y >> uint(i)) & 1) * int(x)
+	}
+	return byte(z)
+}
+
+func qrAbs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func qrMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"time"
+)
+
+/*
+ * migrate sub command for `cmd.Cmd`
+ * usage: app migrate up|down|status|create [--dir xxx] [--steps n] [--dry-run]
+ */
+
+//create migrator func, called when command run
+type CreateFunc func(c *cli.Context) (*Migrator, error)
+
+//create migrate command
+func NewCommand(cf CreateFunc) *cli.Command {
+	dirFlag := &cli.StringFlag{Name: "dir", Value: "migrations", Usage: "migration files dir"}
+	stepsFlag := &cli.IntFlag{Name: "steps", Usage: "steps to run, 0 means all for up"}
+	dryRunFlag := &cli.BoolFlag{Name: "dry-run", Usage: "print sql only"}
+
+	//get migrator with flags
+	getMigrator := func(c *cli.Context) (*Migrator, error) {
+		m, err := cf(c)
+		if err != nil {
+			return nil, err
+		}
+		if err = m.LoadDir(c.String("dir")); err != nil {
+			return nil, err
+		}
+		m.SetDryRun(c.Bool("dry-run"))
+		return m, nil
+	}
+
+	return &cli.Command{
+		Name: "migrate",
+		Usage: "schema migration",
+		Subcommands: []*cli.Command{
+			{
+				Name: "up",
+				Usage: "apply pending migrations",
+				Flags: []cli.Flag{dirFlag, stepsFlag, dryRunFlag},
+				Action: func(c *cli.Context) error {
+					m, err := getMigrator(c)
+					if err != nil {
+						return err
+					}
+					_, err = m.Up(context.Background(), c.Int("steps"))
+					return err
+				},
+			},
+			{
+				Name: "down",
+				Usage: "rollback applied migrations",
+				Flags: []cli.Flag{dirFlag, stepsFlag, dryRunFlag},
+				Action: func(c *cli.Context) error {
+					m, err := getMigrator(c)
+					if err != nil {
+						return err
+					}
+					steps := 1
+					if c.IsSet("steps") {
+						steps = c.Int("steps")
+					}
+					_, err = m.Down(context.Background(), steps)
+					return err
+				},
+			},
+			{
+				Name: "status",
+				Usage: "show migration status",
+				Flags: []cli.Flag{dirFlag},
+				Action: func(c *cli.Context) error {
+					m, err := getMigrator(c)
+					if err != nil {
+						return err
+					}
+					status, err := m.Status(context.Background())
+					if err != nil {
+						return err
+					}
+					for _, v := range status {
+						appliedAt := "pending"
+						if v.Applied {
+							appliedAt = time.Unix(v.AppliedAt, 0).Format(time.RFC3339)
+						}
+						fmt.Fprintf(m.out, "%d_%s\t%s\n", v.Version, v.Name, appliedAt)
+					}
+					return nil
+				},
+			},
+			{
+				Name: "create",
+				Usage: "create migration files, like: migrate create add_user_table",
+				ArgsUsage: "<name>",
+				Flags: []cli.Flag{dirFlag},
+				Action: func(c *cli.Context) error {
+					upFile, downFile, err := CreateFiles(c.String("dir"), c.Args().First())
+					if err != nil {
+						return err
+					}
+					fmt.Printf("created %s\ncreated %s\n", upFile, downFile)
+					return nil
+				},
+			},
+		},
+	}
+}
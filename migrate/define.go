@@ -0,0 +1,24 @@
+package migrate
+
+//dialect
+const (
+	DialectOfMysql = "mysql"
+	DialectOfSqlite = "sqlite"
+)
+
+//default value
+const (
+	SchemaTable = "schema_migrations"
+	LockName = "tc_migrate"
+	LockTimeout = 10 //xxx seconds
+	LockFileSuffix = ".migrate.lock"
+	FileSuffixOfUp = ".up.sql"
+	FileSuffixOfDown = ".down.sql"
+	VersionLayout = "20060102150405"
+)
+
+//no split block marker line of sql file
+const (
+	StatementBlockBegin = "-- migrate:block"
+	StatementBlockEnd = "-- migrate:endblock"
+)
@@ -0,0 +1,421 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+/*
+ * schema migration engine
+ * - migrations from up/down sql files or go registered
+ * - applied versions tracked in schema table
+ * - advisory lock, GET_LOCK on mysql, lock file on sqlite
+ *   lock file default to db file + `LockFileSuffix`
+ * - dry run only print what will be executed, no lock and no write
+ *
+ * note: mysql DDL is implicit committed, failed migration may need manual fix
+ */
+
+//valid schema table name
+var tableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//face info
+type Migrator struct {
+	db *sql.DB
+	dialect string
+	table string
+	lockFile string
+	migrations []*Migration
+	dryRun bool
+	out io.Writer
+	sync.Mutex
+}
+
+//construct
+//for `mysql.Connect` or `sqlite.SqlLite`, pass `GetDB()`
+func NewMigrator(db *sql.DB, dialect string) *Migrator {
+	this := &Migrator{
+		db: db,
+		dialect: dialect,
+		table: SchemaTable,
+		migrations: make([]*Migration, 0),
+		out: os.Stdout,
+	}
+	return this
+}
+
+//set schema table name, only letters, digits and underscore
+func (f *Migrator) SetTable(table string) error {
+	if !tableNameRegexp.MatchString(table) {
+		return fmt.Errorf("invalid schema table name %q", table)
+	}
+	f.table = table
+	return nil
+}
+
+//set lock file of sqlite, default to db file + `LockFileSuffix`
+func (f *Migrator) SetLockFile(lockFile string) {
+	f.lockFile = lockFile
+}
+
+//set dry run
+func (f *Migrator) SetDryRun(dryRun bool) {
+	f.dryRun = dryRun
+}
+
+//set output writer of progress and dry run
+func (f *Migrator) SetOutput(out io.Writer) {
+	if out == nil {
+		return
+	}
+	f.out = out
+}
+
+//load sql migrations from dir
+func (f *Migrator) LoadDir(dir string) error {
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if err = f.Add(migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//register go migration
+func (f *Migrator) Register(version int64, name string, up, down MigrationFunc) error {
+	return f.Add(&Migration{
+		Version: version,
+		Name: name,
+		UpFunc: up,
+		DownFunc: down,
+	})
+}
+
+//add migration
+func (f *Migrator) Add(migration *Migration) error {
+	if migration == nil || migration.Version <= 0 {
+		return errors.New("invalid migration")
+	}
+	f.Lock()
+	defer f.Unlock()
+	for _, v := range f.migrations {
+		if v.Version == migration.Version {
+			return fmt.Errorf("duplicate migration version %v", migration.Version)
+		}
+	}
+	f.migrations = append(f.migrations, migration)
+	sortMigrations(f.migrations)
+	return nil
+}
+
+//get status of all migrations, read only
+func (f *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	if f.db == nil {
+		return nil, errors.New("db not set")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	applied, err := f.getApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f.Lock()
+	defer f.Unlock()
+	result := make([]*Status, 0, len(f.migrations))
+	for _, migration := range f.migrations {
+		appliedAt, ok := applied[migration.Version]
+		result = append(result, &Status{
+			Version: migration.Version,
+			Name: migration.Name,
+			Applied: ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return result, nil
+}
+
+//apply pending migrations, steps <= 0 means all
+//return applied versions
+func (f *Migrator) Up(ctx context.Context, steps ...int) ([]int64, error) {
+	return f.run(ctx, true, f.getSteps(0, steps...))
+}
+
+//rollback applied migrations, default one step, steps <= 0 means all
+//return rollback versions
+func (f *Migrator) Down(ctx context.Context, steps ...int) ([]int64, error) {
+	return f.run(ctx, false, f.getSteps(1, steps...))
+}
+
+////////////////
+//private func
+////////////////
+
+//run migrations with lock
+func (f *Migrator) run(ctx context.Context, isUp bool, steps int) ([]int64, error) {
+	if f.db == nil {
+		return nil, errors.New("db not set")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	//lock and create schema table, skipped by dry run
+	if !f.dryRun {
+		unlock, err := f.lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		if err = f.createTable(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	//get pending migrations
+	applied, err := f.getApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	todo := f.getTodo(applied, isUp, steps)
+
+	//run one by one
+	result := make([]int64, 0, len(todo))
+	for _, migration := range todo {
+		if err = f.runOne(ctx, migration, isUp); err != nil {
+			return result, fmt.Errorf("migration %v_%v failed, err:%v", migration.Version, migration.Name, err)
+		}
+		result = append(result, migration.Version)
+	}
+	return result, nil
+}
+
+//run single migration in tx
+func (f *Migrator) runOne(ctx context.Context, migration *Migration, isUp bool) error {
+	direction, sqlText, fn := "down", migration.Down, migration.DownFunc
+	if isUp {
+		direction, sqlText, fn = "up", migration.Up, migration.UpFunc
+	}
+	statements := SplitStatements(sqlText)
+	if fn == nil && len(statements) <= 0 {
+		return fmt.Errorf("no %s migration defined", direction)
+	}
+
+	//dry run
+	if f.dryRun {
+		fmt.Fprintf(f.out, "-- [dry run] %s %d_%s\n", direction, migration.Version, migration.Name)
+		if fn != nil {
+			fmt.Fprintln(f.out, "-- go migration")
+		}
+		for _, stmt := range statements {
+			fmt.Fprintf(f.out, "%s;\n", stmt)
+		}
+		return nil
+	}
+
+	//run in tx
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if fn != nil {
+		err = fn(ctx, tx)
+	}else{
+		for _, stmt := range statements {
+			if _, err = tx.ExecContext(ctx, stmt); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		if isUp {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (?, ?, ?)",
+				f.table), migration.Version, migration.Name, time.Now().Unix())
+		}else{
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = ?", f.table), migration.Version)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Fprintf(f.out, "migrate %s %d_%s done\n", direction, migration.Version, migration.Name)
+	return nil
+}
+
+//get migrations to run
+func (f *Migrator) getTodo(applied map[int64]int64, isUp bool, steps int) []*Migration {
+	f.Lock()
+	defer f.Unlock()
+	result := make([]*Migration, 0)
+	if isUp {
+		for _, migration := range f.migrations {
+			if _, ok := applied[migration.Version]; !ok {
+				result = append(result, migration)
+			}
+		}
+	}else{
+		for i := len(f.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[f.migrations[i].Version]; ok {
+				result = append(result, f.migrations[i])
+			}
+		}
+	}
+	if steps > 0 && len(result) > steps {
+		result = result[:steps]
+	}
+	return result
+}
+
+//get applied versions, version -> applied at
+//schema table not exists means nothing applied
+func (f *Migrator) getApplied(ctx context.Context) (map[int64]int64, error) {
+	result := map[int64]int64{}
+	exists, err := f.hasTable(ctx)
+	if err != nil || !exists {
+		return result, err
+	}
+	rows, err := f.db.QueryContext(ctx, fmt.Sprintf("SELECT version, applied_at FROM %s", f.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version, appliedAt int64
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		result[version] = appliedAt
+	}
+	return result, rows.Err()
+}
+
+//check schema table exists
+func (f *Migrator) hasTable(ctx context.Context) (bool, error) {
+	var (
+		query string
+		count int
+	)
+	switch f.dialect {
+	case DialectOfMysql:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	case DialectOfSqlite:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	default:
+		return false, fmt.Errorf("unsupported dialect %v", f.dialect)
+	}
+	if err := f.db.QueryRowContext(ctx, query, f.table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+//create schema table
+func (f *Migrator) createTable(ctx context.Context) error {
+	_, err := f.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (" +
+		"version BIGINT NOT NULL PRIMARY KEY, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"applied_at BIGINT NOT NULL)", f.table))
+	return err
+}
+
+//take advisory lock, return unlock func
+func (f *Migrator) lock(ctx context.Context) (func(), error) {
+	switch f.dialect {
+	case DialectOfMysql:
+		//lock is session level, keep it on one conn
+		conn, err := f.db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var got sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", LockName, LockTimeout).Scan(&got)
+		if err != nil || got.Int64 != 1 {
+			conn.Close()
+			if err == nil {
+				err = errors.New("get migrate lock timeout")
+			}
+			return nil, err
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", LockName)
+			conn.Close()
+		}, nil
+	case DialectOfSqlite:
+		lockFile, err := f.getLockFile(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if lockFile == "" {
+			//memory db only visible in this process
+			return func() {}, nil
+		}
+		file, err := os.OpenFile(lockFile, os.O_CREATE | os.O_EXCL | os.O_WRONLY, 0644)
+		if err != nil {
+			if os.IsExist(err) {
+				return nil, fmt.Errorf("migrate locked by %v, remove it if stale", lockFile)
+			}
+			return nil, err
+		}
+		fmt.Fprintf(file, "%d", os.Getpid())
+		file.Close()
+		return func() {
+			os.Remove(lockFile)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported dialect %v", f.dialect)
+}
+
+//get lock file of sqlite, default to main db file + `LockFileSuffix`
+//empty file of memory db
+func (f *Migrator) getLockFile(ctx context.Context) (string, error) {
+	if f.lockFile != "" {
+		return f.lockFile, nil
+	}
+	rows, err := f.db.QueryContext(ctx, "PRAGMA database_list")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			seq int
+			name, file string
+		)
+		if err = rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		}
+		if name == "main" && file != "" {
+			return file + LockFileSuffix, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+//get steps
+func (f *Migrator) getSteps(defaultSteps int, steps ...int) int {
+	if steps != nil && len(steps) > 0 {
+		return steps[0]
+	}
+	return defaultSteps
+}
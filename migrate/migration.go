@@ -0,0 +1,232 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go migration func
+type MigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+//migration info
+type Migration struct {
+	Version int64
+	Name string
+	Up string //up sql
+	Down string //down sql
+	UpFunc MigrationFunc //used if set
+	DownFunc MigrationFunc //used if set
+}
+
+//migration status
+type Status struct {
+	Version int64
+	Name string
+	Applied bool
+	AppliedAt int64
+}
+
+//migration file name, like 20220102030405_create_user.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+//load migrations from dir
+func LoadDir(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	migrationMap := map[int64]*Migration{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		matches := fileNamePattern.FindStringSubmatch(file.Name())
+		if matches == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := migrationMap[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			migrationMap[version] = migration
+		}else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("duplicate migration version %v", version)
+		}
+		if matches[3] == "up" {
+			migration.Up = string(data)
+		}else{
+			migration.Down = string(data)
+		}
+	}
+	result := make([]*Migration, 0, len(migrationMap))
+	for _, migration := range migrationMap {
+		result = append(result, migration)
+	}
+	sortMigrations(result)
+	return result, nil
+}
+
+//create up and down sql files, version is current time
+//return up file path, down file path, error
+func CreateFiles(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	if name == "" {
+		return "", "", fmt.Errorf("invalid migration name")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	prefix := filepath.Join(dir, fmt.Sprintf("%s_%s", time.Now().UTC().Format(VersionLayout), name))
+	upFile, downFile := prefix + FileSuffixOfUp, prefix + FileSuffixOfDown
+	if err := ioutil.WriteFile(upFile, []byte("-- up sql\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := ioutil.WriteFile(downFile, []byte("-- down sql\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return upFile, downFile, nil
+}
+
+//split sql into statements by `;`, skip quoted text and comments
+//mysql conditional comment `/*! ... */` kept
+//text between `StatementBlockBegin` and `StatementBlockEnd` lines kept as one statement,
+//for trigger or procedure body with `;` inside
+func SplitStatements(sqlText string) []string {
+	var (
+		result = make([]string, 0)
+		buf strings.Builder
+		quote rune
+		inLineComment, inBlockComment, keepComment bool
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+			result = append(result, stmt)
+		}
+		buf.Reset()
+	}
+	runes := []rune(sqlText)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i + 1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case inLineComment:
+			if r == '\n' {
+				inLineComment = false
+			}
+			continue
+		case inBlockComment:
+			if keepComment {
+				buf.WriteRune(r)
+			}
+			if r == '*' && next == '/' {
+				if keepComment {
+					buf.WriteRune(next)
+				}
+				inBlockComment, keepComment = false, false
+				i++
+			}
+			continue
+		case quote != 0:
+			buf.WriteRune(r)
+			if r == '\\' && next != 0 {
+				buf.WriteRune(next)
+				i++
+			}else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case r == '-' && next == '-':
+			if getCommentLine(runes, i) != StatementBlockBegin {
+				inLineComment = true
+				break
+			}
+			//no split block
+			flush()
+			block, end := getStatementBlock(runes, i)
+			if block != "" {
+				result = append(result, block)
+			}
+			i = end
+		case r == '#':
+			inLineComment = true
+		case r == '/' && next == '*':
+			inBlockComment = true
+			keepComment = i + 2 < len(runes) && runes[i+2] == '!'
+			if keepComment {
+				buf.WriteString("/*")
+			}
+			i++
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			buf.WriteRune(r)
+		case r == ';':
+			flush()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	flush()
+	return result
+}
+
+//get trimmed line of comment start at pos
+func getCommentLine(runes []rune, pos int) string {
+	end := pos
+	for end < len(runes) && runes[end] != '\n' {
+		end++
+	}
+	return strings.TrimSpace(string(runes[pos:end]))
+}
+
+//get no split block start at begin marker pos
+//return block statement without trailing `;`, end pos of block
+func getStatementBlock(runes []rune, pos int) (string, int) {
+	//skip begin marker line
+	for pos < len(runes) && runes[pos] != '\n' {
+		pos++
+	}
+	begin := pos
+	for pos < len(runes) {
+		lineEnd := pos + 1
+		for lineEnd < len(runes) && runes[lineEnd] != '\n' {
+			lineEnd++
+		}
+		if pos + 1 < len(runes) && strings.TrimSpace(string(runes[pos+1:lineEnd])) == StatementBlockEnd {
+			break
+		}
+		pos = lineEnd
+	}
+	block := strings.TrimSpace(string(runes[begin:pos]))
+	block = strings.TrimSpace(strings.TrimSuffix(block, ";"))
+	//skip end marker line
+	for pos + 1 < len(runes) && runes[pos+1] != '\n' {
+		pos++
+	}
+	return block, pos
+}
+
+//sort migrations by version
+func sortMigrations(migrations []*Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
@@ -0,0 +1,27 @@
+package session
+
+import "time"
+
+//default value
+const (
+	SessionCookieName = "tc_session"
+	SessionTtl = 30 * time.Minute
+	SessionIdSize = 32
+	SessionCleanRate = 60 //xxx seconds
+)
+
+//gin context key
+const (
+	GinContextKeyOfSession = "session"
+)
+
+//store key and table
+const (
+	SessionRedisKeyPrefix = "session:"
+	SessionSqliteTable = "tc_session"
+)
+
+//flash default category
+const (
+	FlashCategoryDefault = "default"
+)
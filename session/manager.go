@@ -0,0 +1,200 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/andyzhou/tinycells/web"
+	"github.com/gin-gonic/gin"
+	"log"
+	"time"
+)
+
+/*
+ * session manager
+ * - session id kept in secure cookie through `web.Cookie`
+ * - sliding expiration, ttl refreshed on each request
+ * - only changed session saved, un-changed session just refresh ttl
+ *   so concurrent requests of same session won't overwrite each other
+ */
+
+//face info
+type Manager struct {
+	store IStore
+	cookie *web.Cookie
+	cookieName string
+	cookieOption *web.CookieOption
+	ttl time.Duration
+}
+
+//construct
+//secure keys of cookie should be set, default cookie is `GetCookie()`
+func NewManager(store IStore, cookies ...*web.Cookie) *Manager {
+	this := &Manager{
+		store: store,
+		cookieName: SessionCookieName,
+		ttl: SessionTtl,
+	}
+	if cookies != nil && len(cookies) > 0 {
+		this.cookie = cookies[0]
+	}else{
+		this.cookie = web.GetCookie()
+	}
+	return this
+}
+
+//set ttl
+func (f *Manager) SetTtl(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	f.ttl = ttl
+}
+
+//set cookie name
+func (f *Manager) SetCookieName(name string) {
+	if name == "" {
+		return
+	}
+	f.cookieName = name
+}
+
+//set cookie option, default use option of `web.Cookie`
+func (f *Manager) SetCookieOption(option *web.CookieOption) {
+	f.cookieOption = option
+}
+
+//gin middleware
+func (f *Manager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		//load or create session
+		sess, err := f.Start(c)
+		if err != nil {
+			log.Printf("Manager::Middleware failed, err:%v\n", err)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.Set(GinContextKeyOfSession, sess)
+
+		//refresh cookie for sliding expiration
+		//cookie should be set before response written
+		if err = f.setCookie(sess.GetId(), c); err != nil {
+			log.Printf("Manager::Middleware set cookie failed, err:%v\n", err)
+		}
+		c.Next()
+
+		//save or refresh session
+		if err = f.save(sess); err != nil {
+			log.Printf("Manager::Middleware save failed, err:%v\n", err)
+		}
+	}
+}
+
+//load session from request, create new one if not exists
+func (f *Manager) Start(c *gin.Context) (*Session, error) {
+	var (
+		id string
+	)
+	//load session
+	if err := f.cookie.GetSecureCookie(f.cookieName, &id, c); err == nil && id != "" {
+		data, err := f.store.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			sess := newSession(id, f, c)
+			if err = sess.decode(data); err == nil {
+				sess.isNew = false
+				return sess, nil
+			}
+		}
+	}
+
+	//create new session
+	id, err := f.genId()
+	if err != nil {
+		return nil, err
+	}
+	return newSession(id, f, c), nil
+}
+
+//get session from gin context
+func GetSession(c *gin.Context) *Session {
+	v, ok := c.Get(GinContextKeyOfSession)
+	if !ok {
+		return nil
+	}
+	sess, _ := v.(*Session)
+	return sess
+}
+
+////////////////
+//private func
+////////////////
+
+//save session into store
+func (f *Manager) save(sess *Session) error {
+	sess.Lock()
+	defer sess.Unlock()
+
+	//remove old session of regenerated
+	if sess.oldId != "" {
+		if err := f.store.Delete(sess.oldId); err != nil {
+			return err
+		}
+		sess.oldId = ""
+	}
+
+	//destroyed
+	if sess.destroyed {
+		return f.store.Delete(sess.id)
+	}
+
+	//un-changed, just refresh ttl
+	if !sess.changed {
+		if sess.isNew {
+			return nil
+		}
+		return f.store.Touch(sess.id, f.ttl)
+	}
+
+	//save data
+	data, err := sess.encode()
+	if err != nil {
+		return err
+	}
+	if err = f.store.Save(sess.id, data, f.ttl); err != nil {
+		return err
+	}
+	sess.changed = false
+	sess.isNew = false
+	return nil
+}
+
+//set session id cookie
+func (f *Manager) setCookie(id string, c *gin.Context) error {
+	if c == nil {
+		return nil
+	}
+	if f.cookieOption != nil {
+		return f.cookie.SetSecureCookie(f.cookieName, id, int(f.ttl / time.Second), c, f.cookieOption)
+	}
+	return f.cookie.SetSecureCookie(f.cookieName, id, int(f.ttl / time.Second), c)
+}
+
+//delete session id cookie
+func (f *Manager) delCookie(c *gin.Context) error {
+	if f.cookieOption != nil {
+		return f.cookie.DelCookie(f.cookieName, "", c, f.cookieOption)
+	}
+	return f.cookie.DelCookie(f.cookieName, "", c)
+}
+
+//gen random session id
+func (f *Manager) genId() (string, error) {
+	buf := make([]byte, SessionIdSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("gen session id failed")
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+ * memory session store
+ * expired session cleaned by background process
+ */
+
+//memory session item
+type memoryItem struct {
+	data []byte
+	expireAt time.Time
+}
+
+//face info
+type MemoryStore struct {
+	itemMap map[string]*memoryItem
+	closeChan chan bool
+	sync.RWMutex
+}
+
+//construct
+func NewMemoryStore() *MemoryStore {
+	this := &MemoryStore{
+		itemMap: map[string]*memoryItem{},
+		closeChan: make(chan bool, 1),
+	}
+	go this.runMainProcess()
+	return this
+}
+
+//quit
+func (f *MemoryStore) Quit() {
+	f.closeChan <- true
+}
+
+//load session data
+func (f *MemoryStore) Load(id string) ([]byte, error) {
+	f.RLock()
+	defer f.RUnlock()
+	item, ok := f.itemMap[id]
+	if !ok || time.Now().After(item.expireAt) {
+		return nil, nil
+	}
+	return item.data, nil
+}
+
+//save session data
+func (f *MemoryStore) Save(id string, data []byte, ttl time.Duration) error {
+	f.Lock()
+	defer f.Unlock()
+	f.itemMap[id] = &memoryItem{
+		data: append([]byte{}, data...),
+		expireAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+//refresh ttl
+func (f *MemoryStore) Touch(id string, ttl time.Duration) error {
+	f.Lock()
+	defer f.Unlock()
+	if item, ok := f.itemMap[id]; ok {
+		item.expireAt = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+//delete session
+func (f *MemoryStore) Delete(id string) error {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.itemMap, id)
+	return nil
+}
+
+////////////////
+//private func
+////////////////
+
+//clean expired sessions
+func (f *MemoryStore) cleanExpired() {
+	now := time.Now()
+	f.Lock()
+	defer f.Unlock()
+	for id, item := range f.itemMap {
+		if now.After(item.expireAt) {
+			delete(f.itemMap, id)
+		}
+	}
+}
+
+//main process
+func (f *MemoryStore) runMainProcess() {
+	ticker := time.NewTicker(SessionCleanRate * time.Second)
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println("MemoryStore:mainProcess panic, err:", err)
+		}
+		ticker.Stop()
+	}()
+
+	//loop
+	for {
+		select {
+		case <- ticker.C:
+			f.cleanExpired()
+		case <- f.closeChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package session
+
+import (
+	"github.com/andyzhou/tinycells/db/redis"
+	"time"
+)
+
+/*
+ * redis session store
+ * expiration handled by redis key ttl
+ */
+
+//face info
+type RedisStore struct {
+	conn *redis.Connection
+	keyPrefix string
+}
+
+//construct
+func NewRedisStore(conn *redis.Connection) *RedisStore {
+	this := &RedisStore{
+		conn: conn,
+		keyPrefix: SessionRedisKeyPrefix,
+	}
+	return this
+}
+
+//set key prefix
+func (f *RedisStore) SetKeyPrefix(prefix string) {
+	f.keyPrefix = prefix
+}
+
+//load session data
+func (f *RedisStore) Load(id string) ([]byte, error) {
+	data, err := f.conn.GetClient().Get(f.keyPrefix + id).Bytes()
+	if err != nil {
+		if err.Error() == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+//save session data
+func (f *RedisStore) Save(id string, data []byte, ttl time.Duration) error {
+	return f.conn.GetClient().Set(f.keyPrefix + id, data, ttl).Err()
+}
+
+//refresh ttl
+func (f *RedisStore) Touch(id string, ttl time.Duration) error {
+	return f.conn.GetClient().Expire(f.keyPrefix + id, ttl).Err()
+}
+
+//delete session
+func (f *RedisStore) Delete(id string) error {
+	return f.conn.GetClient().Del(f.keyPrefix + id).Err()
+}
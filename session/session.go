@@ -0,0 +1,193 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"sync"
+)
+
+/*
+ * single session
+ * values are json serialized, so number value will be float64 after loaded
+ */
+
+//persisted session data
+type sessionData struct {
+	Values map[string]interface{} `json:"values"`
+	Flashes map[string][]string `json:"flashes,omitempty"`
+}
+
+//face info
+type Session struct {
+	id string
+	oldId string //old id before regenerate
+	data *sessionData
+	isNew bool
+	changed bool
+	destroyed bool
+	manager *Manager
+	c *gin.Context
+	sync.RWMutex
+}
+
+//construct
+func newSession(id string, manager *Manager, c *gin.Context) *Session {
+	this := &Session{
+		id: id,
+		data: &sessionData{
+			Values: map[string]interface{}{},
+			Flashes: map[string][]string{},
+		},
+		isNew: true,
+		manager: manager,
+		c: c,
+	}
+	return this
+}
+
+//get session id
+func (f *Session) GetId() string {
+	f.RLock()
+	defer f.RUnlock()
+	return f.id
+}
+
+//check is new session
+func (f *Session) IsNew() bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.isNew
+}
+
+//get value
+func (f *Session) Get(key string) interface{} {
+	f.RLock()
+	defer f.RUnlock()
+	return f.data.Values[key]
+}
+
+//get value as string
+func (f *Session) GetString(key string) string {
+	v := f.Get(key)
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+//set value
+func (f *Session) Set(key string, val interface{}) {
+	f.Lock()
+	defer f.Unlock()
+	f.data.Values[key] = val
+	f.changed = true
+}
+
+//delete value
+func (f *Session) Delete(key string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.data.Values, key)
+	f.changed = true
+}
+
+//clear all values and flashes
+func (f *Session) Clear() {
+	f.Lock()
+	defer f.Unlock()
+	f.data.Values = map[string]interface{}{}
+	f.data.Flashes = map[string][]string{}
+	f.changed = true
+}
+
+//add flash message, category is optional
+func (f *Session) AddFlash(message string, categories ...string) {
+	category := f.getCategory(categories...)
+	f.Lock()
+	defer f.Unlock()
+	f.data.Flashes[category] = append(f.data.Flashes[category], message)
+	f.changed = true
+}
+
+//get and clear flash messages, category is optional
+func (f *Session) Flashes(categories ...string) []string {
+	category := f.getCategory(categories...)
+	f.Lock()
+	defer f.Unlock()
+	messages, ok := f.data.Flashes[category]
+	if !ok {
+		return nil
+	}
+	delete(f.data.Flashes, category)
+	f.changed = true
+	return messages
+}
+
+//regenerate session id, should be called after login
+//data is kept, old session removed when saved
+//should be called before response written, as cookie will be reset
+func (f *Session) Regenerate() error {
+	id, err := f.manager.genId()
+	if err != nil {
+		return err
+	}
+	f.Lock()
+	if f.oldId == "" && !f.isNew {
+		f.oldId = f.id
+	}
+	f.id = id
+	f.changed = true
+	f.Unlock()
+	return f.manager.setCookie(id, f.c)
+}
+
+//destroy session and its cookie, like logout
+func (f *Session) Destroy() error {
+	f.Lock()
+	f.destroyed = true
+	f.Unlock()
+	return f.manager.delCookie(f.c)
+}
+
+//save session into store immediately
+//middleware will save changed session after request
+func (f *Session) Save() error {
+	return f.manager.save(f)
+}
+
+////////////////
+//private func
+////////////////
+
+//get flash category
+func (f *Session) getCategory(categories ...string) string {
+	if categories != nil && len(categories) > 0 && categories[0] != "" {
+		return categories[0]
+	}
+	return FlashCategoryDefault
+}
+
+//encode data
+func (f *Session) encode() ([]byte, error) {
+	return json.Marshal(f.data)
+}
+
+//decode data
+func (f *Session) decode(data []byte) error {
+	sd := &sessionData{}
+	if err := json.Unmarshal(data, sd); err != nil {
+		return err
+	}
+	if sd.Values == nil {
+		sd.Values = map[string]interface{}{}
+	}
+	if sd.Flashes == nil {
+		sd.Flashes = map[string][]string{}
+	}
+	f.data = sd
+	return nil
+}
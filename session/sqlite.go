@@ -0,0 +1,89 @@
+package session
+
+import (
+	"fmt"
+	"github.com/andyzhou/tinycells/db/sqlite"
+	"time"
+)
+
+/*
+ * sqlite session store
+ * expired rows removed by `Clean`
+ */
+
+//face info
+type SqliteStore struct {
+	db *sqlite.SqlLite
+	table string
+}
+
+//construct
+//table will be created if not exists
+func NewSqliteStore(db *sqlite.SqlLite, tables ...string) (*SqliteStore, error) {
+	this := &SqliteStore{
+		db: db,
+		table: SessionSqliteTable,
+	}
+	if tables != nil && len(tables) > 0 && tables[0] != "" {
+		this.table = tables[0]
+	}
+	if err := this.createTable(); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+//load session data
+func (f *SqliteStore) Load(id string) ([]byte, error) {
+	sql := fmt.Sprintf("SELECT data FROM %s WHERE id = ? AND expire_at > ?", f.table)
+	rows, err := f.db.Query(sql, []interface{}{id, time.Now().Unix()})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) <= 0 {
+		return nil, nil
+	}
+	return []byte(rows[0]["data"]), nil
+}
+
+//save session data
+func (f *SqliteStore) Save(id string, data []byte, ttl time.Duration) error {
+	sql := fmt.Sprintf("INSERT OR REPLACE INTO %s(id, data, expire_at) VALUES(?, ?, ?)", f.table)
+	_, _, err := f.db.Execute(sql, []interface{}{id, data, time.Now().Add(ttl).Unix()})
+	return err
+}
+
+//refresh ttl
+func (f *SqliteStore) Touch(id string, ttl time.Duration) error {
+	sql := fmt.Sprintf("UPDATE %s SET expire_at = ? WHERE id = ?", f.table)
+	_, _, err := f.db.Execute(sql, []interface{}{time.Now().Add(ttl).Unix(), id})
+	return err
+}
+
+//delete session
+func (f *SqliteStore) Delete(id string) error {
+	sql := fmt.Sprintf("DELETE FROM %s WHERE id = ?", f.table)
+	_, _, err := f.db.Execute(sql, []interface{}{id})
+	return err
+}
+
+//clean expired sessions
+func (f *SqliteStore) Clean() error {
+	sql := fmt.Sprintf("DELETE FROM %s WHERE expire_at <= ?", f.table)
+	_, _, err := f.db.Execute(sql, []interface{}{time.Now().Unix()})
+	return err
+}
+
+////////////////
+//private func
+////////////////
+
+//create session table
+func (f *SqliteStore) createTable() error {
+	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (" +
+		"id TEXT PRIMARY KEY, " +
+		"data BLOB NOT NULL, " +
+		"expire_at INTEGER NOT NULL)", f.table)
+	_, _, err := f.db.Execute(sql, nil)
+	return err
+}
@@ -0,0 +1,20 @@
+package session
+
+import "time"
+
+/*
+ * session store interface
+ * implement should be safe for concurrent access
+ */
+
+//store interface
+type IStore interface {
+	//load session data, return nil if not exists or expired
+	Load(id string) ([]byte, error)
+	//save session data with ttl
+	Save(id string, data []byte, ttl time.Duration) error
+	//refresh ttl without change data, for sliding expiration
+	Touch(id string, ttl time.Duration) error
+	//delete session
+	Delete(id string) error
+}
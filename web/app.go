@@ -72,9 +72,11 @@ func (f *App) Start(port int) bool {
 
 //register root app entry
 //url like: /xxx or /xxx/{ParaName:string|integer}
+//middlewares run before entry, like `JwtAuth.Middleware`
 func (f *App) RegisterSubApp(
 			reqUrlPara string,
 			face IWebSubApp,
+			middlewares ...gin.HandlerFunc,
 		) bool {
 	//check
 	if reqUrlPara == "" || face == nil {
@@ -85,7 +87,8 @@ func (f *App) RegisterSubApp(
 	requestAnyPath := fmt.Sprintf("/%v/*%v", reqUrlPara, AnyPath)
 
 	//set get、post request
-	f.server.Any(requestAnyPath, face.Entry)
+	handlers := append(append([]gin.HandlerFunc{}, middlewares...), face.Entry)
+	f.server.Any(requestAnyPath, handlers...)
 	return true
 }
 
@@ -0,0 +1,398 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/andyzhou/tinycells/crypt"
+	"github.com/andyzhou/tinycells/db/redis"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+ * jwt authentication middleware and refresh token flow
+ * - token from `Authorization: Bearer`, cookie or query para
+ * - claims injected into gin context
+ * - per route scopes
+ * - refresh token rotation with reuse detection, stored in redis
+ */
+
+//inter macro define
+const (
+	AuthHeader = "Authorization"
+	AuthBearerPrefix = "Bearer "
+	AuthCookieName = "access_token"
+	AuthQueryName = "access_token"
+	AuthScopeSplit = " "
+	AuthContextKeyOfClaims = "authClaims"
+	AuthRedisKeyPrefix = "auth:"
+	AuthAccessTtl = 15 * time.Minute
+	AuthRefreshTtl = 30 * 24 * time.Hour
+	AuthRefreshTokenSize = 32
+)
+
+//auth errors
+var (
+	ErrAuthNoToken = errors.New("no auth token")
+	ErrAuthTokenRevoked = errors.New("auth token revoked")
+	ErrAuthRefreshInvalid = errors.New("refresh token invalid")
+	ErrAuthRefreshReused = errors.New("refresh token reused")
+	ErrAuthNoRedis = errors.New("redis connect not set")
+	ErrAuthRevokeCheck = errors.New("auth token revoke check failed")
+)
+
+//access token claims
+type AuthClaims struct {
+	crypt.JwtClaims
+	Scope string `json:"scope,omitempty"` //space split scopes
+}
+
+//token pair
+type AuthToken struct {
+	AccessToken string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn int64 `json:"expiresIn"` //access token ttl seconds
+}
+
+//refresh token info stored in redis
+type authRefresh struct {
+	Subject string `json:"subject"`
+	Scopes []string `json:"scopes"`
+	Family string `json:"family"`
+}
+
+//face info
+type JwtAuth struct {
+	jwt *crypt.Jwt
+	cookie *Cookie
+	conn *redis.Connection
+	cookieName string
+	queryName string
+	keyPrefix string
+	accessTtl time.Duration
+	refreshTtl time.Duration
+}
+
+//construct
+func NewJwtAuth(jwt *crypt.Jwt, cookies ...*Cookie) *JwtAuth {
+	this := &JwtAuth{
+		jwt: jwt,
+		cookieName: AuthCookieName,
+		queryName: AuthQueryName,
+		keyPrefix: AuthRedisKeyPrefix,
+		accessTtl: AuthAccessTtl,
+		refreshTtl: AuthRefreshTtl,
+	}
+	if cookies != nil && len(cookies) > 0 {
+		this.cookie = cookies[0]
+	}
+	if this.cookie == nil {
+		this.cookie = GetCookie()
+	}
+	return this
+}
+
+//set redis connect for refresh token and revocation
+func (f *JwtAuth) SetRedis(conn *redis.Connection) {
+	f.conn = conn
+}
+
+//set token ttl
+func (f *JwtAuth) SetTtl(accessTtl, refreshTtl time.Duration) {
+	if accessTtl > 0 {
+		f.accessTtl = accessTtl
+	}
+	if refreshTtl > 0 {
+		f.refreshTtl = refreshTtl
+	}
+}
+
+//set cookie and query para name of token
+func (f *JwtAuth) SetTokenName(cookieName, queryName string) {
+	f.cookieName = cookieName
+	f.queryName = queryName
+}
+
+//set redis key prefix
+func (f *JwtAuth) SetKeyPrefix(prefix string) {
+	f.keyPrefix = prefix
+}
+
+//gin middleware, check token and required scopes
+func (f *JwtAuth) Middleware(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := f.Verify(c)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrAuthRevokeCheck) {
+				//revoke state unknown, reject as unavailable
+				status = http.StatusServiceUnavailable
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		for _, scope := range scopes {
+			if !f.HasScope(claims, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope"})
+				return
+			}
+		}
+		c.Set(AuthContextKeyOfClaims, claims)
+		c.Next()
+	}
+}
+
+//verify request token and return claims
+func (f *JwtAuth) Verify(c *gin.Context) (*AuthClaims, error) {
+	token := f.GetToken(c)
+	if token == "" {
+		return nil, ErrAuthNoToken
+	}
+	claims := &AuthClaims{}
+	if err := f.jwt.DecodeClaims(token, claims); err != nil {
+		return nil, err
+	}
+	revoked, err := f.isRevoked(claims.Id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthRevokeCheck, err)
+	}
+	if revoked {
+		return nil, ErrAuthTokenRevoked
+	}
+	return claims, nil
+}
+
+//get token from header, cookie or query para
+func (f *JwtAuth) GetToken(c *gin.Context) string {
+	header := c.GetHeader(AuthHeader)
+	if strings.HasPrefix(header, AuthBearerPrefix) {
+		return strings.TrimSpace(strings.TrimPrefix(header, AuthBearerPrefix))
+	}
+	if f.cookieName != "" {
+		if token, err := f.cookie.GetCookie(f.cookieName, c); err == nil && token != "" {
+			return token
+		}
+	}
+	if f.queryName != "" {
+		return c.Query(f.queryName)
+	}
+	return ""
+}
+
+//check claims has scope
+func (f *JwtAuth) HasScope(claims *AuthClaims, scope string) bool {
+	if claims == nil {
+		return false
+	}
+	for _, v := range strings.Split(claims.Scope, AuthScopeSplit) {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+//issue access and refresh token
+func (f *JwtAuth) IssueToken(subject string, scopes []string) (*AuthToken, error) {
+	return f.issueToken(subject, scopes, "")
+}
+
+//refresh token pair, old refresh token is consumed
+//reused refresh token will revoke whole token family
+func (f *JwtAuth) Refresh(refreshToken string) (*AuthToken, error) {
+	if f.conn == nil {
+		return nil, ErrAuthNoRedis
+	}
+	if refreshToken == "" {
+		return nil, ErrAuthRefreshInvalid
+	}
+	client := f.conn.GetClient()
+
+	//get refresh info
+	data, err := client.Get(f.refreshKey(refreshToken)).Bytes()
+	if err != nil {
+		if err.Error() != redis.Nil {
+			return nil, err
+		}
+		//check reuse
+		family, _ := client.Get(f.usedKey(refreshToken)).Result()
+		if family != "" {
+			f.RevokeFamily(family)
+			return nil, ErrAuthRefreshReused
+		}
+		return nil, ErrAuthRefreshInvalid
+	}
+	info := &authRefresh{}
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+
+	//consume old token
+	deleted, err := client.Del(f.refreshKey(refreshToken)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if deleted <= 0 {
+		//consumed by others concurrently
+		f.RevokeFamily(info.Family)
+		return nil, ErrAuthRefreshReused
+	}
+	client.Set(f.usedKey(refreshToken), info.Family, f.refreshTtl)
+
+	//check family not revoked
+	exists, err := client.Exists(f.familyKey(info.Family)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists <= 0 {
+		return nil, ErrAuthTokenRevoked
+	}
+	return f.issueToken(info.Subject, info.Scopes, info.Family)
+}
+
+//revoke refresh token and its family
+func (f *JwtAuth) RevokeRefresh(refreshToken string) error {
+	if f.conn == nil {
+		return ErrAuthNoRedis
+	}
+	client := f.conn.GetClient()
+	data, err := client.Get(f.refreshKey(refreshToken)).Bytes()
+	if err != nil {
+		return err
+	}
+	info := &authRefresh{}
+	if err = json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	client.Del(f.refreshKey(refreshToken))
+	return f.RevokeFamily(info.Family)
+}
+
+//revoke token family
+func (f *JwtAuth) RevokeFamily(family string) error {
+	if f.conn == nil {
+		return ErrAuthNoRedis
+	}
+	return f.conn.GetClient().Del(f.familyKey(family)).Err()
+}
+
+//revoke access token until it expired
+func (f *JwtAuth) RevokeAccess(claims *AuthClaims) error {
+	if f.conn == nil {
+		return ErrAuthNoRedis
+	}
+	if claims == nil || claims.Id == "" {
+		return errors.New("invalid parameter")
+	}
+	ttl := f.accessTtl
+	if claims.ExpiresAt > 0 {
+		ttl = time.Until(time.Unix(claims.ExpiresAt, 0))
+		if ttl <= 0 {
+			return nil
+		}
+	}
+	return f.conn.GetClient().Set(f.revokeKey(claims.Id), 1, ttl).Err()
+}
+
+//get claims from gin context
+func GetAuthClaims(c *gin.Context) *AuthClaims {
+	v, ok := c.Get(AuthContextKeyOfClaims)
+	if !ok {
+		return nil
+	}
+	claims, _ := v.(*AuthClaims)
+	return claims
+}
+
+////////////////
+//private func
+////////////////
+
+//issue token pair of family
+func (f *JwtAuth) issueToken(subject string, scopes []string, family string) (*AuthToken, error) {
+	//gen access token
+	claims := &AuthClaims{
+		JwtClaims: *f.jwt.GenClaims(subject, f.accessTtl),
+		Scope: strings.Join(scopes, AuthScopeSplit),
+	}
+	accessToken, err := f.jwt.EncodeClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	result := &AuthToken{
+		AccessToken: accessToken,
+		ExpiresIn: int64(f.accessTtl / time.Second),
+	}
+	if f.conn == nil {
+		return result, nil
+	}
+
+	//gen refresh token
+	refreshToken, err := f.genRandom()
+	if err != nil {
+		return nil, err
+	}
+	client := f.conn.GetClient()
+	if family == "" {
+		if family, err = f.genRandom(); err != nil {
+			return nil, err
+		}
+	}
+	data, _ := json.Marshal(&authRefresh{
+		Subject: subject,
+		Scopes: scopes,
+		Family: family,
+	})
+	pipe := client.TxPipeline()
+	pipe.Set(f.familyKey(family), 1, f.refreshTtl)
+	pipe.Set(f.refreshKey(refreshToken), data, f.refreshTtl)
+	if _, err = pipe.Exec(); err != nil {
+		return nil, err
+	}
+	result.RefreshToken = refreshToken
+	return result, nil
+}
+
+//check access token revoked
+//lookup error returned, caller should fail closed
+func (f *JwtAuth) isRevoked(jti string) (bool, error) {
+	if f.conn == nil || jti == "" {
+		return false, nil
+	}
+	exists, err := f.conn.GetClient().Exists(f.revokeKey(jti)).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+//gen random hex string
+func (f *JwtAuth) genRandom() (string, error) {
+	buf := make([]byte, AuthRefreshTokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+//redis keys
+func (f *JwtAuth) refreshKey(token string) string {
+	return fmt.Sprintf("%srt:%s", f.keyPrefix, token)
+}
+
+func (f *JwtAuth) usedKey(token string) string {
+	return fmt.Sprintf("%sused:%s", f.keyPrefix, token)
+}
+
+func (f *JwtAuth) familyKey(family string) string {
+	return fmt.Sprintf("%sfamily:%s", f.keyPrefix, family)
+}
+
+func (f *JwtAuth) revokeKey(jti string) string {
+	return fmt.Sprintf("%srevoke:%s", f.keyPrefix, jti)
+}
@@ -1,11 +1,14 @@
 package web
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/andyzhou/tinycells/crypt"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/securecookie"
+	"net/http"
 	"sync"
+	"time"
 )
 
 /*
@@ -14,11 +17,33 @@ import (
 
 //internal macro defines
 const (
-	CookieHashKey = "04DgUStWPtd0aJmiR+L2z5xwbpPr/hmH" //"the-big-and-secret-fash-key-here"//
-	CookieBlockKey = "5oR820YTUcjpBFAYlgbte8jEL9o2oHI2" //"lot-secret-of-characters-big-too"//
+	CookieHashKeySize = 32
+	CookieBlockKeySize = 32
 	CookieExpireSeconds = 86400 //xxx seconds
+	CookiePathDefault = "/"
 )
 
+//cookie errors
+var (
+	ErrCookieExpired = errors.New("cookie expired")
+	ErrCookieNoKey = errors.New("cookie secure keys not set")
+)
+
+//cookie option
+type CookieOption struct {
+	Path string
+	Domain string
+	Secure bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+//secure cookie payload, with expire time
+type secureCookieValue struct {
+	Value json.RawMessage `json:"v"`
+	ExpireAt int64 `json:"e"`
+}
+
 //global variable for single instance
 var (
 	_cookie *Cookie
@@ -27,9 +52,11 @@ var (
 
 //face info
 type Cookie struct {
-	secureCookie *securecookie.SecureCookie `secure cookie instance`
+	codecs []securecookie.Codec //first one for encode, all for decode
+	option *CookieOption
 	jwt *crypt.Jwt
 	expireTime int
+	sync.RWMutex
 }
 
 //get single instance
@@ -41,15 +68,18 @@ func GetCookie() *Cookie {
 }
 
 //construct
+//secure cookie fail with `ErrCookieNoKey` until `SetSecureKeys` called,
+//keys should be same after restart and across nodes
 func NewCookie() *Cookie {
 	//self init
 	this := &Cookie{
 		expireTime:CookieExpireSeconds,
-		//jwt: crypt.NewJwt(),
+		option: &CookieOption{
+			Path: CookiePathDefault,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
 	}
-
-	//inter init
-	this.interInit()
 	return this
 }
 
@@ -62,17 +92,176 @@ func (f *Cookie) SetExpireTime(seconds int) {
 	f.expireTime = seconds
 }
 
+//set default option for secure cookie
+func (f *Cookie) SetOption(option *CookieOption) error {
+	if option == nil {
+		return errors.New("invalid parameter")
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.option = option
+	return nil
+}
+
+//set secure keys, as hash key and block key pairs
+//first pair used for encode, all pairs for decode, so old keys can be kept for rotation
+//hash key should be 32 or 64 bytes, block key should be 16, 24 or 32 bytes
+func (f *Cookie) SetSecureKeys(hashAndBlockKeys ...[]byte) error {
+	//check
+	if len(hashAndBlockKeys) <= 0 || len(hashAndBlockKeys) % 2 != 0 {
+		return errors.New("keys should be hash and block key pairs")
+	}
+	for i := 0; i < len(hashAndBlockKeys); i += 2 {
+		if len(hashAndBlockKeys[i]) < 32 {
+			return errors.New("hash key should be at least 32 bytes")
+		}
+		switch len(hashAndBlockKeys[i+1]) {
+		case 16, 24, 32:
+		default:
+			return errors.New("block key should be 16, 24 or 32 bytes")
+		}
+	}
+
+	//init codecs
+	codecs := f.newCodecs(hashAndBlockKeys...)
+	f.Lock()
+	defer f.Unlock()
+	f.codecs = codecs
+	return nil
+}
+
+//encode value with hmac and encryption, and set into cookie
+//val should be json serializable
+//expireSeconds <= 0 means use default expire time
+func (f *Cookie) SetSecureCookie(
+			key string,
+			val interface{},
+			expireSeconds int,
+			c *gin.Context,
+			options ...*CookieOption,
+		) error {
+	//check
+	if key == "" || val == nil || c == nil {
+		return errors.New("invalid parameter")
+	}
+	f.RLock()
+	codecs := f.codecs
+	option := f.option
+	if expireSeconds <= 0 {
+		expireSeconds = f.expireTime
+	}
+	f.RUnlock()
+	if len(codecs) <= 0 {
+		return ErrCookieNoKey
+	}
+	if options != nil && len(options) > 0 && options[0] != nil {
+		option = options[0]
+	}
+
+	//encode value
+	jsonBytes, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	payload := &secureCookieValue{
+		Value: jsonBytes,
+		ExpireAt: time.Now().Unix() + int64(expireSeconds),
+	}
+	encStr, err := securecookie.EncodeMulti(key, payload, codecs...)
+	if err != nil {
+		return err
+	}
+
+	//set into cookie
+	path := option.Path
+	if path == "" {
+		path = CookiePathDefault
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name: key,
+		Value: encStr,
+		Path: path,
+		Domain: option.Domain,
+		MaxAge: expireSeconds,
+		Expires: time.Now().Add(time.Duration(expireSeconds) * time.Second),
+		Secure: option.Secure,
+		HttpOnly: option.HttpOnly,
+		SameSite: option.SameSite,
+	})
+	return nil
+}
+
+//get secure cookie and decode into out
+//return `ErrCookieExpired` if expired
+func (f *Cookie) GetSecureCookie(
+			key string,
+			out interface{},
+			c *gin.Context,
+		) error {
+	//check
+	if out == nil {
+		return errors.New("invalid parameter")
+	}
+	orgVal, err := f.GetCookie(key, c)
+	if err != nil {
+		return err
+	}
+	f.RLock()
+	codecs := f.codecs
+	f.RUnlock()
+	if len(codecs) <= 0 {
+		return ErrCookieNoKey
+	}
+
+	//decode value
+	payload := &secureCookieValue{}
+	if err = securecookie.DecodeMulti(key, orgVal, payload, codecs...); err != nil {
+		return err
+	}
+	if payload.ExpireAt > 0 && time.Now().Unix() > payload.ExpireAt {
+		return ErrCookieExpired
+	}
+	return json.Unmarshal(payload.Value, out)
+}
+
 //delete cookie
+//option should match the one of set, or browser keep the cookie
+//default option used if not passed, domain param used if not empty
 func (f *Cookie) DelCookie(name,
 	domain string,
 	c *gin.Context,
+	options ...*CookieOption,
 ) error {
 	//check
 	if name == "" || c == nil {
 		return errors.New("invalid parameter")
 	}
+	f.RLock()
+	option := f.option
+	f.RUnlock()
+	if options != nil && len(options) > 0 && options[0] != nil {
+		option = options[0]
+	}
+	path := option.Path
+	if path == "" {
+		path = CookiePathDefault
+	}
+	if domain == "" {
+		domain = option.Domain
+	}
+
 	//destroy cookie
-	c.SetCookie(name, "", -1, "/", domain, false, true)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name: name,
+		Value: "",
+		Path: path,
+		Domain: domain,
+		MaxAge: -1,
+		Expires: time.Unix(0, 0),
+		Secure: option.Secure,
+		HttpOnly: option.HttpOnly,
+		SameSite: option.SameSite,
+	})
 	return nil
 }
 
@@ -90,15 +279,28 @@ func (f *Cookie) GetCookie(
 	if err != nil {
 		return "", err
 	}
-	//try decode pass jwt
-	//jwt, err := f.jwt.Decode(orgVal)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//return jwt, nil
 	return orgVal, nil
 }
 
+//get jwt cookie and decode into claims
+func (f *Cookie) GetJwtCookie(
+			key string,
+			claims crypt.IJwtClaims,
+			c *gin.Context,
+		) error {
+	//check
+	if f.jwt == nil {
+		return errors.New("jwt not set")
+	}
+	//get original value
+	orgVal, err := f.GetCookie(key, c)
+	if err != nil {
+		return err
+	}
+	//try decode pass jwt
+	return f.jwt.DecodeClaims(orgVal, claims)
+}
+
 //set cookie
 func (f *Cookie) SetCookie(
 			key string,
@@ -111,16 +313,34 @@ func (f *Cookie) SetCookie(
 	if key == "" || val == "" || c == nil {
 		return errors.New("invalid parameter")
 	}
-	////try encode pass jwt
-	//encStr, err := f.jwt.Encode(val)
-	//if err != nil {
-	//	return err
-	//}
 	//set into cookie
 	c.SetCookie(key, val, expireSeconds, "/", domain, false, true)
 	return nil
 }
 
+//encode claims pass jwt and set into cookie
+func (f *Cookie) SetJwtCookie(
+			key string,
+			claims crypt.IJwtClaims,
+			expireSeconds int,
+			domain string,
+			c *gin.Context,
+		) error {
+	//check
+	if f.jwt == nil {
+		return errors.New("jwt not set")
+	}
+	if claims == nil {
+		return errors.New("invalid parameter")
+	}
+	//try encode pass jwt
+	encStr, err := f.jwt.EncodeClaims(claims)
+	if err != nil {
+		return err
+	}
+	return f.SetCookie(key, encStr, expireSeconds, domain, c)
+}
+
 //set jwt
 func (f *Cookie) SetJwt(jwt *crypt.Jwt) bool {
 	if jwt == nil {
@@ -134,11 +354,15 @@ func (f *Cookie) SetJwt(jwt *crypt.Jwt) bool {
 //private func
 //////////////
 
-func (f *Cookie) interInit() {
-	//init security key
-	hashKey := []byte(CookieHashKey)
-	blockKey := []byte(CookieBlockKey)
-
-	//init cookie
-	f.secureCookie = securecookie.New(hashKey, blockKey)
-}
\ No newline at end of file
+//create codecs from key pairs
+//expire checked by payload, so disable securecookie max age
+func (f *Cookie) newCodecs(hashAndBlockKeys ...[]byte) []securecookie.Codec {
+	codecs := make([]securecookie.Codec, 0, len(hashAndBlockKeys) / 2)
+	for i := 0; i < len(hashAndBlockKeys); i += 2 {
+		codec := securecookie.New(hashAndBlockKeys[i], hashAndBlockKeys[i+1])
+		codec.MaxAge(0)
+		codec.SetSerializer(securecookie.JSONEncoder{})
+		codecs = append(codecs, codec)
+	}
+	return codecs
+}
@@ -0,0 +1,226 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"net/http"
+)
+
+/*
+ * csrf protection
+ * - double submit cookie by default, token kept in signed cookie
+ * - or synchronizer token stored in session, see `SetTokenStore`
+ * - token submitted by header or form field
+ * - template func to emit hidden field
+ */
+
+//inter macro define
+const (
+	CsrfCookieName = "_csrf"
+	CsrfHeaderName = "X-CSRF-Token"
+	CsrfFormField = "_csrf"
+	CsrfSessionKey = "_csrf"
+	CsrfContextKeyOfToken = "csrfToken"
+	CsrfTokenSize = 32
+	CsrfFuncName = "csrfField"
+)
+
+//csrf errors
+var (
+	ErrCsrfTokenMissing = errors.New("csrf token missing")
+	ErrCsrfTokenInvalid = errors.New("csrf token invalid")
+)
+
+//token store, like `session.Session`
+type ICsrfTokenStore interface {
+	Get(key string) interface{}
+	Set(key string, val interface{})
+}
+
+//get token store of request
+type CsrfStoreFunc func(c *gin.Context) ICsrfTokenStore
+
+//face info
+type Csrf struct {
+	cookie *Cookie
+	cookieName string
+	headerName string
+	formField string
+	storeFunc CsrfStoreFunc
+	errFunc gin.HandlerFunc
+}
+
+//construct
+//secure keys of cookie should be set, default cookie is `GetCookie()`
+func NewCsrf(cookies ...*Cookie) *Csrf {
+	this := &Csrf{
+		cookieName: CsrfCookieName,
+		headerName: CsrfHeaderName,
+		formField: CsrfFormField,
+	}
+	if cookies != nil && len(cookies) > 0 {
+		this.cookie = cookies[0]
+	}
+	if this.cookie == nil {
+		this.cookie = GetCookie()
+	}
+	return this
+}
+
+//set token names
+func (f *Csrf) SetNames(cookieName, headerName, formField string) {
+	if cookieName != "" {
+		f.cookieName = cookieName
+	}
+	if headerName != "" {
+		f.headerName = headerName
+	}
+	if formField != "" {
+		f.formField = formField
+	}
+}
+
+//set token store, use synchronizer token instead of double submit cookie
+//store middleware, like session, should run before csrf middleware
+func (f *Csrf) SetTokenStore(storeFunc CsrfStoreFunc) {
+	f.storeFunc = storeFunc
+}
+
+//set handler for invalid token, default abort with 403
+func (f *Csrf) SetErrHandler(errFunc gin.HandlerFunc) {
+	f.errFunc = errFunc
+}
+
+//gin middleware
+func (f *Csrf) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		//get or create token
+		token, err := f.getToken(c)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Set(CsrfContextKeyOfToken, token)
+
+		//check unsafe method
+		if !f.isSafeMethod(c.Request.Method) {
+			if err = f.verify(c, token); err != nil {
+				if f.errFunc != nil {
+					f.errFunc(c)
+					c.Abort()
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+//get template func map
+//merge it into map of `App.SetMapFunc`, before `App.SetTplPattern`
+//usage in template: {{ csrfField .csrfToken }}
+func (f *Csrf) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		CsrfFuncName: f.Field,
+	}
+}
+
+//register template func into app
+//it will replace app func map, use `FuncMap` if has other funcs
+func (f *Csrf) RegisterFuncMap(app *App) {
+	app.SetMapFunc(f.FuncMap())
+}
+
+//gen hidden form field
+func (f *Csrf) Field(token string) template.HTML {
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+		template.HTMLEscapeString(f.formField), template.HTMLEscapeString(token)))
+}
+
+//get csrf token from gin context
+func GetCsrfToken(c *gin.Context) string {
+	v, ok := c.Get(CsrfContextKeyOfToken)
+	if !ok {
+		return ""
+	}
+	token, _ := v.(string)
+	return token
+}
+
+////////////////
+//private func
+////////////////
+
+//get or create token
+func (f *Csrf) getToken(c *gin.Context) (string, error) {
+	var (
+		token string
+	)
+	//get exists token
+	if f.storeFunc != nil {
+		store := f.storeFunc(c)
+		if store == nil {
+			return "", errors.New("csrf token store not found")
+		}
+		token, _ = store.Get(CsrfSessionKey).(string)
+		if token != "" {
+			return token, nil
+		}
+	}else if err := f.cookie.GetSecureCookie(f.cookieName, &token, c); err == nil && token != "" {
+		return token, nil
+	}
+
+	//create new token
+	token, err := f.genToken()
+	if err != nil {
+		return "", err
+	}
+	if f.storeFunc != nil {
+		f.storeFunc(c).Set(CsrfSessionKey, token)
+		return token, nil
+	}
+	if err = f.cookie.SetSecureCookie(f.cookieName, token, 0, c); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+//verify submitted token
+func (f *Csrf) verify(c *gin.Context, token string) error {
+	submitted := c.GetHeader(f.headerName)
+	if submitted == "" {
+		submitted = c.PostForm(f.formField)
+	}
+	if submitted == "" {
+		return ErrCsrfTokenMissing
+	}
+	if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+		return ErrCsrfTokenInvalid
+	}
+	return nil
+}
+
+//check is safe method
+func (f *Csrf) isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+//gen random token
+func (f *Csrf) genToken() (string, error) {
+	buf := make([]byte, CsrfTokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+/*
+ * security headers middleware
+ * - CSP, HSTS, X-Frame-Options, Referrer-Policy and X-Content-Type-Options
+ * - per route override by `OverrideHeaders`
+ */
+
+//header name
+const (
+	HeaderOfCsp = "Content-Security-Policy"
+	HeaderOfHsts = "Strict-Transport-Security"
+	HeaderOfFrameOptions = "X-Frame-Options"
+	HeaderOfReferrerPolicy = "Referrer-Policy"
+	HeaderOfContentTypeOptions = "X-Content-Type-Options"
+)
+
+//default value
+const (
+	SecurityCspDefault = "default-src 'self'"
+	SecurityHstsDefault = "max-age=31536000; includeSubDomains"
+	SecurityFrameOptionsDefault = "DENY"
+	SecurityReferrerPolicyDefault = "strict-origin-when-cross-origin"
+	SecurityContentTypeOptionsDefault = "nosniff"
+)
+
+//face info
+//empty field means header not set
+type SecurityHeaders struct {
+	Csp string
+	Hsts string
+	FrameOptions string
+	ReferrerPolicy string
+	ContentTypeOptions string
+	HstsOnlyTls bool //only send hsts for tls request
+}
+
+//construct, with default value
+func NewSecurityHeaders() *SecurityHeaders {
+	this := &SecurityHeaders{
+		Csp: SecurityCspDefault,
+		Hsts: SecurityHstsDefault,
+		FrameOptions: SecurityFrameOptionsDefault,
+		ReferrerPolicy: SecurityReferrerPolicyDefault,
+		ContentTypeOptions: SecurityContentTypeOptionsDefault,
+		HstsOnlyTls: true,
+	}
+	return this
+}
+
+//gin middleware
+func (f *SecurityHeaders) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f.setHeader(c, HeaderOfCsp, f.Csp)
+		f.setHeader(c, HeaderOfFrameOptions, f.FrameOptions)
+		f.setHeader(c, HeaderOfReferrerPolicy, f.ReferrerPolicy)
+		f.setHeader(c, HeaderOfContentTypeOptions, f.ContentTypeOptions)
+		if f.Hsts != "" && (!f.HstsOnlyTls || f.isTls(c)) {
+			f.setHeader(c, HeaderOfHsts, f.Hsts)
+		}
+		c.Next()
+	}
+}
+
+//per route override middleware, should run after `Middleware`
+//header name -> value, empty value means remove the header
+//like: RegisterSubApp("embed", face, OverrideHeaders(map[string]string{HeaderOfFrameOptions: ""}))
+func OverrideHeaders(headers map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		for k, v := range headers {
+			if v == "" {
+				header.Del(k)
+			}else{
+				header.Set(k, v)
+			}
+		}
+		c.Next()
+	}
+}
+
+////////////////
+//private func
+////////////////
+
+//set header if value not empty
+func (f *SecurityHeaders) setHeader(c *gin.Context, name, value string) {
+	if value == "" {
+		return
+	}
+	c.Writer.Header().Set(name, value)
+}
+
+//check request is tls, or behind tls proxy
+func (f *SecurityHeaders) isTls(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
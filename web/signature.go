@@ -0,0 +1,165 @@
+package web
+
+import (
+	"fmt"
+	"github.com/andyzhou/tinycells/crypt"
+	"github.com/andyzhou/tinycells/db/redis"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+ * hmac request signature verification middleware
+ * - reject invalid signature and stale timestamp
+ * - reject replayed nonce tracked in nonce store, redis or memory
+ */
+
+//inter macro define
+const (
+	SignRedisKeyPrefix = "sign:nonce:"
+	SignContextKeyOfInfo = "signInfo"
+)
+
+//nonce store interface
+type ISignNonceStore interface {
+	//add nonce with ttl, return false if already exists
+	AddNonce(key string, ttl time.Duration) (bool, error)
+}
+
+//face info
+type SignatureAuth struct {
+	signer *crypt.HmacSigner
+	store ISignNonceStore
+	keyPrefix string
+}
+
+//construct
+//if conn is nil, nonce kept in memory store, only for single node
+func NewSignatureAuth(signer *crypt.HmacSigner, conn *redis.Connection) *SignatureAuth {
+	this := &SignatureAuth{
+		signer: signer,
+		keyPrefix: SignRedisKeyPrefix,
+	}
+	if conn != nil {
+		this.store = &redisNonceStore{conn: conn}
+	}else{
+		this.store = NewMemoryNonceStore()
+	}
+	return this
+}
+
+//set redis key prefix
+func (f *SignatureAuth) SetKeyPrefix(prefix string) {
+	f.keyPrefix = prefix
+}
+
+//set nonce store, like `NewMemoryNonceStore()` for single node
+//nil store is ignored, replay check can't be disabled
+func (f *SignatureAuth) SetNonceStore(store ISignNonceStore) {
+	if store == nil {
+		return
+	}
+	f.store = store
+}
+
+//gin middleware
+func (f *SignatureAuth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, err := f.signer.VerifyRequest(c.Request)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if err == crypt.ErrSignBodyTooLarge {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		replayed, err := f.isReplayed(info)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if replayed {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "request nonce replayed"})
+			return
+		}
+		c.Set(SignContextKeyOfInfo, info)
+		c.Next()
+	}
+}
+
+//get verified sign info from gin context
+func GetSignInfo(c *gin.Context) *crypt.SignInfo {
+	v, ok := c.Get(SignContextKeyOfInfo)
+	if !ok {
+		return nil
+	}
+	info, _ := v.(*crypt.SignInfo)
+	return info
+}
+
+////////////////
+//private func
+////////////////
+
+//check and record nonce
+//nonce kept for twice of max skew, out of window will be rejected by timestamp
+func (f *SignatureAuth) isReplayed(info *crypt.SignInfo) (bool, error) {
+	key := fmt.Sprintf("%s%s:%s", f.keyPrefix, info.KeyId, info.Nonce)
+	ok, err := f.store.AddNonce(key, 2 * f.signer.GetMaxSkew())
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+/////////////////
+//nonce store
+/////////////////
+
+//redis nonce store
+type redisNonceStore struct {
+	conn *redis.Connection
+}
+
+//add nonce by SETNX
+func (s *redisNonceStore) AddNonce(key string, ttl time.Duration) (bool, error) {
+	return s.conn.GetClient().SetNX(key, 1, ttl).Result()
+}
+
+//memory nonce store, for single node
+type MemoryNonceStore struct {
+	nonceMap map[string]time.Time //key -> expire at
+	sweepAt time.Time
+	sync.Mutex
+}
+
+//construct
+func NewMemoryNonceStore() *MemoryNonceStore {
+	this := &MemoryNonceStore{
+		nonceMap: map[string]time.Time{},
+	}
+	return this
+}
+
+//add nonce, expired nonces swept once per ttl
+func (s *MemoryNonceStore) AddNonce(key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.Lock()
+	defer s.Unlock()
+	if expireAt, ok := s.nonceMap[key]; ok && expireAt.After(now) {
+		return false, nil
+	}
+	if now.After(s.sweepAt) {
+		for k, v := range s.nonceMap {
+			if !v.After(now) {
+				delete(s.nonceMap, k)
+			}
+		}
+		s.sweepAt = now.Add(ttl)
+	}
+	s.nonceMap[key] = now.Add(ttl)
+	return true, nil
+}